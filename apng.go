@@ -0,0 +1,171 @@
+package tad
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+	"time"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// apngFrame is a compressed frame waiting for the animation to be written
+type apngFrame struct {
+	rect  image.Rectangle
+	delay time.Duration
+	data  []byte
+}
+
+// APNGSink encodes frames as an animated PNG in full color. Every frame after the first
+// only holds the area that changed. Frames are kept compressed in memory until Close
+// as the number of frames goes in front of them.
+type APNGSink struct {
+	w      io.Writer
+	frames []apngFrame
+	last   *image.NRGBA
+}
+
+// NewAPNGSink creates an APNGSink that writes to w when it is closed
+func NewAPNGSink(w io.Writer) *APNGSink {
+	return &APNGSink{w: w}
+}
+
+// WriteFrame compresses the part of the frame that changed since the last one
+func (as *APNGSink) WriteFrame(img image.Image, delay time.Duration) error {
+	full := image.NewNRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+	draw.Draw(full, full.Bounds(), img, img.Bounds().Min, draw.Src)
+	rect := full.Bounds()
+	if as.last != nil {
+		if !as.last.Bounds().Eq(full.Bounds()) {
+			return errors.New("apng frames must all be the same size")
+		}
+		rect = changedBounds(full.Bounds(), func(x, y int) bool {
+			i := full.PixOffset(x, y)
+			return bytes.Equal(full.Pix[i:i+4], as.last.Pix[i:i+4])
+		})
+		if rect.Empty() {
+			// nothing moved so the last frame is shown for longer
+			as.frames[len(as.frames)-1].delay += delay
+			return nil
+		}
+	}
+	data, err := compressRows(full.SubImage(rect).(*image.NRGBA))
+	if err != nil {
+		return err
+	}
+	as.frames = append(as.frames, apngFrame{rect: rect, delay: delay, data: data})
+	as.last = full
+	return nil
+}
+
+// compressRows zlib compresses the pixels of an image with the up filter on every row
+func compressRows(img *image.NRGBA) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	b := img.Bounds()
+	rowLen := 4 * b.Dx()
+	row := make([]byte, rowLen+1)
+	var prev []byte
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		i := img.PixOffset(b.Min.X, y)
+		cur := img.Pix[i : i+rowLen]
+		row[0] = 2
+		for j := range cur {
+			row[j+1] = cur[j]
+			if prev != nil {
+				row[j+1] -= prev[j]
+			}
+		}
+		if _, err := zw.Write(row); err != nil {
+			return nil, err
+		}
+		prev = cur
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeChunk writes a PNG chunk with its length and checksum
+func writeChunk(w io.Writer, name string, data []byte) error {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+	copy(header[4:], name)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+	footer := make([]byte, 4)
+	binary.BigEndian.PutUint32(footer, crc.Sum32())
+	for _, b := range [][]byte{header, data, footer} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close writes the animation. Viewers without APNG support show the first frame.
+func (as *APNGSink) Close() error {
+	if len(as.frames) == 0 {
+		return errors.New("apng needs at least one frame")
+	}
+	if _, err := as.w.Write(pngSignature); err != nil {
+		return err
+	}
+	size := as.frames[0].rect.Size()
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], uint32(size.X))
+	binary.BigEndian.PutUint32(ihdr[4:], uint32(size.Y))
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 6 // truecolor with alpha
+	if err := writeChunk(as.w, "IHDR", ihdr); err != nil {
+		return err
+	}
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl[0:], uint32(len(as.frames)))
+	// 0 plays forever
+	if err := writeChunk(as.w, "acTL", actl); err != nil {
+		return err
+	}
+	var seq uint32
+	for i, f := range as.frames {
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl[0:], seq)
+		binary.BigEndian.PutUint32(fctl[4:], uint32(f.rect.Dx()))
+		binary.BigEndian.PutUint32(fctl[8:], uint32(f.rect.Dy()))
+		binary.BigEndian.PutUint32(fctl[12:], uint32(f.rect.Min.X))
+		binary.BigEndian.PutUint32(fctl[16:], uint32(f.rect.Min.Y))
+		delay := f.delay / time.Millisecond
+		if delay > math.MaxUint16 {
+			delay = math.MaxUint16
+		}
+		binary.BigEndian.PutUint16(fctl[20:], uint16(delay))
+		binary.BigEndian.PutUint16(fctl[22:], 1000)
+		// dispose op none and blend op source are both 0
+		if err := writeChunk(as.w, "fcTL", fctl); err != nil {
+			return err
+		}
+		seq++
+		if i == 0 {
+			if err := writeChunk(as.w, "IDAT", f.data); err != nil {
+				return err
+			}
+			continue
+		}
+		fdat := make([]byte, 4+len(f.data))
+		binary.BigEndian.PutUint32(fdat, seq)
+		copy(fdat[4:], f.data)
+		if err := writeChunk(as.w, "fdAT", fdat); err != nil {
+			return err
+		}
+		seq++
+	}
+	return writeChunk(as.w, "IEND", nil)
+}
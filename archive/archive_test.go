@@ -0,0 +1,532 @@
+package archive
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cosmouser/tad"
+	"github.com/cosmouser/tad/internal/tedtest"
+)
+
+func TestIndexer(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel, content string) {
+		path := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.ted", "not a recording")
+	write("sub/b.TED", "also not a recording")
+	write("notes.txt", "not a demo")
+	write("indexed.ted", "an indexed game")
+	write("copy/indexed.ted", "an indexed game")
+	indexPath := filepath.Join(t.TempDir(), "index.jsonl")
+
+	// an earlier run indexed one game and was cut off while writing
+	info, err := os.Stat(filepath.Join(root, "indexed.ted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("an indexed game"))
+	seed, _ := json.Marshal(Entry{
+		Path:    "indexed.ted",
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Hash:    hex.EncodeToString(sum[:]),
+		Report:  &tad.GameReport{Map: "Dark Comet"},
+	})
+	if err := os.WriteFile(indexPath, append(append(seed, '\n'), `{"path":"half`...), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ix := &Indexer{Workers: 2, Timeout: time.Minute}
+	stats, err := ix.Index(context.Background(), root, indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (IndexStats{Skipped: 1, Duplicates: 1, Failed: 2}); stats != want {
+		t.Errorf("wanted %+v, got %+v", want, stats)
+	}
+	entries, err := LoadIndex(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("wanted 4 entries, got %d", len(entries))
+	}
+	for _, e := range entries[1:] {
+		switch e.Path {
+		case "copy/indexed.ted":
+			if e.DuplicateOf != "indexed.ted" || e.Report != nil {
+				t.Errorf("wanted a duplicate of indexed.ted, got %+v", e)
+			}
+		case "a.ted", "sub/b.TED":
+			if e.ErrorKind != MalformedError.String() || e.Report != nil {
+				t.Errorf("wanted %v to be malformed, got %+v", e.Path, e)
+			}
+		default:
+			t.Errorf("unexpected entry %+v", e)
+		}
+	}
+
+	stats, err = ix.Index(context.Background(), root, indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (IndexStats{Skipped: 4}); stats != want {
+		t.Errorf("wanted everything skipped on the second run, got %+v", stats)
+	}
+	ix.RetryFailed = true
+	stats, err = ix.Index(context.Background(), root, indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (IndexStats{Skipped: 2, Failed: 2}); stats != want {
+		t.Errorf("wanted the failures retried, got %+v", stats)
+	}
+	if entries, err = LoadIndex(indexPath); err != nil || len(entries) != 4 {
+		t.Errorf("wanted the retries to replace the failed entries, got %d entries and %v", len(entries), err)
+	}
+}
+
+// demo writes a recording of a game in the same lobby each time. Games of the same
+// length are copies and comments only change the file.
+func demo(moves int, comments string) []byte {
+	d := &tedtest.Demo{
+		Map:      "Dark Comet",
+		MaxUnits: 500,
+		Players: []tedtest.Player{
+			{Number: 1, Name: "Kazik", Color: 0, TDPID: 11},
+			{Number: 2, Name: "Fez", Side: 1, Color: 1, TDPID: 12},
+		},
+		Sectors: []tedtest.Sector{{Type: tedtest.CommentsSector, Data: []byte(comments)}},
+	}
+	for i := 0; i < moves; i++ {
+		d.Moves = append(d.Moves, tedtest.Move{Time: 100, Sender: byte(i%2 + 1), Data: tedtest.Packets([]byte{0xff})})
+	}
+	return d.Bytes()
+}
+
+func TestIndexerRematches(t *testing.T) {
+	root := t.TempDir()
+	write := func(rel string, data []byte) {
+		if err := os.WriteFile(filepath.Join(root, rel), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("game.ted", demo(10, "recorded by Kazik"))
+	write("fez.ted", demo(10, "recorded by Fez"))
+	write("rematch.ted", demo(12, "recorded by Kazik"))
+	indexPath := filepath.Join(t.TempDir(), "index.jsonl")
+	// one worker indexes the files in the order of the walk
+	ix := &Indexer{Workers: 1, Timeout: time.Minute}
+	stats, err := ix.Index(context.Background(), root, indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (IndexStats{Indexed: 2, Duplicates: 1}); stats != want {
+		t.Errorf("wanted the rematch indexed and the other recorder's copy a duplicate, got %+v", stats)
+	}
+	entries, err := LoadIndex(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPath := make(map[string]Entry)
+	for _, e := range entries {
+		byPath[e.Path] = e
+	}
+	if e := byPath["game.ted"]; e.DuplicateOf != "fez.ted" {
+		t.Errorf("wanted game.ted to be a copy of fez.ted, got %+v", e)
+	}
+	if a, b := byPath["fez.ted"], byPath["rematch.ted"]; a.Fingerprint != b.Fingerprint || a.Key == b.Key || b.Report == nil {
+		t.Errorf("wanted the rematch to share the fingerprint and not the key, got %+v and %+v", a, b)
+	}
+
+	// a file rewritten in place is indexed again instead of being a copy of itself
+	write("fez.ted", demo(14, "recorded by Fez"))
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "fez.ted"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err = ix.Index(context.Background(), root, indexPath); err != nil {
+		t.Fatal(err)
+	}
+	if want := (IndexStats{Indexed: 1, Skipped: 2}); stats != want {
+		t.Errorf("wanted the rewritten file indexed again, got %+v", stats)
+	}
+	if entries, err = LoadIndex(indexPath); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("wanted the new entry to replace the old one, got %+v", entries)
+	}
+	for _, e := range entries {
+		if e.Path == "fez.ted" && (e.DuplicateOf != "" || e.Report == nil || e.Report.Moves != 14) {
+			t.Errorf("wanted the rewritten game indexed, got %+v", e)
+		}
+	}
+}
+
+// player is a player of a game made by game
+type player struct {
+	name      string
+	side      string
+	tdpid     int32
+	ip        string
+	allied    bool
+	timeToDie int
+}
+
+// game makes a report of a game on map with the fingerprint fp, which is also its game
+// key, and players numbered from 1
+func game(fp, mapName, recorded string, players ...player) *tad.GameReport {
+	g := &tad.GameReport{Fingerprint: fp, Map: mapName, Unitsum: "0x1", Recorded: recorded}
+	for i, p := range players {
+		g.Players = append(g.Players, tad.PlayerReport{
+			Number: i + 1, Name: p.name, Side: p.side, TDPID: p.tdpid, IP: p.ip, Allied: p.allied, TimeToDie: p.timeToDie,
+		})
+	}
+	return g
+}
+
+func TestDB(t *testing.T) {
+	db, err := OpenDB(filepath.Join(t.TempDir(), "games.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	kazik, fez, hessano := player{name: "Kazik", tdpid: 5}, player{name: "Fez", tdpid: 3}, player{name: "Hessano", tdpid: 7}
+	games := []*tad.GameReport{
+		game("a", "Dark Comet", "2021-01-10T10:00:00Z", kazik, fez),
+		game("b", "Dark Comet", "2021-03-10T10:00:00Z", kazik, hessano),
+		game("c", "Coast to Coast", "2021-05-10T10:00:00Z", fez, kazik, hessano),
+		game("d", "Dark Comet", "", fez),
+	}
+	games[1].Unitsum = "0x2"
+	for _, g := range games {
+		if err := db.Put(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// moving a game to another map drops it from the old map's index
+	if err := db.Put(game("d", "Metal Isles", "", fez)); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		q    Query
+		want string
+	}{
+		{Query{}, "dabc"},
+		{Query{Players: []string{"kazik"}, Map: "dark comet"}, "ab"},
+		{Query{Players: []string{"Kazik", "Fez"}}, "ac"},
+		{Query{TDPIDs: []int32{7}}, "bc"},
+		{Query{Unitsum: "0x1", From: time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)}, "c"},
+		{Query{To: time.Date(2021, 3, 10, 10, 0, 0, 0, time.UTC)}, "ab"},
+		{Query{Map: "Metal Isles"}, "d"},
+		{Query{Players: []string{"nobody"}}, ""},
+	}
+	for _, c := range cases {
+		found, err := db.Find(c.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		for _, g := range found {
+			got += g.Fingerprint
+		}
+		if got != c.want {
+			t.Errorf("%+v found %q, wanted %q", c.q, got, c.want)
+		}
+	}
+	// a rematch in the same lobby has the fingerprint and not the key of the game before it
+	rematch := game("a", "Dark Comet", "2021-01-10T11:00:00Z", kazik, fez)
+	rematch.Key = "a2"
+	if err := db.Put(rematch); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := db.Find(Query{Fingerprint: "a"}); err != nil || len(found) != 2 || found[1].Key != "a2" {
+		t.Errorf("wanted the game and its rematch by fingerprint, got %+v %v", found, err)
+	}
+	if g, err := db.Get("a2"); err != nil || g.Recorded != rematch.Recorded {
+		t.Errorf("wanted the rematch, got %+v %v", g, err)
+	}
+	if g, err := db.Get("b"); err != nil || g.Players[1].Name != "Hessano" {
+		t.Errorf("wanted game b, got %+v %v", g, err)
+	}
+	if _, err := db.Get("z"); err != ErrNotFound {
+		t.Errorf("wanted ErrNotFound, got %v", err)
+	}
+}
+
+func TestIdentities(t *testing.T) {
+	r := NewResolver(nil)
+	for _, g := range []*tad.GameReport{
+		game("a", "", "2021-01-10T10:00:00Z", player{name: "Kazik", tdpid: 11, ip: "1.1.1.1"}, player{name: "Fez", tdpid: 22, ip: "2.2.2.2"}),
+		game("b", "", "2021-02-10T10:00:00Z", player{name: "Kazik", tdpid: 33, ip: "1.1.1.1"}, player{name: "Hessano", tdpid: 44, ip: "3.3.3.3"}),
+		// a new name with the TDPID and IP of the last game
+		game("c", "", "2021-03-10T10:00:00Z", player{name: "Smurf", tdpid: 33, ip: "1.1.1.1"}, player{name: "fez", tdpid: 55, ip: "2.2.2.2"}),
+		// two players behind one address
+		game("d", "", "2021-04-10T10:00:00Z", player{name: "LanA", tdpid: 66, ip: "9.9.9.9"}, player{name: "LanB", tdpid: 77, ip: "9.9.9.9"}),
+		game("e", "", "2021-05-10T10:00:00Z", player{name: "LanC", tdpid: 88, ip: "9.9.9.9"}),
+		// a stranger with a name that's taken
+		game("f", "", "2021-06-10T10:00:00Z", player{name: "Hessano", tdpid: 99, ip: "7.7.7.7"}),
+	} {
+		r.Add(g)
+	}
+	summary := func() map[string]string {
+		out := make(map[string]string)
+		for _, id := range r.Identities() {
+			var apps string
+			for _, a := range id.Appearances {
+				apps += a.String() + " "
+			}
+			out[id.ID] = apps
+		}
+		return out
+	}
+	check := func(want map[string]string) {
+		t.Helper()
+		got := summary()
+		if len(got) != len(want) {
+			t.Errorf("got identities %q, wanted %q", got, want)
+			return
+		}
+		for id, apps := range want {
+			if got[id] != apps {
+				t.Errorf("identity %v has %q, wanted %q", id, got[id], apps)
+			}
+		}
+	}
+	check(map[string]string{
+		"a/1": "a/1 b/1 c/1 ",
+		"a/2": "a/2 c/2 ",
+		"b/2": "b/2 ",
+		"d/1": "d/1 ",
+		"d/2": "d/2 ",
+		"e/1": "e/1 ",
+		"f/1": "f/1 ",
+	})
+	for _, id := range r.Identities() {
+		if id.ID != "a/1" {
+			continue
+		}
+		if len(id.Names) != 2 || id.Names[1] != "Smurf" {
+			t.Errorf("wanted names Kazik and Smurf, got %q", id.Names)
+		}
+		for _, l := range id.Links {
+			if l.B.Game == "c" && (len(l.Evidence) != 2 || l.Evidence[0].Kind != SameTDPID || l.Confidence < 0.6 || l.Confidence > 0.7) {
+				t.Errorf("unexpected smurf link %+v", l)
+			}
+		}
+	}
+
+	r.Split(AppearanceKey{"b", 1}, AppearanceKey{"c", 1}, "different player", "admin")
+	r.Merge(AppearanceKey{"d", 1}, AppearanceKey{"e", 1}, "same lan player", "admin")
+	check(map[string]string{
+		"a/1": "a/1 b/1 ",
+		"c/1": "c/1 ",
+		"a/2": "a/2 c/2 ",
+		"b/2": "b/2 ",
+		"d/1": "d/1 e/1 ",
+		"d/2": "d/2 ",
+		"f/1": "f/1 ",
+	})
+	// overrides are kept to be loaded again
+	data, err := json.Marshal(r.Overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var overrides []Override
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		t.Fatal(err)
+	}
+	if len(overrides) != 2 || overrides[1].Kind != MergeOverride || overrides[1].By != "admin" {
+		t.Errorf("unexpected audit trail %+v", overrides)
+	}
+	// a later merge undoes the split
+	r.Merge(AppearanceKey{"c", 1}, AppearanceKey{"b", 1}, "confirmed smurf", "admin")
+	if got := summary()["a/1"]; got != "a/1 b/1 c/1 " {
+		t.Errorf("after merge a/1 has %q", got)
+	}
+}
+
+func TestLadder(t *testing.T) {
+	games := []*tad.GameReport{
+		game("a", "", "2021-01-10T10:00:00Z", player{name: "Kazik", side: "arm", tdpid: 11, timeToDie: 1001}, player{name: "Fez", side: "core", timeToDie: 500}),
+		game("b", "", "2021-02-10T10:00:00Z",
+			player{name: "Kazik", side: "arm", tdpid: 11, timeToDie: 2001}, player{name: "Hessano", side: "core", allied: true, timeToDie: 900},
+			player{name: "Fez", side: "core", timeToDie: 300}, player{name: "Zor", side: "arm", timeToDie: 400},
+			player{name: "Watcher", side: "watcher"}),
+		// free for all where two players outlast the third
+		game("c", "", "2021-03-10T10:00:00Z", player{name: "Kazik", side: "arm", tdpid: 11, timeToDie: 100}, player{name: "Fez", side: "core", timeToDie: 3001}, player{name: "Hessano", side: "arm", timeToDie: 3001}),
+		// a copy of the first game
+		game("a", "", "2021-01-10T10:00:00Z", player{name: "Kazik", side: "arm", tdpid: 11, timeToDie: 1001}, player{name: "Fez", side: "core", timeToDie: 500}),
+		// nobody to play against
+		game("d", "", "2021-04-10T10:00:00Z", player{name: "Kazik", side: "arm", tdpid: 11, timeToDie: 1001}, player{name: "Watcher", side: "watcher"}),
+	}
+	var l Ladder
+	l.Rebuild(games)
+	if _, ok := l.Player("watcher"); ok {
+		t.Error("watcher was rated")
+	}
+	kazik, _ := l.Player("kazik")
+	if kazik.Games != 3 || kazik.Wins != 2 || kazik.Losses != 1 || len(kazik.History) != 3 {
+		t.Errorf("unexpected record for Kazik %+v", kazik)
+	}
+	if h := kazik.History[0]; h.Game != "a" || h.Rating != 1516 || h.Result != "win" {
+		t.Errorf("unexpected first game of Kazik %+v", h)
+	}
+	fez, _ := l.Player("fez")
+	if fez.Draws != 1 || fez.History[0].Rating != 1484 {
+		t.Errorf("unexpected record for Fez %+v", fez)
+	}
+	hessano, _ := l.Player("hessano")
+	if hessano.History[0].Change != kazik.History[1].Change {
+		t.Errorf("teammates got %v and %v", hessano.History[0].Change, kazik.History[1].Change)
+	}
+	var total float64
+	for _, pr := range l.Standings() {
+		total += pr.Rating
+	}
+	if math.Abs(total-4*1500) > 1e-9 {
+		t.Errorf("ratings add up to %v", total)
+	}
+
+	// the order games are found in doesn't matter
+	var again Ladder
+	again.Rebuild([]*tad.GameReport{games[2], games[4], games[1], games[0]})
+	if !reflect.DeepEqual(l.Standings(), again.Standings()) {
+		t.Errorf("rebuilding gave %+v, wanted %+v", again.Standings(), l.Standings())
+	}
+
+	// a rematch in the same lobby shares the fingerprint and is rated on its own
+	rematch := game("a", "", "2021-01-10T11:00:00Z", player{name: "Kazik", side: "arm", tdpid: 11, timeToDie: 1001}, player{name: "Fez", side: "core", timeToDie: 500})
+	rematch.Key = "a2"
+	var rematches Ladder
+	rematches.Rebuild([]*tad.GameReport{games[0], rematch})
+	if pr, _ := rematches.Player("kazik"); pr.Games != 2 || pr.History[1].Game != "a2" {
+		t.Errorf("wanted the rematch rated, got %+v", pr)
+	}
+
+	// identities join the names of a smurf
+	games = append(games, game("e", "", "2021-05-10T10:00:00Z", player{name: "K4zik", side: "arm", timeToDie: 1001}, player{name: "Zor", side: "core", timeToDie: 500}))
+	// Kazik's games are linked by name and TDPID and Fez only has a name to go by
+	r := NewResolver(nil)
+	for _, g := range games {
+		r.Add(g)
+	}
+	r.Merge(AppearanceKey{"a", 1}, AppearanceKey{"e", 1}, "smurf", "admin")
+	ided := Ladder{Identify: Identified(r.Identities())}
+	ided.Rebuild(games)
+	if pr, _ := ided.Player("a/1"); pr.Games != 4 || pr.Wins != 3 || pr.Name != "K4zik" {
+		t.Errorf("unexpected record for identity a/1 %+v", pr)
+	}
+	if pr, ok := ided.Player("fez"); !ok || pr.Games != fez.Games {
+		t.Errorf("wanted Fez rated by name with %d games, got %+v", fez.Games, pr)
+	}
+}
+
+func TestCareers(t *testing.T) {
+	kazik := func(side string, timeToDie int, died bool, metal float64, units ...tad.UnitReport) tad.PlayerReport {
+		return tad.PlayerReport{
+			Number: 1, Name: "Kazik", Side: side, TimeToDie: timeToDie, Died: died, Actions: 300,
+			ScoreSeries: []tad.ScoreSample{{Milliseconds: 30000, Metal: metal}, {Milliseconds: 50000, Metal: metal + 2}, {Milliseconds: 70000, Metal: 10}},
+			Units:       units,
+		}
+	}
+	fez := func(timeToDie int, died bool) tad.PlayerReport {
+		return tad.PlayerReport{Number: 2, Name: "Fez", Side: "core", TimeToDie: timeToDie, Died: died}
+	}
+	zeus := tad.UnitReport{NetID: 235, Name: "ARMZEUS", Produced: 10, Kills: []tad.UnitCount{{NetID: 7, Count: 4}, {NetID: 9, Count: 2}}, Deaths: []tad.UnitCount{{NetID: 7, Count: 3}}}
+	peewee := tad.UnitReport{NetID: 100, Name: "ARMPW", Produced: 12}
+	games := []*tad.GameReport{
+		{Fingerprint: "a", Map: "Dark Comet", Recorded: "2021-01-10T10:00:00Z",
+			Players: []tad.PlayerReport{kazik("arm", 600001, false, 4, zeus), fez(300000, true)}},
+		{Fingerprint: "b", Map: "Dark Comet", Recorded: "2021-02-10T10:00:00Z",
+			Players: []tad.PlayerReport{kazik("core", 300000, true, 8, zeus, peewee), fez(600001, false)}},
+		{Fingerprint: "c", Map: "Coast to Coast", Recorded: "2021-03-10T10:00:00Z",
+			Players: []tad.PlayerReport{kazik("arm", 300001, false, 2), fez(120000, true)}},
+	}
+	all := make(Careers)
+	for _, g := range games {
+		all.Add(g, nil)
+	}
+	// careers built as games arrive are the same
+	first, later := make(Careers), make(Careers)
+	first.Add(games[0], nil)
+	first.Add(games[0], nil)
+	later.Add(games[1], nil)
+	later.Add(games[2], nil)
+	if err := first.Merge(later); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(all, first) {
+		t.Errorf("merged careers %+v differ from %+v", first["kazik"], all["kazik"])
+	}
+	if err := first.Merge(later); err == nil {
+		t.Error("merged the same games twice")
+	}
+	// a failed merge leaves every career as it was
+	newcomer := NewCareer("aaron")
+	newcomer.Add(&tad.GameReport{Fingerprint: "z"}, &tad.PlayerReport{Name: "Aaron"}, "win")
+	if err := first.Merge(Careers{"aaron": newcomer, "kazik": later["kazik"]}); err == nil {
+		t.Error("merged the same games twice")
+	}
+	if !reflect.DeepEqual(all, first) {
+		t.Errorf("a failed merge changed the careers to %+v", first)
+	}
+	// a rematch in the same lobby is a game of its own
+	rematch := *games[0]
+	rematch.Key = "a2"
+	played := make(Careers)
+	played.Add(games[0], nil)
+	played.Add(&rematch, nil)
+	if c := played["kazik"]; c.Games != 2 {
+		t.Errorf("wanted the rematch added, got %+v", c.Record)
+	}
+	rematches := make(Careers)
+	rematches.Add(&rematch, nil)
+	if err := rematches.Merge(Careers{"kazik": first["kazik"]}); err != nil {
+		t.Errorf("wanted careers with the game and its rematch to merge, got %v", err)
+	}
+
+	c := all["kazik"]
+	if c.Games != 3 || c.WinRate() != 2.0/3 {
+		t.Errorf("wanted 2 wins of 3, got %+v", c.Record)
+	}
+	if r := c.ByMap["Dark Comet"]; r.Games != 2 || r.WinRate() != 0.5 {
+		t.Errorf("unexpected Dark Comet record %+v", r)
+	}
+	if r := c.BySide["arm"]; r.Games != 2 || r.Wins != 2 {
+		t.Errorf("unexpected arm record %+v", r)
+	}
+	if fav := c.FavouriteUnits(1); len(fav) != 1 || fav[0].Name != "ARMZEUS" || fav[0].Produced != 20 || fav[0].KillsPerDeath() != 2 {
+		t.Errorf("unexpected favourite units %+v", fav)
+	}
+	if got := c.CommanderDeathRate(); got != 1.0/3 {
+		t.Errorf("wanted a commander death rate of 1/3, got %v", got)
+	}
+	if got := c.APM(); math.Abs(got-900/(1200002/60000.0)) > 1e-9 {
+		t.Errorf("unexpected APM %v", got)
+	}
+	income := c.AverageIncome()
+	// the first minute averages to 5, 9 and 3 in the three games
+	if len(income) != 2 || math.Abs(income[0].Metal-17/3.0) > 1e-9 || income[1].Metal != 10 {
+		t.Errorf("unexpected income curve %+v", income)
+	}
+	if fez := all["fez"]; fez.Losses != 2 || fez.CommanderDeaths != 2 {
+		t.Errorf("unexpected career of Fez %+v", fez)
+	}
+}
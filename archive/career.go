@@ -0,0 +1,326 @@
+package archive
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cosmouser/tad"
+)
+
+// Record counts the results of games
+type Record struct {
+	Games  int `json:"games"`
+	Wins   int `json:"wins"`
+	Losses int `json:"losses"`
+	Draws  int `json:"draws"`
+}
+
+func (r *Record) add(result string) {
+	r.Games++
+	switch result {
+	case "win":
+		r.Wins++
+	case "loss":
+		r.Losses++
+	default:
+		r.Draws++
+	}
+}
+
+func (r *Record) merge(o Record) {
+	r.Games += o.Games
+	r.Wins += o.Wins
+	r.Losses += o.Losses
+	r.Draws += o.Draws
+}
+
+// WinRate is the share of games that were won
+func (r Record) WinRate() float64 {
+	if r.Games == 0 {
+		return 0
+	}
+	return float64(r.Wins) / float64(r.Games)
+}
+
+// UnitCareer is the totals of a unit type over a player's games
+type UnitCareer struct {
+	NetID          int    `json:"netId"`
+	Name           string `json:"name,omitempty"`
+	Produced       int    `json:"produced"`
+	Kills          int    `json:"kills"`
+	Deaths         int    `json:"deaths"`
+	DamageDealt    int    `json:"damageDealt"`
+	DamageReceived int    `json:"damageReceived"`
+}
+
+// KillsPerDeath is the k/d of the unit type. Without deaths it's the kills.
+func (u UnitCareer) KillsPerDeath() float64 {
+	if u.Deaths == 0 {
+		return float64(u.Kills)
+	}
+	return float64(u.Kills) / float64(u.Deaths)
+}
+
+// IncomeMinute sums a minute of game time of the games that lasted to it
+type IncomeMinute struct {
+	Games  int     `json:"games"`
+	Metal  float64 `json:"metal"`
+	Energy float64 `json:"energy"`
+}
+
+// IncomeSample is the mean income per second over a minute of game time
+type IncomeSample struct {
+	Minute int     `json:"minute"`
+	Metal  float64 `json:"metal"`
+	Energy float64 `json:"energy"`
+}
+
+// Career is the statistics of a player over many games. It only holds sums so careers
+// of different games can be merged as new games arrive.
+type Career struct {
+	Player string `json:"player"`
+	Name   string `json:"name"` // the name of their latest game
+	Record
+	LastPlayed      string             `json:"lastPlayed,omitempty"` // RFC 3339
+	ByMap           map[string]Record  `json:"byMap"`
+	BySide          map[string]Record  `json:"bySide"`
+	Units           map[int]UnitCareer `json:"units"`
+	Income          []IncomeMinute     `json:"income"`
+	Actions         int                `json:"actions"`
+	Milliseconds    int                `json:"milliseconds"` // time played
+	CommanderDeaths int                `json:"commanderDeaths"`
+	Played          map[string]bool    `json:"played"` // game keys
+}
+
+// NewCareer creates an empty career for a player
+func NewCareer(player string) *Career {
+	return &Career{
+		Player: player,
+		ByMap:  make(map[string]Record),
+		BySide: make(map[string]Record),
+		Units:  make(map[int]UnitCareer),
+		Played: make(map[string]bool),
+	}
+}
+
+// Add adds a player's game with its result, which is win, loss or draw. Games already
+// in the career are left out.
+func (c *Career) Add(g *tad.GameReport, p *tad.PlayerReport, result string) {
+	if c.Played[gameKey(g)] {
+		return
+	}
+	c.Played[gameKey(g)] = true
+	if g.Recorded >= c.LastPlayed {
+		c.Name, c.LastPlayed = p.Name, g.Recorded
+	}
+	c.Record.add(result)
+	byMap := c.ByMap[g.Map]
+	byMap.add(result)
+	c.ByMap[g.Map] = byMap
+	bySide := c.BySide[p.Side]
+	bySide.add(result)
+	c.BySide[p.Side] = bySide
+
+	for _, u := range p.Units {
+		uc := c.Units[u.NetID]
+		uc.NetID, uc.Name = u.NetID, u.Name
+		uc.Produced += u.Produced
+		uc.DamageDealt += u.DamageDealt
+		uc.DamageReceived += u.DamageReceived
+		for _, k := range u.Kills {
+			uc.Kills += k.Count
+		}
+		for _, d := range u.Deaths {
+			uc.Deaths += d.Count
+		}
+		c.Units[u.NetID] = uc
+	}
+
+	// the samples of each minute are averaged before they join the career
+	var minutes []IncomeMinute
+	for _, s := range p.ScoreSeries {
+		m := s.Milliseconds / 60000
+		for len(minutes) <= m {
+			minutes = append(minutes, IncomeMinute{})
+		}
+		minutes[m].Games++
+		minutes[m].Metal += s.Metal
+		minutes[m].Energy += s.Energy
+	}
+	for m, sum := range minutes {
+		if sum.Games == 0 {
+			continue
+		}
+		c.Income = addIncome(c.Income, m, IncomeMinute{1, sum.Metal / float64(sum.Games), sum.Energy / float64(sum.Games)})
+	}
+
+	c.Actions += p.Actions
+	if p.TimeToDie > 0 {
+		c.Milliseconds += p.TimeToDie
+	} else {
+		c.Milliseconds += g.Milliseconds
+	}
+	if p.Died {
+		c.CommanderDeaths++
+	}
+}
+
+func addIncome(income []IncomeMinute, minute int, im IncomeMinute) []IncomeMinute {
+	for len(income) <= minute {
+		income = append(income, IncomeMinute{})
+	}
+	income[minute].Games += im.Games
+	income[minute].Metal += im.Metal
+	income[minute].Energy += im.Energy
+	return income
+}
+
+// Merge adds the games of another career of the same player. It fails without changing
+// c when a game is in both.
+func (c *Career) Merge(o *Career) error {
+	for key := range o.Played {
+		if c.Played[key] {
+			return fmt.Errorf("game %v is in both careers of %v", key, c.Player)
+		}
+	}
+	for key := range o.Played {
+		c.Played[key] = true
+	}
+	if o.LastPlayed >= c.LastPlayed {
+		c.Name, c.LastPlayed = o.Name, o.LastPlayed
+	}
+	c.Record.merge(o.Record)
+	for name, r := range o.ByMap {
+		byMap := c.ByMap[name]
+		byMap.merge(r)
+		c.ByMap[name] = byMap
+	}
+	for side, r := range o.BySide {
+		bySide := c.BySide[side]
+		bySide.merge(r)
+		c.BySide[side] = bySide
+	}
+	for netID, u := range o.Units {
+		uc := c.Units[netID]
+		uc.NetID, uc.Name = u.NetID, u.Name
+		uc.Produced += u.Produced
+		uc.Kills += u.Kills
+		uc.Deaths += u.Deaths
+		uc.DamageDealt += u.DamageDealt
+		uc.DamageReceived += u.DamageReceived
+		c.Units[netID] = uc
+	}
+	for m, im := range o.Income {
+		c.Income = addIncome(c.Income, m, im)
+	}
+	c.Actions += o.Actions
+	c.Milliseconds += o.Milliseconds
+	c.CommanderDeaths += o.CommanderDeaths
+	return nil
+}
+
+// APM is the mean actions per minute of game time. See tad.ActionsWorker for what
+// counts as an action.
+func (c *Career) APM() float64 {
+	if c.Milliseconds == 0 {
+		return 0
+	}
+	return float64(c.Actions) / (float64(c.Milliseconds) / 60000)
+}
+
+// CommanderDeathRate is the share of games where the player's commander was destroyed
+func (c *Career) CommanderDeathRate() float64 {
+	if c.Games == 0 {
+		return 0
+	}
+	return float64(c.CommanderDeaths) / float64(c.Games)
+}
+
+// FavouriteUnits gives the n unit types the player built most, or all of them when n
+// is 0
+func (c *Career) FavouriteUnits(n int) []UnitCareer {
+	units := make([]UnitCareer, 0, len(c.Units))
+	for _, u := range c.Units {
+		units = append(units, u)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		if units[i].Produced != units[j].Produced {
+			return units[i].Produced > units[j].Produced
+		}
+		return units[i].NetID < units[j].NetID
+	})
+	if n > 0 && n < len(units) {
+		units = units[:n]
+	}
+	return units
+}
+
+// AverageIncome gives the mean income curve of the player's games
+func (c *Career) AverageIncome() []IncomeSample {
+	samples := make([]IncomeSample, 0, len(c.Income))
+	for m, im := range c.Income {
+		if im.Games == 0 {
+			continue
+		}
+		samples = append(samples, IncomeSample{m, im.Metal / float64(im.Games), im.Energy / float64(im.Games)})
+	}
+	return samples
+}
+
+// Careers is the careers of many players by the key a Ladder's Identify gives them
+type Careers map[string]*Career
+
+// Add adds a game to the careers of its players. identify can be nil to tell players
+// apart by name. Watchers and games with fewer than two teams are left out.
+func (cs Careers) Add(g *tad.GameReport, identify func(g *tad.GameReport, p *tad.PlayerReport) string) {
+	if identify == nil {
+		identify = byName
+	}
+	teams := GameTeams(g)
+	if len(teams) < 2 {
+		return
+	}
+	_, results := teamResults(teams)
+	for i, team := range teams {
+		for _, p := range team {
+			id := identify(g, p)
+			c, ok := cs[id]
+			if !ok {
+				c = NewCareer(id)
+				cs[id] = c
+			}
+			c.Add(g, p, results[i])
+		}
+	}
+}
+
+// Merge adds careers made from other games. It fails without changing cs when a
+// career has a game that is already in cs.
+func (cs Careers) Merge(other Careers) error {
+	ids := make([]string, 0, len(other))
+	for id := range other {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		c, ok := cs[id]
+		if !ok {
+			continue
+		}
+		for fp := range other[id].Played {
+			if c.Played[fp] {
+				return fmt.Errorf("game %v is in both careers of %v", fp, id)
+			}
+		}
+	}
+	for _, id := range ids {
+		c, ok := cs[id]
+		if !ok {
+			c = NewCareer(id)
+			cs[id] = c
+		}
+		// the games were checked above so this can't fail
+		c.Merge(other[id])
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cosmouser/tad"
+	bolt "go.etcd.io/bbolt"
+)
+
+// buckets of a DB. Games are kept by game key and each index bucket has keys of an
+// indexed value, a zero byte and the game key of a game with that value.
+var (
+	gamesBucket       = []byte("games")
+	playerBucket      = []byte("player")
+	tdpidBucket       = []byte("tdpid")
+	mapBucket         = []byte("map")
+	unitsumBucket     = []byte("unitsum")
+	dateBucket        = []byte("date")
+	fingerprintBucket = []byte("fingerprint")
+	indexBuckets      = [][]byte{playerBucket, tdpidBucket, mapBucket, unitsumBucket, dateBucket, fingerprintBucket}
+)
+
+// ErrNotFound is returned by DB.Get for games that aren't in the database
+var ErrNotFound = errors.New("game not found")
+
+// DB is a file of GameReports keyed by game key with indexes on player name, TDPID,
+// map, unitsum, recording date and fingerprint. It needs no outside service.
+type DB struct {
+	bolt *bolt.DB
+}
+
+// OpenDB opens the database at path, creating it when it doesn't exist
+func OpenDB(path string) (*DB, error) {
+	bdb, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = bdb.Update(func(tx *bolt.Tx) error {
+		for _, name := range append([][]byte{gamesBucket}, indexBuckets...) {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		bdb.Close()
+		return nil, err
+	}
+	return &DB{bdb}, nil
+}
+
+// Close closes the database file
+func (db *DB) Close() error {
+	return db.bolt.Close()
+}
+
+// gameKey gives the key of a game. Reports from before there were keys fall back to
+// the fingerprint.
+func gameKey(g *tad.GameReport) string {
+	if g.Key != "" {
+		return g.Key
+	}
+	return g.Fingerprint
+}
+
+// indexKeys gives the keys of a game in each index bucket. Names and maps are
+// matched without case.
+func indexKeys(g *tad.GameReport) map[string][][]byte {
+	key := func(value []byte) []byte {
+		return append(append(value, 0), gameKey(g)...)
+	}
+	keys := make(map[string][][]byte)
+	for _, p := range g.Players {
+		keys[string(playerBucket)] = append(keys[string(playerBucket)], key([]byte(strings.ToLower(p.Name))))
+		keys[string(tdpidBucket)] = append(keys[string(tdpidBucket)], key(tdpidKey(p.TDPID)))
+	}
+	keys[string(mapBucket)] = [][]byte{key([]byte(strings.ToLower(g.Map)))}
+	keys[string(unitsumBucket)] = [][]byte{key([]byte(g.Unitsum))}
+	keys[string(fingerprintBucket)] = [][]byte{key([]byte(g.Fingerprint))}
+	if recorded, ok := recordedTime(g); ok {
+		keys[string(dateBucket)] = [][]byte{key(dateKey(recorded))}
+	}
+	return keys
+}
+
+func tdpidKey(tdpid int32) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(tdpid))
+}
+
+// dateKey sorts like the time it's made from
+func dateKey(t time.Time) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(t.Unix())^1<<63)
+}
+
+func recordedTime(g *tad.GameReport) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, g.Recorded)
+	return t, err == nil
+}
+
+// Put adds a game or replaces the one with the same game key. Rematches in the same
+// lobby share a fingerprint and are kept apart.
+func (db *DB) Put(g *tad.GameReport) error {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		games := tx.Bucket(gamesBucket)
+		if old := games.Get([]byte(gameKey(g))); old != nil {
+			var prev tad.GameReport
+			if err := json.Unmarshal(old, &prev); err != nil {
+				return err
+			}
+			for bucket, keys := range indexKeys(&prev) {
+				for _, k := range keys {
+					if err := tx.Bucket([]byte(bucket)).Delete(k); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		for bucket, keys := range indexKeys(g) {
+			for _, k := range keys {
+				if err := tx.Bucket([]byte(bucket)).Put(k, nil); err != nil {
+					return err
+				}
+			}
+		}
+		return games.Put([]byte(gameKey(g)), data)
+	})
+}
+
+// Import puts the games of an index into the database, skipping failures and
+// duplicates, and returns how many it put
+func (db *DB) Import(entries []Entry) (n int, err error) {
+	for i := range entries {
+		if entries[i].Report == nil {
+			continue
+		}
+		if err := db.Put(entries[i].Report); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Get returns the game with a game key. Query.Fingerprint finds the games of a lobby.
+func (db *DB) Get(key string) (g *tad.GameReport, err error) {
+	err = db.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(gamesBucket).Get([]byte(key))
+		if data == nil {
+			return ErrNotFound
+		}
+		g = new(tad.GameReport)
+		return json.Unmarshal(data, g)
+	})
+	return
+}
+
+// Query picks games from a DB. A game has to match every field that is set: all of
+// Players and TDPIDs have to be in it and it has to be recorded between From and To.
+type Query struct {
+	Players     []string
+	TDPIDs      []int32
+	Map         string
+	Unitsum     string
+	Fingerprint string
+	From        time.Time // zero means no start
+	To          time.Time // zero means no end
+}
+
+// Find returns the games matching q by recording date
+func (db *DB) Find(q Query) (games []*tad.GameReport, err error) {
+	err = db.bolt.View(func(tx *bolt.Tx) error {
+		var matches map[string]bool
+		narrow := func(keys map[string]bool) {
+			if matches == nil {
+				matches = keys
+				return
+			}
+			for key := range matches {
+				if !keys[key] {
+					delete(matches, key)
+				}
+			}
+		}
+		for _, name := range q.Players {
+			narrow(scanPrefix(tx.Bucket(playerBucket), []byte(strings.ToLower(name))))
+		}
+		for _, tdpid := range q.TDPIDs {
+			narrow(scanPrefix(tx.Bucket(tdpidBucket), tdpidKey(tdpid)))
+		}
+		if q.Map != "" {
+			narrow(scanPrefix(tx.Bucket(mapBucket), []byte(strings.ToLower(q.Map))))
+		}
+		if q.Unitsum != "" {
+			narrow(scanPrefix(tx.Bucket(unitsumBucket), []byte(q.Unitsum)))
+		}
+		if q.Fingerprint != "" {
+			narrow(scanPrefix(tx.Bucket(fingerprintBucket), []byte(q.Fingerprint)))
+		}
+		if !q.From.IsZero() || !q.To.IsZero() {
+			narrow(scanDates(tx.Bucket(dateBucket), q.From, q.To))
+		}
+		gb := tx.Bucket(gamesBucket)
+		add := func(data []byte) error {
+			g := new(tad.GameReport)
+			if err := json.Unmarshal(data, g); err != nil {
+				return err
+			}
+			games = append(games, g)
+			return nil
+		}
+		if matches == nil {
+			return gb.ForEach(func(_, data []byte) error { return add(data) })
+		}
+		for key := range matches {
+			if err := add(gb.Get([]byte(key))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	sort.Slice(games, func(i, j int) bool {
+		if games[i].Recorded != games[j].Recorded {
+			return games[i].Recorded < games[j].Recorded
+		}
+		return gameKey(games[i]) < gameKey(games[j])
+	})
+	return
+}
+
+// scanPrefix gives the game keys of the index keys for one indexed value
+func scanPrefix(b *bolt.Bucket, value []byte) map[string]bool {
+	keys := make(map[string]bool)
+	prefix := append(value, 0)
+	c := b.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys[string(k[len(prefix):])] = true
+	}
+	return keys
+}
+
+// scanDates gives the game keys of the games recorded between from and to
+func scanDates(b *bolt.Bucket, from, to time.Time) map[string]bool {
+	keys := make(map[string]bool)
+	c := b.Cursor()
+	k, _ := c.First()
+	if !from.IsZero() {
+		k, _ = c.Seek(dateKey(from))
+	}
+	for ; k != nil; k, _ = c.Next() {
+		if !to.IsZero() && bytes.Compare(k[:8], dateKey(to)) > 0 {
+			break
+		}
+		keys[string(k[9:])] = true
+	}
+	return keys
+}
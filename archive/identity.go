@@ -0,0 +1,341 @@
+package archive
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cosmouser/tad"
+)
+
+// AppearanceKey is a player of a game by the game key and the player's number
+type AppearanceKey struct {
+	Game   string `json:"game"`
+	Number int    `json:"number"`
+}
+
+func (k AppearanceKey) String() string {
+	return fmt.Sprintf("%v/%d", k.Game, k.Number)
+}
+
+// Appearance is a player of one game
+type Appearance struct {
+	AppearanceKey
+	Name     string    `json:"name"`
+	TDPID    int32     `json:"tdpid"`
+	IP       string    `json:"ip,omitempty"`
+	Recorded time.Time `json:"recorded"`
+}
+
+// EvidenceKind is what two appearances have in common
+type EvidenceKind int
+
+// EvidenceKind values of Evidence
+const (
+	SameName EvidenceKind = iota
+	SameTDPID
+	SameIP
+	ManualMerge
+)
+
+func (k EvidenceKind) String() string {
+	switch k {
+	case SameName:
+		return "same name"
+	case SameTDPID:
+		return "same tdpid"
+	case SameIP:
+		return "same ip"
+	case ManualMerge:
+		return "manual merge"
+	}
+	return fmt.Sprintf("evidence(%d)", int(k))
+}
+
+// evidenceWeights are how sure each kind of evidence alone makes a link
+var evidenceWeights = map[EvidenceKind]float64{
+	SameName:    0.6,
+	SameTDPID:   0.5,
+	SameIP:      0.3,
+	ManualMerge: 1,
+}
+
+// Evidence is a reason to link two appearances
+type Evidence struct {
+	Kind  EvidenceKind `json:"kind"`
+	Value string       `json:"value"`
+}
+
+// Link joins two appearances of an identity. Confidence is from 0 to 1.
+type Link struct {
+	A, B       AppearanceKey
+	Confidence float64
+	Evidence   []Evidence
+}
+
+// Identity is a person found across games. ID is the key of their earliest appearance
+// so it stays the same as newer games are added.
+type Identity struct {
+	ID          string
+	Names       []string
+	Appearances []Appearance
+	Links       []Link
+}
+
+// OverrideKind is a manual decision about two appearances
+type OverrideKind int
+
+// OverrideKind values of Override
+const (
+	MergeOverride OverrideKind = iota
+	SplitOverride
+)
+
+func (k OverrideKind) String() string {
+	if k == SplitOverride {
+		return "split"
+	}
+	return "merge"
+}
+
+// Override is a manual merge or split. The overrides of a Resolver are its audit trail.
+type Override struct {
+	Kind   OverrideKind  `json:"kind"`
+	A      AppearanceKey `json:"a"`
+	B      AppearanceKey `json:"b"`
+	Reason string        `json:"reason"`
+	By     string        `json:"by"`
+	Time   time.Time     `json:"time"`
+}
+
+// defaultMinEvidence is the kinds of evidence a link needs when a Resolver has no
+// MinEvidence. One isn't enough as strangers often share a name.
+const defaultMinEvidence = 2
+
+// Resolver links the players of many games into identities. Appearances that share a
+// name, TDPID or IP are linked with a confidence from what they share. IPs that two
+// players of one game shared don't count and players of the same game are never linked.
+// A player seen under one name without a TDPID or IP to back it up is left unlinked,
+// which Identified rates by name.
+type Resolver struct {
+	MinEvidence int     // kinds of evidence a link needs, 0 means 2
+	Threshold   float64 // confidence a link needs as well, 0 means any
+	Overrides   []Override
+	appearances []Appearance
+	sharedIPs   map[string]bool
+}
+
+// NewResolver creates a Resolver with earlier overrides, which can be nil
+func NewResolver(overrides []Override) *Resolver {
+	return &Resolver{Overrides: overrides, sharedIPs: make(map[string]bool)}
+}
+
+// Add adds the players of a game
+func (r *Resolver) Add(g *tad.GameReport) {
+	recorded, _ := recordedTime(g)
+	ips := make(map[string]int)
+	for _, p := range g.Players {
+		r.appearances = append(r.appearances, Appearance{
+			AppearanceKey: AppearanceKey{gameKey(g), p.Number},
+			Name:          p.Name,
+			TDPID:         p.TDPID,
+			IP:            p.IP,
+			Recorded:      recorded,
+		})
+		if p.IP != "" {
+			ips[p.IP]++
+		}
+	}
+	for ip, n := range ips {
+		if n > 1 {
+			r.sharedIPs[ip] = true
+		}
+	}
+}
+
+// Merge records that two appearances are the same person
+func (r *Resolver) Merge(a, b AppearanceKey, reason, by string) {
+	r.Overrides = append(r.Overrides, Override{MergeOverride, a, b, reason, by, time.Now().UTC()})
+}
+
+// Split records that two appearances are different people. It undoes earlier merges
+// of the two.
+func (r *Resolver) Split(a, b AppearanceKey, reason, by string) {
+	r.Overrides = append(r.Overrides, Override{SplitOverride, a, b, reason, by, time.Now().UTC()})
+}
+
+// evidence gives what two appearances have in common and how sure it makes a link
+func (r *Resolver) evidence(a, b *Appearance) (ev []Evidence, confidence float64) {
+	if a.Name != "" && strings.EqualFold(a.Name, b.Name) {
+		ev = append(ev, Evidence{SameName, a.Name})
+	}
+	if a.TDPID != 0 && a.TDPID == b.TDPID {
+		ev = append(ev, Evidence{SameTDPID, fmt.Sprint(a.TDPID)})
+	}
+	if a.IP != "" && a.IP == b.IP && !r.sharedIPs[a.IP] {
+		ev = append(ev, Evidence{SameIP, a.IP})
+	}
+	// each piece of evidence is treated as an independent chance the link is right
+	doubt := 1.0
+	for _, e := range ev {
+		doubt *= 1 - evidenceWeights[e.Kind]
+	}
+	return ev, 1 - doubt
+}
+
+// Identities works out the identities of every appearance added so far. Each appearance
+// is compared with the latest earlier one sharing its name, its TDPID or its IP and the
+// links are made from the most confident down.
+func (r *Resolver) Identities() []Identity {
+	minEvidence := r.MinEvidence
+	if minEvidence <= 0 {
+		minEvidence = defaultMinEvidence
+	}
+	apps := append([]Appearance(nil), r.appearances...)
+	sort.SliceStable(apps, func(i, j int) bool {
+		if !apps[i].Recorded.Equal(apps[j].Recorded) {
+			return apps[i].Recorded.Before(apps[j].Recorded)
+		}
+		if apps[i].Game != apps[j].Game {
+			return apps[i].Game < apps[j].Game
+		}
+		return apps[i].Number < apps[j].Number
+	})
+	index := make(map[AppearanceKey]int, len(apps))
+	for i := range apps {
+		index[apps[i].AppearanceKey] = i
+	}
+
+	// the latest override of each pair wins
+	type pair struct{ a, b AppearanceKey }
+	latestOverride := make(map[pair]int)
+	for i, o := range r.Overrides {
+		if o.B.String() < o.A.String() {
+			o.A, o.B = o.B, o.A
+		}
+		latestOverride[pair{o.A, o.B}] = i
+	}
+	var decisions []Override
+	for i, o := range r.Overrides {
+		if o.B.String() < o.A.String() {
+			o.A, o.B = o.B, o.A
+		}
+		if latestOverride[pair{o.A, o.B}] == i {
+			decisions = append(decisions, o)
+		}
+	}
+	var candidates []Link
+	for _, o := range decisions {
+		if o.Kind == MergeOverride {
+			candidates = append(candidates, Link{o.A, o.B, 1, []Evidence{{ManualMerge, o.Reason}}})
+		}
+	}
+	latest := make(map[string]int)
+	for i := range apps {
+		a := &apps[i]
+		seen := make(map[int]bool)
+		for _, key := range []string{"name:" + strings.ToLower(a.Name), fmt.Sprint("tdpid:", a.TDPID), "ip:" + a.IP} {
+			if j, ok := latest[key]; ok && !seen[j] {
+				seen[j] = true
+				if ev, confidence := r.evidence(&apps[j], a); len(ev) >= minEvidence && confidence >= r.Threshold {
+					candidates = append(candidates, Link{apps[j].AppearanceKey, a.AppearanceKey, confidence, ev})
+				}
+			}
+			latest[key] = i
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Confidence > candidates[j].Confidence })
+
+	// union find over appearances, keeping the games and splits of each group
+	parent := make([]int, len(apps))
+	games := make([]map[string]bool, len(apps))
+	for i := range apps {
+		parent[i] = i
+		games[i] = map[string]bool{apps[i].Game: true}
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	var splits [][2]int
+	for _, o := range decisions {
+		i, iok := index[o.A]
+		j, jok := index[o.B]
+		if o.Kind == SplitOverride && iok && jok {
+			splits = append(splits, [2]int{i, j})
+		}
+	}
+	canJoin := func(a, b int) bool {
+		for g := range games[b] {
+			if games[a][g] {
+				return false
+			}
+		}
+		for _, s := range splits {
+			x, y := find(s[0]), find(s[1])
+			if (x == a && y == b) || (x == b && y == a) {
+				return false
+			}
+		}
+		return true
+	}
+	var links []Link
+	for _, l := range candidates {
+		i, iok := index[l.A]
+		j, jok := index[l.B]
+		if !iok || !jok {
+			continue
+		}
+		a, b := find(i), find(j)
+		if a == b || !canJoin(a, b) {
+			continue
+		}
+		// the earliest appearance stays the root so the identity's ID doesn't change
+		if b < a {
+			a, b = b, a
+		}
+		parent[b] = a
+		for g := range games[b] {
+			games[a][g] = true
+		}
+		links = append(links, l)
+	}
+
+	byRoot := make(map[int]*Identity)
+	var identities []*Identity
+	for i := range apps {
+		root := find(i)
+		id, ok := byRoot[root]
+		if !ok {
+			id = &Identity{ID: apps[root].AppearanceKey.String()}
+			byRoot[root] = id
+			identities = append(identities, id)
+		}
+		id.Appearances = append(id.Appearances, apps[i])
+		if !containsFold(id.Names, apps[i].Name) {
+			id.Names = append(id.Names, apps[i].Name)
+		}
+	}
+	for _, l := range links {
+		id := byRoot[find(index[l.A])]
+		id.Links = append(id.Links, l)
+	}
+	out := make([]Identity, len(identities))
+	for i, id := range identities {
+		out[i] = *id
+	}
+	return out
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,373 @@
+// Package archive works with collections of recordings. An Indexer analyzes
+// directories of demos into an index file of GameReports and a DB stores them for
+// queries by player, map, unitsum and date. A Resolver links the players of those games
+// into identities, a Ladder rates them and Careers sums up their games.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cosmouser/tad"
+)
+
+// ErrorKind is why a recording couldn't be indexed
+type ErrorKind int
+
+// ErrorKind values of IndexError
+const (
+	UnreadableError ErrorKind = iota
+	MalformedError
+	TimeoutError
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case UnreadableError:
+		return "unreadable"
+	case MalformedError:
+		return "malformed"
+	case TimeoutError:
+		return "timeout"
+	}
+	return fmt.Sprintf("errorkind(%d)", int(k))
+}
+
+// IndexError is a recording that failed to be indexed
+type IndexError struct {
+	Path string
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *IndexError) Error() string {
+	return fmt.Sprintf("%v: %v: %v", e.Path, e.Kind, e.Err)
+}
+
+func (e *IndexError) Unwrap() error {
+	return e.Err
+}
+
+// Entry is one recording of an index file. Paths are relative to the indexed directory.
+// Duplicates have the path of the first copy of the game and no report.
+type Entry struct {
+	Path        string          `json:"path"`
+	Size        int64           `json:"size"`
+	ModTime     time.Time       `json:"modTime"`
+	Hash        string          `json:"hash"` // sha256 of the file
+	Fingerprint string          `json:"fingerprint,omitempty"`
+	Key         string          `json:"key,omitempty"` // see tad.Game.GetGameKey
+	DuplicateOf string          `json:"duplicateOf,omitempty"`
+	Report      *tad.GameReport `json:"report,omitempty"`
+	ErrorKind   string          `json:"errorKind,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	Indexed     time.Time       `json:"indexed"`
+}
+
+// Failed reports whether the recording couldn't be analyzed
+func (e *Entry) Failed() bool {
+	return e.ErrorKind != ""
+}
+
+// IndexStats counts what happened to the recordings of an Index run
+type IndexStats struct {
+	Indexed    int
+	Skipped    int // already in the index
+	Duplicates int
+	Failed     int
+}
+
+// defaultIndexTimeout is how long an Indexer spends on a file when it has no Timeout
+const defaultIndexTimeout = 120 * time.Second
+
+// Indexer analyzes every .ted file under a directory into an index file
+type Indexer struct {
+	Workers     int           // files analyzed at once, 0 means one per CPU
+	Timeout     time.Duration // per file, 0 means two minutes
+	UnitNames   map[uint16]string
+	RetryFailed bool              // analyze files that failed in an earlier run again
+	Progress    func(e *Entry)    // called after each file when set
+	claims      map[string]string // hashes and game keys to the path of their first file
+	mu          sync.Mutex
+}
+
+// Index walks root and appends an entry to the index file at indexPath for each
+// recording not in it yet. Files are matched to the entries of earlier runs by path,
+// size and modification time so an interrupted run carries on where it stopped. A file
+// that changed since is indexed again and its new entry replaces the old one.
+// Recordings with the content hash or game key of an indexed one are duplicates.
+func (ix *Indexer) Index(ctx context.Context, root, indexPath string) (stats IndexStats, err error) {
+	entries, err := LoadIndex(indexPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return stats, err
+	}
+	ix.claims = make(map[string]string)
+	done := make(map[string]*Entry)
+	for i := range entries {
+		e := &entries[i]
+		if e.Failed() && ix.RetryFailed {
+			continue
+		}
+		done[e.Path] = e
+		if !e.Failed() && e.DuplicateOf == "" {
+			ix.claims[e.Hash] = e.Path
+			if key := e.gameKey(); key != "" {
+				ix.claims[key] = e.Path
+			}
+		}
+	}
+	out, err := openIndex(indexPath)
+	if err != nil {
+		return stats, err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	jobs := make(chan string)
+	results := make(chan *Entry)
+	workers := ix.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for rel := range jobs {
+				results <- ix.indexFile(ctx, root, rel)
+			}
+		}()
+	}
+	walkErr := make(chan error, 1)
+	go func() {
+		defer close(jobs)
+		walkErr <- filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".ted") {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if e, ok := done[rel]; ok {
+				if info, err := d.Info(); err == nil && info.Size() == e.Size && info.ModTime().Equal(e.ModTime) {
+					stats.Skipped++
+					return nil
+				}
+				// the file changed so it mustn't be a duplicate of its old self
+				ix.release(e)
+			}
+			select {
+			case jobs <- rel:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	enc := json.NewEncoder(out)
+	for e := range results {
+		if e == nil {
+			continue
+		}
+		if err == nil {
+			err = enc.Encode(e)
+		}
+		switch {
+		case e.Failed():
+			stats.Failed++
+		case e.DuplicateOf != "":
+			stats.Duplicates++
+		default:
+			stats.Indexed++
+		}
+		if ix.Progress != nil {
+			ix.Progress(e)
+		}
+	}
+	if werr := <-walkErr; err == nil {
+		err = werr
+	}
+	return stats, err
+}
+
+// claim gives the path of the file that already has key, or records path as its owner
+func (ix *Indexer) claim(key, path string) (owner string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if owner, ok := ix.claims[key]; ok {
+		return owner
+	}
+	ix.claims[key] = path
+	return ""
+}
+
+// release drops the claims of an entry that is indexed again
+func (ix *Indexer) release(e *Entry) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for _, key := range []string{e.Hash, e.gameKey()} {
+		if ix.claims[key] == e.Path {
+			delete(ix.claims, key)
+		}
+	}
+}
+
+func (ix *Indexer) claimed(key string) string {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.claims[key]
+}
+
+// indexFile analyzes one recording. Failures are kept in the entry. It gives nil when
+// ctx is done so the file is left for the next run.
+func (ix *Indexer) indexFile(ctx context.Context, root, rel string) *Entry {
+	e := &Entry{Path: rel}
+	fail := func(kind ErrorKind, err error) *Entry {
+		e.ErrorKind, e.Error = kind.String(), (&IndexError{rel, kind, err}).Error()
+		e.Report = nil
+		e.Indexed = time.Now().UTC()
+		return e
+	}
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	info, err := os.Stat(path)
+	if err != nil {
+		return fail(UnreadableError, err)
+	}
+	e.Size, e.ModTime = info.Size(), info.ModTime()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fail(UnreadableError, err)
+	}
+	sum := sha256.Sum256(data)
+	e.Hash = hex.EncodeToString(sum[:])
+	e.Indexed = time.Now().UTC()
+	if owner := ix.claimed(e.Hash); owner != "" {
+		e.DuplicateOf = owner
+		return e
+	}
+	timeout := ix.Timeout
+	if timeout <= 0 {
+		timeout = defaultIndexTimeout
+	}
+	fctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	gp, prs, err := tad.Analyze(fctx, bytes.NewReader(data))
+	if err != nil {
+		return fail(MalformedError, err)
+	}
+	e.Fingerprint, e.Key = gp.GetFingerprint(), gp.GetGameKey()
+	if owner := ix.claimed(e.Key); owner != "" {
+		// stops the packet stream
+		cancel()
+		e.DuplicateOf = owner
+		return e
+	}
+	e.Report, err = tad.GameReportWorker(prs, gp, ix.UnitNames)
+	if ctx.Err() != nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fail(TimeoutError, err)
+	}
+	if err != nil {
+		return fail(MalformedError, err)
+	}
+	e.Indexed = time.Now().UTC()
+	// another copy of the game may have finished first
+	if owner := ix.claim(e.Hash, rel); owner != "" {
+		e.DuplicateOf, e.Report = owner, nil
+		return e
+	}
+	if owner := ix.claim(e.Key, rel); owner != "" {
+		e.DuplicateOf, e.Report = owner, nil
+	}
+	return e
+}
+
+// gameKey gives the key of the entry's game like the gameKey of its report
+func (e *Entry) gameKey() string {
+	if e.Key != "" {
+		return e.Key
+	}
+	return e.Fingerprint
+}
+
+// LoadIndex reads the entries of an index file. A path indexed again by a later run
+// gets its last entry. A last line cut short by an interrupted run is ignored.
+func LoadIndex(indexPath string) ([]Entry, error) {
+	f, err := os.Open(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []Entry
+	seen := make(map[string]int)
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// without a newline the last entry wasn't finished
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var e Entry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return nil, fmt.Errorf("%v:%d: %v", indexPath, line, err)
+		}
+		if i, ok := seen[e.Path]; ok {
+			entries[i] = e
+			continue
+		}
+		seen[e.Path] = len(entries)
+		entries = append(entries, e)
+	}
+}
+
+// openIndex opens an index file for appending, cutting off an unfinished last line
+func openIndex(indexPath string) (*os.File, error) {
+	f, err := os.OpenFile(indexPath, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err == nil {
+		err = f.Truncate(int64(bytes.LastIndexByte(data, '\n') + 1))
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekEnd)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,267 @@
+package archive
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cosmouser/tad"
+)
+
+// defaults of a Ladder
+const (
+	defaultK       = 32
+	defaultInitial = 1500
+)
+
+// RatingPoint is a player's rating after a game
+type RatingPoint struct {
+	Game     string    `json:"game"` // game key
+	Recorded time.Time `json:"recorded"`
+	Rating   float64   `json:"rating"`
+	Change   float64   `json:"change"`
+	Result   string    `json:"result"` // win, loss or draw
+}
+
+// PlayerRating is a player of a Ladder
+type PlayerRating struct {
+	Player  string        `json:"player"`
+	Name    string        `json:"name"` // the name of their latest game
+	Rating  float64       `json:"rating"`
+	Games   int           `json:"games"`
+	Wins    int           `json:"wins"`
+	Losses  int           `json:"losses"`
+	Draws   int           `json:"draws"`
+	History []RatingPoint `json:"history"`
+}
+
+// Ladder rates players with Elo from the outcomes of their games. Teams are rated by
+// the mean of their players and every player of a team gets the team's change. Games
+// with more than two teams are scored as a match between each pair of teams.
+type Ladder struct {
+	K       float64 // largest change of a game, 0 means 32
+	Initial float64 // rating of a new player, 0 means 1500
+	// Identify gives the player a PlayerReport belongs to. nil means their name
+	// without case.
+	Identify func(g *tad.GameReport, p *tad.PlayerReport) string
+	players  map[string]*PlayerRating
+}
+
+// Identified gives a Ladder.Identify that rates players by the identities of a Resolver.
+// Players without one or alone in theirs are rated by name, so a name that was never
+// linked still has one row.
+func Identified(identities []Identity) func(g *tad.GameReport, p *tad.PlayerReport) string {
+	ids := make(map[AppearanceKey]string)
+	for _, id := range identities {
+		if len(id.Links) == 0 {
+			continue
+		}
+		for _, a := range id.Appearances {
+			ids[a.AppearanceKey] = id.ID
+		}
+	}
+	return func(g *tad.GameReport, p *tad.PlayerReport) string {
+		if id, ok := ids[AppearanceKey{gameKey(g), p.Number}]; ok {
+			return id
+		}
+		return byName(g, p)
+	}
+}
+
+// byName identifies players by their name without case
+func byName(_ *tad.GameReport, p *tad.PlayerReport) string {
+	return strings.ToLower(p.Name)
+}
+
+// GameTeams splits the players of a game into teams, leaving out watchers. The players
+// allied with the one who recorded the game are a team against the rest and without
+// allies every player is on their own.
+func GameTeams(g *tad.GameReport) [][]*tad.PlayerReport {
+	var recorders, others [][]*tad.PlayerReport
+	allied := false
+	for i := range g.Players {
+		p := &g.Players[i]
+		if p.Side == "watcher" {
+			continue
+		}
+		if p.Number == 1 || p.Allied {
+			recorders = append(recorders, []*tad.PlayerReport{p})
+			allied = allied || p.Allied
+		} else {
+			others = append(others, []*tad.PlayerReport{p})
+		}
+	}
+	if !allied {
+		return append(recorders, others...)
+	}
+	teams := make([][]*tad.PlayerReport, 2)
+	for _, t := range recorders {
+		teams[0] = append(teams[0], t...)
+	}
+	for _, t := range others {
+		teams[1] = append(teams[1], t...)
+	}
+	if len(teams[1]) == 0 {
+		return teams[:1]
+	}
+	return teams
+}
+
+// teamResults gives how long each team lasted, which is until its last player died,
+// and whether it won, lost or drew. Teams that outlast the rest win and teams that last
+// as long as another winner draw.
+func teamResults(teams [][]*tad.PlayerReport) (times []int, results []string) {
+	times = make([]int, len(teams))
+	best := 0
+	for i, team := range teams {
+		for _, p := range team {
+			if p.TimeToDie > times[i] {
+				times[i] = p.TimeToDie
+			}
+		}
+		if times[i] > best {
+			best = times[i]
+		}
+	}
+	winners := 0
+	for _, t := range times {
+		if t == best {
+			winners++
+		}
+	}
+	results = make([]string, len(teams))
+	for i, t := range times {
+		switch {
+		case t == best && winners == 1:
+			results[i] = "win"
+		case t == best:
+			results[i] = "draw"
+		default:
+			results[i] = "loss"
+		}
+	}
+	return
+}
+
+// Rebuild rates games from the start, in order of recording date and then game key, so
+// the same games always give the same ladder. Games with fewer than two teams and
+// further copies of a game key aren't rated.
+func (l *Ladder) Rebuild(games []*tad.GameReport) {
+	games = append([]*tad.GameReport(nil), games...)
+	sort.SliceStable(games, func(i, j int) bool {
+		if games[i].Recorded != games[j].Recorded {
+			return games[i].Recorded < games[j].Recorded
+		}
+		return gameKey(games[i]) < gameKey(games[j])
+	})
+	l.players = make(map[string]*PlayerRating)
+	rated := make(map[string]bool)
+	for _, g := range games {
+		if rated[gameKey(g)] {
+			continue
+		}
+		rated[gameKey(g)] = true
+		l.rate(g)
+	}
+}
+
+// rate applies the outcome of one game
+func (l *Ladder) rate(g *tad.GameReport) {
+	teams := GameTeams(g)
+	if len(teams) < 2 {
+		return
+	}
+	k, initial := l.K, l.Initial
+	if k <= 0 {
+		k = defaultK
+	}
+	if initial <= 0 {
+		initial = defaultInitial
+	}
+	identify := l.Identify
+	if identify == nil {
+		identify = byName
+	}
+	recorded, _ := recordedTime(g)
+
+	members := make([][]*PlayerRating, len(teams))
+	ratings := make([]float64, len(teams))
+	times, results := teamResults(teams)
+	for i, team := range teams {
+		for _, p := range team {
+			id := identify(g, p)
+			pr, ok := l.players[id]
+			if !ok {
+				pr = &PlayerRating{Player: id, Rating: initial}
+				l.players[id] = pr
+			}
+			pr.Name = p.Name
+			members[i] = append(members[i], pr)
+			ratings[i] += pr.Rating
+		}
+		ratings[i] /= float64(len(team))
+	}
+	// all changes come from the ratings before the game
+	changes := make([]float64, len(teams))
+	for i := range teams {
+		for j := range teams {
+			if i == j {
+				continue
+			}
+			expected := 1 / (1 + math.Pow(10, (ratings[j]-ratings[i])/400))
+			score := 0.5
+			if times[i] > times[j] {
+				score = 1
+			} else if times[i] < times[j] {
+				score = 0
+			}
+			changes[i] += k * (score - expected) / float64(len(teams)-1)
+		}
+	}
+	for i := range teams {
+		for _, pr := range members[i] {
+			pr.Rating += changes[i]
+			pr.Games++
+			switch results[i] {
+			case "win":
+				pr.Wins++
+			case "loss":
+				pr.Losses++
+			default:
+				pr.Draws++
+			}
+			pr.History = append(pr.History, RatingPoint{
+				Game:     gameKey(g),
+				Recorded: recorded,
+				Rating:   pr.Rating,
+				Change:   changes[i],
+				Result:   results[i],
+			})
+		}
+	}
+}
+
+// Standings gives every rated player from the highest rating down
+func (l *Ladder) Standings() []PlayerRating {
+	out := make([]PlayerRating, 0, len(l.players))
+	for _, pr := range l.players {
+		out = append(out, *pr)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Rating != out[j].Rating {
+			return out[i].Rating > out[j].Rating
+		}
+		return out[i].Player < out[j].Player
+	})
+	return out
+}
+
+// Player gives the rating of a player by their Identify key
+func (l *Ladder) Player(id string) (PlayerRating, bool) {
+	pr, ok := l.players[id]
+	if !ok {
+		return PlayerRating{}, false
+	}
+	return *pr, true
+}
@@ -0,0 +1,187 @@
+package tad
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"math"
+	"sort"
+
+	"github.com/fogleman/gg"
+)
+
+// cameraViewport is the size in game pixels of the area a player sees at 640x480
+var cameraViewport = image.Pt(640, 480)
+
+// baseRadius is how far in game pixels from a player's start position still counts as their base
+const baseRadius = 1024
+
+// CameraPosition is where a player moved their screen to. X and Y are the top left
+// of the screen in game pixels.
+type CameraPosition struct {
+	Owner int
+	X     int
+	Y     int
+	Time  int // milliseconds
+}
+
+// Attention is how many milliseconds a player spent looking at each part of the map.
+// Front is time with another player's units in view, Expansion is time with their own
+// buildings in view away from their base and Other is everything else.
+type Attention struct {
+	Base      int
+	Front     int
+	Expansion int
+	Other     int
+}
+
+// Total returns the milliseconds of attention that were counted
+func (a *Attention) Total() int {
+	return a.Base + a.Front + a.Expansion + a.Other
+}
+
+// Shares returns the fraction of time spent looking at the base, front line and expansions
+func (a *Attention) Shares() (base, front, expansion float64) {
+	total := float64(a.Total())
+	if total == 0 {
+		return
+	}
+	return float64(a.Base) / total, float64(a.Front) / total, float64(a.Expansion) / total
+}
+
+// CameraReport has each player's screen positions in time order and where their attention went.
+// Both are keyed by player number.
+type CameraReport struct {
+	Tracks    map[int][]CameraPosition
+	Attention map[int]*Attention
+}
+
+// cameraFromPacket reads a 0xfc packet. ok is false for other packets.
+func cameraFromPacket(pr PacketRec, clock int) (cp CameraPosition, ok bool, err error) {
+	if pr.Data[0] != 0xfc {
+		return
+	}
+	tmp := &packet0xfc{}
+	if err = binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+		return
+	}
+	return CameraPosition{
+		Owner: int(pr.Sender),
+		X:     int(tmp.XPos),
+		Y:     int(tmp.YPos),
+		Time:  clock,
+	}, true, nil
+}
+
+// inView reports whether a game position is on the screen
+func (cp *CameraPosition) inView(x, y int) bool {
+	return x >= cp.X && x < cp.X+cameraViewport.X && y >= cp.Y && y < cp.Y+cameraViewport.Y
+}
+
+// attend adds the milliseconds the player spent at a camera position to their attention
+func (a *Attention) attend(cp CameraPosition, start image.Point, hasStart bool, units map[uint16]*TAUnit, ms int) {
+	cx, cy := cp.X+cameraViewport.X/2, cp.Y+cameraViewport.Y/2
+	if hasStart && math.Hypot(float64(cx-start.X), float64(cy-start.Y)) <= baseRadius {
+		a.Base += ms
+		return
+	}
+	var own bool
+	for _, tau := range units {
+		if !tau.Finished || !cp.inView(tau.Pos.X, tau.Pos.Y) {
+			continue
+		}
+		if tau.Owner != cp.Owner {
+			a.Front += ms
+			return
+		}
+		if tau.Class == buildingClass || tau.Class == factoryClass {
+			own = true
+		}
+	}
+	if own {
+		a.Expansion += ms
+		return
+	}
+	a.Other += ms
+}
+
+// CameraWorker consumes packets from a stream and returns every player's screen positions
+// and a summary of where they looked. A player's base is around where their commander
+// started and attention is sampled every second of game time.
+func CameraWorker(stream chan PacketRec, maxUnits int) (report *CameraReport, err error) {
+	report = &CameraReport{
+		Tracks:    make(map[int][]CameraPosition),
+		Attention: make(map[int]*Attention),
+	}
+	ut := newUnitTracker(maxUnits)
+	starts := make(map[int]image.Point)
+	cameras := make(map[int]CameraPosition)
+	var clock, lastSample, lastMove int
+	for pr := range stream {
+		if pr.Move != lastMove {
+			clock += int(pr.Time)
+			lastMove = pr.Move
+		}
+		if clock-lastSample >= presenceSampleInterval {
+			for player, cp := range cameras {
+				start, ok := starts[player]
+				since := lastSample
+				if cp.Time > since {
+					since = cp.Time
+				}
+				report.Attention[player].attend(cp, start, ok, ut.units, clock-since)
+			}
+			lastSample = clock
+		}
+		cp, ok, err := cameraFromPacket(pr, clock)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			report.Tracks[cp.Owner] = append(report.Tracks[cp.Owner], cp)
+			if _, seen := cameras[cp.Owner]; !seen {
+				report.Attention[cp.Owner] = &Attention{}
+			}
+			cameras[cp.Owner] = cp
+		}
+		if err := ut.update(pr, clock); err != nil {
+			return nil, err
+		}
+		if pr.Data[0] == 0x09 {
+			player := int(pr.Sender)
+			if _, ok := starts[player]; !ok && player >= 1 && player <= len(ut.unitSpaces) && ut.unitSpaces[player-1] != 0 {
+				if tau := ut.units[ut.unitSpaces[player-1]]; tau != nil {
+					starts[player] = image.Pt(tau.Pos.X, tau.Pos.Y)
+				}
+			}
+		}
+	}
+	return
+}
+
+// latestCameras returns the newest position of each player's screen in player order
+func latestCameras(cameras map[int]CameraPosition) []CameraPosition {
+	out := make([]CameraPosition, 0, len(cameras))
+	for _, cp := range cameras {
+		out = append(out, cp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Owner < out[j].Owner })
+	return out
+}
+
+// drawCameras outlines each player's screen in their color
+func (opts *RenderOptions) drawCameras(dc *gg.Context, cameras []CameraPosition, scale float64) {
+	if !opts.ShowCameras {
+		return
+	}
+	dc.SetLineWidth(opts.markerScale())
+	for _, cp := range cameras {
+		c, ok := opts.unitColor(&TAUnit{Owner: cp.Owner})
+		if !ok {
+			continue
+		}
+		dc.SetColor(c)
+		dc.DrawRectangle(scale*float64(cp.X), scale*float64(cp.Y), scale*float64(cameraViewport.X), scale*float64(cameraViewport.Y))
+		dc.Stroke()
+	}
+}
@@ -0,0 +1,285 @@
+package tad
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/fogleman/gg"
+)
+
+// ChartMetric is the value of a score sample that a chart shows
+type ChartMetric int
+
+// ChartMetric values for Chart
+const (
+	MetalIncome ChartMetric = iota
+	EnergyIncome
+	MetalTotal
+	EnergyTotal
+	MetalExcess
+	EnergyExcess
+	KillCount
+	LossCount
+)
+
+func (m ChartMetric) String() string {
+	switch m {
+	case MetalIncome:
+		return "metal income"
+	case EnergyIncome:
+		return "energy income"
+	case MetalTotal:
+		return "total metal"
+	case EnergyTotal:
+		return "total energy"
+	case MetalExcess:
+		return "excess metal"
+	case EnergyExcess:
+		return "excess energy"
+	case KillCount:
+		return "kills"
+	case LossCount:
+		return "losses"
+	}
+	return fmt.Sprintf("metric(%d)", int(m))
+}
+
+func (m ChartMetric) value(s SPLite) float64 {
+	switch m {
+	case MetalIncome:
+		return s.Metal
+	case EnergyIncome:
+		return s.Energy
+	case MetalTotal:
+		return s.TotalM
+	case EnergyTotal:
+		return s.TotalE
+	case MetalExcess:
+		return s.ExcessM
+	case EnergyExcess:
+		return s.ExcessE
+	case KillCount:
+		return float64(s.Kills)
+	case LossCount:
+		return float64(s.Losses)
+	}
+	return 0
+}
+
+// ChartMarker is a vertical line at a moment of the game like a death or a battle
+type ChartMarker struct {
+	Milliseconds int
+	Label        string
+}
+
+// Chart is a line chart of one metric for every player in a score series like the
+// ones from ScoreSeriesWorker and GenScoreSeries. Players without a color are gray.
+type Chart struct {
+	Metric  ChartMetric
+	Series  map[string][]SPLite
+	Colors  map[string]color.Color
+	Markers []ChartMarker
+	Width   int // pixels, 0 means 800
+	Height  int // pixels, 0 means 400
+}
+
+// defaultChartWidth and defaultChartHeight are the size of a chart when none is given
+// and chartMargin is the space around the plot
+const (
+	defaultChartWidth  = 800
+	defaultChartHeight = 400
+	chartMargin        = 40
+)
+
+var chartGray = color.RGBA{0x80, 0x80, 0x80, 0xff}
+
+// NewChart creates a chart of the series in the player colors of the game with markers
+// for when each player died. ttd is from TimeToDieWorker, where the players who lasted
+// to the end share the latest time and watchers have none.
+func (gp *Game) NewChart(series map[string][]SPLite, metric ChartMetric, ttd [10]int) *Chart {
+	c := &Chart{
+		Metric: metric,
+		Series: series,
+		Colors: make(map[string]color.Color),
+	}
+	var end int
+	for _, t := range ttd {
+		if t > end {
+			end = t
+		}
+	}
+	colorMap := gp.MakeColorMap()
+	for i, p := range gp.Players {
+		if idx, ok := colorMap[int(p.Number)]; ok && idx >= 0 && idx < len(playerColors) {
+			c.Colors[p.Name] = playerColors[idx]
+		}
+		if i < len(ttd) && ttd[i] > 0 && ttd[i] < end {
+			c.Markers = append(c.Markers, ChartMarker{Milliseconds: ttd[i], Label: p.Name + " died"})
+		}
+	}
+	sort.Slice(c.Markers, func(i, j int) bool { return c.Markers[i].Milliseconds < c.Markers[j].Milliseconds })
+	return c
+}
+
+// UnitDataSeries turns the records of UnitDataSeriesWorker into a score series keyed by
+// player name so they can be charted
+func (gp *Game) UnitDataSeries(records map[int][]UDSRecord) map[string][]SPLite {
+	series := make(map[string][]SPLite)
+	for _, p := range gp.Players {
+		for _, r := range records[int(p.Number)] {
+			series[p.Name] = append(series[p.Name], r.SPLite)
+		}
+	}
+	return series
+}
+
+// BattleMarkers finds the minutes of the game where at least threshold units were
+// killed across all players and marks the start of each
+func BattleMarkers(series map[string][]SPLite, threshold int) []ChartMarker {
+	kills := make(map[int]int)
+	for _, samples := range series {
+		last := 0
+		for _, s := range samples {
+			if s.Kills > last {
+				kills[s.Milliseconds/60000] += s.Kills - last
+				last = s.Kills
+			}
+		}
+	}
+	var markers []ChartMarker
+	for minute, n := range kills {
+		if n >= threshold {
+			markers = append(markers, ChartMarker{
+				Milliseconds: minute * 60000,
+				Label:        fmt.Sprintf("battle: %d kills", n),
+			})
+		}
+	}
+	sort.Slice(markers, func(i, j int) bool { return markers[i].Milliseconds < markers[j].Milliseconds })
+	return markers
+}
+
+// chartLayout maps game time and metric values on to the plot area
+type chartLayout struct {
+	width, height float64
+	maxT, maxV    float64
+	names         []string
+}
+
+func (c *Chart) layout() chartLayout {
+	l := chartLayout{width: float64(c.Width), height: float64(c.Height), maxT: 1, maxV: 1}
+	if l.width <= 0 {
+		l.width = defaultChartWidth
+	}
+	if l.height <= 0 {
+		l.height = defaultChartHeight
+	}
+	for name, samples := range c.Series {
+		l.names = append(l.names, name)
+		for _, s := range samples {
+			l.maxT = math.Max(l.maxT, float64(s.Milliseconds))
+			l.maxV = math.Max(l.maxV, c.Metric.value(s))
+		}
+	}
+	for _, m := range c.Markers {
+		l.maxT = math.Max(l.maxT, float64(m.Milliseconds))
+	}
+	sort.Strings(l.names)
+	return l
+}
+
+func (l *chartLayout) x(ms int) float64 {
+	return chartMargin + (l.width-2*chartMargin)*float64(ms)/l.maxT
+}
+
+func (l *chartLayout) y(v float64) float64 {
+	return l.height - chartMargin - (l.height-2*chartMargin)*v/l.maxV
+}
+
+func (c *Chart) color(name string) color.Color {
+	if col, ok := c.Colors[name]; ok {
+		return col
+	}
+	return chartGray
+}
+
+func svgColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
+// WriteSVG writes the chart as an SVG document
+func (c *Chart) WriteSVG(w io.Writer) error {
+	l := c.layout()
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f">`+"\n", l.width, l.height, l.width, l.height)
+	fmt.Fprintf(bw, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	fmt.Fprintf(bw, `<text x="%d" y="20" font-family="sans-serif" font-size="14">%s</text>`+"\n", chartMargin, html.EscapeString(c.Metric.String()))
+	fmt.Fprintf(bw, `<polyline points="%.1f,%.1f %.1f,%.1f %.1f,%.1f" fill="none" stroke="black"/>`+"\n",
+		l.x(0), l.y(l.maxV), l.x(0), l.y(0), l.x(int(l.maxT)), l.y(0))
+	fmt.Fprintf(bw, `<text x="%.1f" y="%.1f" font-family="sans-serif" font-size="10" text-anchor="end">%.0f</text>`+"\n", l.x(0)-4, l.y(l.maxV)+4, l.maxV)
+	fmt.Fprintf(bw, `<text x="%.1f" y="%.1f" font-family="sans-serif" font-size="10" text-anchor="end">%d min</text>`+"\n", l.x(int(l.maxT)), l.y(0)+14, int(l.maxT)/60000)
+	for _, m := range c.Markers {
+		fmt.Fprintf(bw, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="gray" stroke-dasharray="4 3"/>`+"\n",
+			l.x(m.Milliseconds), l.y(l.maxV), l.x(m.Milliseconds), l.y(0))
+		fmt.Fprintf(bw, `<text x="%.1f" y="%.1f" font-family="sans-serif" font-size="10" fill="gray">%s</text>`+"\n",
+			l.x(m.Milliseconds)+2, l.y(l.maxV)+10, html.EscapeString(m.Label))
+	}
+	for i, name := range l.names {
+		fmt.Fprintf(bw, `<polyline fill="none" stroke="%s" stroke-width="2" points="`, svgColor(c.color(name)))
+		for j, s := range c.Series[name] {
+			if j > 0 {
+				bw.WriteString(" ")
+			}
+			fmt.Fprintf(bw, "%.1f,%.1f", l.x(s.Milliseconds), l.y(c.Metric.value(s)))
+		}
+		bw.WriteString("\"/>\n")
+		fmt.Fprintf(bw, `<text x="%.1f" y="20" font-family="sans-serif" font-size="12" fill="%s">%s</text>`+"\n",
+			l.width-chartMargin-float64(120*(len(l.names)-i)), svgColor(c.color(name)), html.EscapeString(name))
+	}
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
+
+// WritePNG draws the chart as a PNG
+func (c *Chart) WritePNG(w io.Writer) error {
+	l := c.layout()
+	dc := gg.NewContext(int(l.width), int(l.height))
+	dc.SetColor(color.White)
+	dc.Clear()
+	dc.SetColor(color.Black)
+	dc.DrawString(c.Metric.String(), chartMargin, 20)
+	dc.SetLineWidth(1)
+	dc.DrawLine(l.x(0), l.y(l.maxV), l.x(0), l.y(0))
+	dc.DrawLine(l.x(0), l.y(0), l.x(int(l.maxT)), l.y(0))
+	dc.Stroke()
+	dc.DrawStringAnchored(fmt.Sprintf("%.0f", l.maxV), l.x(0)-4, l.y(l.maxV), 1, 0.5)
+	dc.DrawStringAnchored(fmt.Sprintf("%d min", int(l.maxT)/60000), l.x(int(l.maxT)), l.y(0)+12, 1, 0.5)
+	dc.SetColor(chartGray)
+	dc.SetDash(4, 3)
+	for _, m := range c.Markers {
+		dc.DrawLine(l.x(m.Milliseconds), l.y(l.maxV), l.x(m.Milliseconds), l.y(0))
+		dc.Stroke()
+		dc.DrawString(m.Label, l.x(m.Milliseconds)+2, l.y(l.maxV)+10)
+	}
+	dc.SetDash()
+	dc.SetLineWidth(2)
+	for i, name := range l.names {
+		dc.SetColor(c.color(name))
+		for j, s := range c.Series[name] {
+			if j == 0 {
+				dc.MoveTo(l.x(s.Milliseconds), l.y(c.Metric.value(s)))
+				continue
+			}
+			dc.LineTo(l.x(s.Milliseconds), l.y(c.Metric.value(s)))
+		}
+		dc.Stroke()
+		dc.DrawString(name, l.width-chartMargin-float64(120*(len(l.names)-i)), 20)
+	}
+	return dc.EncodePNG(w)
+}
@@ -0,0 +1,146 @@
+package tad
+
+import (
+	"regexp"
+	"strings"
+)
+
+// LobbyMessageKind classifies a line of lobby chat
+type LobbyMessageKind int
+
+// LobbyMessageKind values found by parseLobbyMessages
+const (
+	PlayerLine LobbyMessageKind = iota
+	JoinLine
+	LeaveLine
+	KickLine
+	MapChangeLine
+	SystemLine
+)
+
+func (k LobbyMessageKind) String() string {
+	switch k {
+	case PlayerLine:
+		return "player"
+	case JoinLine:
+		return "join"
+	case LeaveLine:
+		return "leave"
+	case KickLine:
+		return "kick"
+	case MapChangeLine:
+		return "map"
+	}
+	return "system"
+}
+
+// LobbyMessage is a line of lobby chat. Speaker is set for lines typed by a player
+// and Subject is the player a system line is about when it can be found.
+type LobbyMessage struct {
+	Kind    LobbyMessageKind
+	Speaker string
+	Subject string
+	Text    string
+}
+
+// lobbyPatterns match whole system lines, after the "***" in front of them, to their
+// kind. The subject group is the player the line is about. They are anchored at both
+// ends so chat like "I left early" isn't taken for a player leaving.
+var lobbyPatterns = []struct {
+	re   *regexp.Regexp
+	kind LobbyMessageKind
+}{
+	{regexp.MustCompile(`(?i)^(?P<subject>\S+) (?:has been|was) kicked(?: by \S+)?\.?$`), KickLine},
+	{regexp.MustCompile(`(?i)^\S+ kicked (?P<subject>\S+)\.?$`), KickLine},
+	{regexp.MustCompile(`(?i)^(?P<subject>\S+) has joined(?: the game)?\.?$`), JoinLine},
+	{regexp.MustCompile(`(?i)^(?P<subject>\S+) has left(?: the game)?\.?$`), LeaveLine},
+	{regexp.MustCompile(`(?i)^(?P<subject>\S+) (?:has )?disconnected\.?$`), LeaveLine},
+	{regexp.MustCompile(`(?i)^map changed to \S.*$`), MapChangeLine},
+}
+
+// parseLobbyMessage splits a lobby line into its speaker and text. Player lines
+// look like "<name> text" and everything else is a system line.
+func parseLobbyMessage(line string) (lm LobbyMessage) {
+	if strings.HasPrefix(line, "<") {
+		if end := strings.Index(line, ">"); end > 1 {
+			lm.Kind = PlayerLine
+			lm.Speaker = line[1:end]
+			lm.Text = strings.TrimPrefix(line[end+1:], " ")
+			return
+		}
+	}
+	lm.Kind = SystemLine
+	lm.Text = line
+	body := strings.TrimSpace(strings.TrimLeft(line, "*"))
+	for _, p := range lobbyPatterns {
+		match := p.re.FindStringSubmatch(body)
+		if match == nil {
+			continue
+		}
+		lm.Kind = p.kind
+		if i := p.re.SubexpIndex("subject"); i > 0 {
+			lm.Subject = match[i]
+		}
+		return
+	}
+	return
+}
+
+func parseLobbyMessages(lines []string) []LobbyMessage {
+	messages := make([]LobbyMessage, len(lines))
+	for i := range lines {
+		messages[i] = parseLobbyMessage(lines[i])
+	}
+	return messages
+}
+
+// LobbyMessagesBy returns the lobby messages spoken by or about a player
+func (gp *Game) LobbyMessagesBy(name string) (messages []LobbyMessage) {
+	for _, lm := range gp.LobbyMessages {
+		if strings.EqualFold(lm.Speaker, name) || strings.EqualFold(lm.Subject, name) {
+			messages = append(messages, lm)
+		}
+	}
+	return
+}
+
+// ChatScope is who an in-game message was sent to. Recordings don't say yet so
+// ChatWorker gives AllChat for every message.
+type ChatScope int
+
+// ChatScope values of PlayerMessage
+const (
+	AllChat ChatScope = iota
+	AllyChat
+	PrivateChat
+)
+
+func (c ChatScope) String() string {
+	switch c {
+	case AllyChat:
+		return "allies"
+	case PrivateChat:
+		return "private"
+	}
+	return "all"
+}
+
+// ChatOptions turns on flagging in ChatWorker
+type ChatOptions struct {
+	FlagWatchers bool // flag messages from players on side 2
+	FlagDead     bool // flag messages sent after the player died
+}
+
+// parsePlayerMessage splits an in-game line of the form "<name> text". The 0x05 packet
+// holds only the line and nothing in it is known to mark who it was sent to, so the
+// scope is left as AllChat.
+func parsePlayerMessage(line string) (pm PlayerMessage) {
+	pm.Message = line
+	pm.Text = line
+	if strings.HasPrefix(line, "<") {
+		if end := strings.Index(line, ">"); end > 1 {
+			pm.Text = strings.TrimPrefix(line[end+1:], " ")
+		}
+	}
+	return
+}
@@ -0,0 +1,547 @@
+package tad
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FindingKind identifies the rule that produced a Finding
+type FindingKind int
+
+// FindingKind values reported by the CheatDetector
+const (
+	CheatsEnabled FindingKind = iota
+	ScoreRegression
+	ImpossibleIncome
+	FastBuild
+	Teleport
+	OverStorage
+	DotCommand
+	DeadSender
+)
+
+func (k FindingKind) String() string {
+	switch k {
+	case CheatsEnabled:
+		return "cheats-enabled"
+	case ScoreRegression:
+		return "score-regression"
+	case ImpossibleIncome:
+		return "impossible-income"
+	case FastBuild:
+		return "fast-build"
+	case Teleport:
+		return "teleport"
+	case OverStorage:
+		return "over-storage"
+	case DotCommand:
+		return "dot-command"
+	case DeadSender:
+		return "dead-sender"
+	}
+	return fmt.Sprintf("finding(%d)", int(k))
+}
+
+// Finding is a piece of evidence of foul play. Player is the sender number
+// and Milliseconds is the game clock when the evidence was seen.
+type Finding struct {
+	Kind         FindingKind
+	Player       int
+	Name         string
+	Move         int
+	Milliseconds int
+	Evidence     string
+}
+
+// CheatReport holds every finding from a CheatDetector run in stream order
+type CheatReport struct {
+	Findings []Finding
+}
+
+// Clean reports whether no findings were made
+func (cr *CheatReport) Clean() bool {
+	return len(cr.Findings) == 0
+}
+
+// ByPlayer groups the findings by player number
+func (cr *CheatReport) ByPlayer() map[int][]Finding {
+	out := make(map[int][]Finding)
+	for _, f := range cr.Findings {
+		out[f.Player] = append(out[f.Player], f)
+	}
+	return out
+}
+
+// Suspects returns the numbers of the players that have at least one finding
+func (cr *CheatReport) Suspects() (players []int) {
+	seen := make(map[int]bool)
+	for _, f := range cr.Findings {
+		if !seen[f.Player] {
+			seen[f.Player] = true
+			players = append(players, f.Player)
+		}
+	}
+	return
+}
+
+// UnitInfo is static data about a unit type. Zero values mean unknown.
+type UnitInfo struct {
+	Name       string
+	BuildTime  int     // fewest milliseconds the unit can be built in
+	MetalMake  float64 // most metal per second the unit can produce
+	EnergyMake float64 // most energy per second the unit can produce
+	Sight      int     // game pixels the unit can see
+}
+
+// UnitTable maps a NetID to its UnitInfo
+type UnitTable map[uint16]UnitInfo
+
+// NewUnitTable creates a UnitTable from a map of NetIDs to unit names like the
+// one stored in taesc900.gob. Only the names are filled in, the build times and
+// incomes have to come from the units' FBI files for IncomeRule and BuildTimeRule.
+func NewUnitTable(names map[uint16]string) UnitTable {
+	ut := make(UnitTable)
+	for k, v := range names {
+		ut[k] = UnitInfo{Name: v}
+	}
+	return ut
+}
+
+// hasIncomes reports whether any unit of the table makes metal or energy
+func (ut UnitTable) hasIncomes() bool {
+	for _, info := range ut {
+		if info.MetalMake > 0 || info.EnergyMake > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasBuildTimes reports whether any unit of the table has a build time
+func (ut UnitTable) hasBuildTimes() bool {
+	for _, info := range ut {
+		if info.BuildTime > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// CheatContext is the state shared with each CheatRule while the stream is read.
+// Units is kept up to date the way FramesWorker tracks them before rules run.
+type CheatContext struct {
+	Game         *Game
+	Units        map[uint16]*TAUnit
+	Move         int
+	Milliseconds int
+	ut           *unitTracker
+}
+
+// finding creates a Finding for the sender of pr at the current time
+func (cc *CheatContext) finding(kind FindingKind, pr PacketRec, evidence string) Finding {
+	return Finding{
+		Kind:         kind,
+		Player:       int(pr.Sender),
+		Move:         pr.Move,
+		Milliseconds: cc.Milliseconds,
+		Evidence:     evidence,
+	}
+}
+
+// smartpakPos decodes the unit and position of a 0x2c packet the same way FramesWorker does
+func (cc *CheatContext) smartpakPos(pr PacketRec) (unitID uint16, x, y int, ok bool) {
+	if pr.Data[0] != 0x2c || len(pr.Data) < 0x1a {
+		return
+	}
+	unitID = binary.LittleEndian.Uint16(pr.Data[0x7:]) + cc.ut.unitSpaces[int(pr.Sender)-1]
+	netID := binary.LittleEndian.Uint16(pr.Data[0x9:])
+	tau, found := cc.Units[unitID]
+	if !found || tau == nil || netID-0xc00 != tau.NetID {
+		return
+	}
+	x = int(binary.LittleEndian.Uint16(pr.Data[0xb:])) * 16
+	y = int(binary.LittleEndian.Uint16(pr.Data[0xd:])) * 16
+	return unitID, x, y, true
+}
+
+// CheatRule inspects each packet of a stream and reports findings. Rules keep
+// their own state so a fresh rule should be used for each game.
+type CheatRule interface {
+	Check(cc *CheatContext, pr PacketRec) ([]Finding, error)
+}
+
+// CheatDetector runs a set of CheatRules over a packet stream
+type CheatDetector struct {
+	Game  Game
+	Rules []CheatRule
+}
+
+// NewCheatDetector creates a CheatDetector for the game. When no rules are given
+// it uses DefaultCheatRules with the unit table ut, which may be nil. Rules given
+// whose unit table is missing the data they check are logged as they can't report anything.
+func NewCheatDetector(gp Game, ut UnitTable, rules ...CheatRule) *CheatDetector {
+	if len(rules) == 0 {
+		rules = DefaultCheatRules(ut)
+	}
+	for _, rule := range rules {
+		switch r := rule.(type) {
+		case *IncomeRule:
+			if !r.Units.hasIncomes() {
+				log.Warn("IncomeRule has no unit incomes and won't report anything")
+			}
+		case *BuildTimeRule:
+			if !r.Units.hasBuildTimes() {
+				log.Warn("BuildTimeRule has no unit build times and won't report anything")
+			}
+		}
+	}
+	return &CheatDetector{
+		Game:  gp,
+		Rules: rules,
+	}
+}
+
+// defaults of the TeleportRule of DefaultCheatRules. The fastest aircraft have a
+// MaxVelocity of about 12 pixels a game tick, which is 360 pixels a second at 30 ticks
+// a second, and 600 leaves room for lag bunching up their packets. 0x2c positions are
+// in steps of 16 pixels and the slack is 16 of those steps.
+const (
+	defaultTeleportSpeed = 600
+	defaultTeleportSlack = 256
+)
+
+// DefaultCheatRules returns a fresh instance of every built in rule. The income
+// and build time rules are left out unless ut has incomes or build times, which
+// the tables from NewUnitTable don't.
+func DefaultCheatRules(ut UnitTable) []CheatRule {
+	rules := []CheatRule{
+		&ScoreRegressionRule{},
+		&TeleportRule{MaxSpeed: defaultTeleportSpeed, Slack: defaultTeleportSlack},
+		&StorageRule{Slack: 1},
+		&DotCommandRule{},
+		&DeadSenderRule{},
+	}
+	if ut.hasIncomes() {
+		rules = append(rules, &IncomeRule{Units: ut, Tolerance: 1.5})
+	}
+	if ut.hasBuildTimes() {
+		rules = append(rules, &BuildTimeRule{Units: ut})
+	}
+	return rules
+}
+
+// Worker consumes packets from a stream and returns a report of the findings
+func (cd *CheatDetector) Worker(stream chan PacketRec) (report *CheatReport, err error) {
+	report = &CheatReport{}
+	pnames := GenPnames(cd.Game.Players)
+	for _, p := range cd.Game.Players {
+		if p.Cheats {
+			report.Findings = append(report.Findings, Finding{
+				Kind:     CheatsEnabled,
+				Player:   int(p.Number),
+				Name:     p.Name,
+				Evidence: "cheats bit set in status message",
+			})
+		}
+	}
+	cc := &CheatContext{
+		Game: &cd.Game,
+		ut:   newUnitTracker(cd.Game.MaxUnits),
+	}
+	cc.Units = cc.ut.units
+	var lastMove int
+	for pr := range stream {
+		if pr.Move != lastMove {
+			cc.Milliseconds += int(pr.Time)
+			lastMove = pr.Move
+		}
+		cc.Move = pr.Move
+		if len(pr.Data) == 0 || pr.Sender < 1 || pr.Sender > 10 {
+			continue
+		}
+		if err = cc.ut.update(pr, cc.Milliseconds); err != nil {
+			return nil, err
+		}
+		for _, rule := range cd.Rules {
+			found, err := rule.Check(cc, pr)
+			if err != nil {
+				return nil, err
+			}
+			for i := range found {
+				found[i].Name = pnames[byte(found[i].Player)]
+			}
+			report.Findings = append(report.Findings, found...)
+		}
+	}
+	return
+}
+
+// ScoreRegressionRule reports kills, losses or resource totals that go down,
+// which is what FinalScoresWorker calls foul play
+type ScoreRegressionRule struct {
+	last [10]*packet0x28
+}
+
+// Check implements CheatRule
+func (r *ScoreRegressionRule) Check(cc *CheatContext, pr PacketRec) (findings []Finding, err error) {
+	if pr.Data[0] != 0x28 {
+		return
+	}
+	sp := &packet0x28{}
+	if err = binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, sp); err != nil {
+		return nil, err
+	}
+	if last := r.last[int(pr.Sender)-1]; last != nil {
+		if sp.Kills < last.Kills {
+			findings = append(findings, cc.finding(ScoreRegression, pr,
+				fmt.Sprintf("kills went from %d to %d", last.Kills, sp.Kills)))
+		}
+		if sp.Losses < last.Losses {
+			findings = append(findings, cc.finding(ScoreRegression, pr,
+				fmt.Sprintf("losses went from %d to %d", last.Losses, sp.Losses)))
+		}
+		if sp.TotalE < last.TotalE {
+			findings = append(findings, cc.finding(ScoreRegression, pr,
+				fmt.Sprintf("total energy went from %.0f to %.0f", last.TotalE, sp.TotalE)))
+		}
+		if sp.TotalM < last.TotalM {
+			findings = append(findings, cc.finding(ScoreRegression, pr,
+				fmt.Sprintf("total metal went from %.0f to %.0f", last.TotalM, sp.TotalM)))
+		}
+	}
+	r.last[int(pr.Sender)-1] = sp
+	return
+}
+
+// IncomeRule reports income that the player's finished units could not have
+// produced according to Units. Tolerance multiplies the expected income to
+// allow for reclaiming and timing jitter.
+type IncomeRule struct {
+	Units     UnitTable
+	Tolerance float64
+	last      [10]*packet0x28
+	lastTime  [10]int
+}
+
+// Check implements CheatRule
+func (r *IncomeRule) Check(cc *CheatContext, pr PacketRec) (findings []Finding, err error) {
+	if pr.Data[0] != 0x28 || len(r.Units) == 0 {
+		return
+	}
+	sp := &packet0x28{}
+	if err = binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, sp); err != nil {
+		return nil, err
+	}
+	n := int(pr.Sender) - 1
+	last, lastTime := r.last[n], r.lastTime[n]
+	// income is only measured over windows of at least a second
+	if last != nil && cc.Milliseconds-lastTime < 1000 {
+		return
+	}
+	r.last[n] = sp
+	r.lastTime[n] = cc.Milliseconds
+	if last == nil {
+		return
+	}
+	var maxM, maxE float64
+	for _, tau := range cc.Units {
+		if tau.Owner == int(pr.Sender) && tau.Finished {
+			maxM += r.Units[tau.NetID].MetalMake
+			maxE += r.Units[tau.NetID].EnergyMake
+		}
+	}
+	tolerance := r.Tolerance
+	if tolerance < 1 {
+		tolerance = 1
+	}
+	seconds := float64(cc.Milliseconds-lastTime) / 1000
+	metal := float64(sp.TotalM-last.TotalM) / seconds
+	energy := float64(sp.TotalE-last.TotalE) / seconds
+	if metal > maxM*tolerance+1 {
+		findings = append(findings, cc.finding(ImpossibleIncome, pr,
+			fmt.Sprintf("metal income of %.1f/s exceeds %.1f/s possible from units", metal, maxM)))
+	}
+	if energy > maxE*tolerance+1 {
+		findings = append(findings, cc.finding(ImpossibleIncome, pr,
+			fmt.Sprintf("energy income of %.1f/s exceeds %.1f/s possible from units", energy, maxE)))
+	}
+	return
+}
+
+// BuildTimeRule reports units that were finished sooner after they were
+// started than the BuildTime in Units allows
+type BuildTimeRule struct {
+	Units   UnitTable
+	started map[uint16]int
+}
+
+// Check implements CheatRule
+func (r *BuildTimeRule) Check(cc *CheatContext, pr PacketRec) (findings []Finding, err error) {
+	if len(r.Units) == 0 {
+		return
+	}
+	if r.started == nil {
+		r.started = make(map[uint16]int)
+	}
+	switch pr.Data[0] {
+	case 0x09:
+		tmp := &packet0x09{}
+		if err = binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+			return nil, err
+		}
+		r.started[tmp.UnitID] = cc.Milliseconds
+	case 0x12:
+		tmp := &packet0x12{}
+		if err = binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+			return nil, err
+		}
+		start, ok := r.started[tmp.BuiltID]
+		if !ok {
+			return
+		}
+		delete(r.started, tmp.BuiltID)
+		tau := cc.Units[tmp.BuiltID]
+		if tau == nil {
+			return
+		}
+		info := r.Units[tau.NetID]
+		if took := cc.Milliseconds - start; took < info.BuildTime {
+			findings = append(findings, cc.finding(FastBuild, pr,
+				fmt.Sprintf("%v (%04x) built in %d ms, needs at least %d ms", info.Name, tmp.BuiltID, took, info.BuildTime)))
+		}
+	case 0x0c:
+		tmp := &packet0x0c{}
+		if err = binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+			return nil, err
+		}
+		delete(r.started, tmp.Destroyed)
+	}
+	return
+}
+
+// TeleportRule reports units whose 0x2c positions are further apart than they
+// could travel at MaxSpeed map pixels per second. Slack is the distance in
+// pixels that is always allowed for jitter.
+type TeleportRule struct {
+	MaxSpeed float64
+	Slack    float64
+	last     map[uint16]point
+}
+
+// Check implements CheatRule
+func (r *TeleportRule) Check(cc *CheatContext, pr PacketRec) (findings []Finding, err error) {
+	if r.last == nil {
+		r.last = make(map[uint16]point)
+	}
+	if pr.Data[0] == 0x0c {
+		tmp := &packet0x0c{}
+		if err = binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+			return nil, err
+		}
+		delete(r.last, tmp.Destroyed)
+		return
+	}
+	unitID, x, y, ok := cc.smartpakPos(pr)
+	if !ok {
+		return
+	}
+	if prev, ok := r.last[unitID]; ok {
+		dist := math.Hypot(float64(x-prev.X), float64(y-prev.Y))
+		allowed := r.MaxSpeed*float64(cc.Milliseconds-prev.Time)/1000 + r.Slack
+		if dist > allowed {
+			findings = append(findings, cc.finding(Teleport, pr,
+				fmt.Sprintf("unit %04x moved %.0f pixels from (%d, %d) to (%d, %d) in %d ms",
+					unitID, dist, prev.X, prev.Y, x, y, cc.Milliseconds-prev.Time)))
+		}
+	}
+	r.last[unitID] = point{X: x, Y: y, Time: cc.Milliseconds}
+	return
+}
+
+// StorageRule reports stored metal or energy above the player's storage
+type StorageRule struct {
+	Slack float64
+}
+
+// Check implements CheatRule
+func (r *StorageRule) Check(cc *CheatContext, pr PacketRec) (findings []Finding, err error) {
+	if pr.Data[0] != 0x28 {
+		return
+	}
+	sp := &packet0x28{}
+	if err = binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, sp); err != nil {
+		return nil, err
+	}
+	// storage hasn't been reported yet
+	if sp.StorageM == 0 && sp.StorageE == 0 {
+		return
+	}
+	if float64(sp.StoredM) > float64(sp.StorageM)+r.Slack {
+		findings = append(findings, cc.finding(OverStorage, pr,
+			fmt.Sprintf("stored metal %.0f exceeds storage of %.0f", sp.StoredM, sp.StorageM)))
+	}
+	if float64(sp.StoredE) > float64(sp.StorageE)+r.Slack {
+		findings = append(findings, cc.finding(OverStorage, pr,
+			fmt.Sprintf("stored energy %.0f exceeds storage of %.0f", sp.StoredE, sp.StorageE)))
+	}
+	return
+}
+
+// DotCommandRule reports chat messages that start with a '.' command
+type DotCommandRule struct{}
+
+// Check implements CheatRule
+func (r *DotCommandRule) Check(cc *CheatContext, pr PacketRec) (findings []Finding, err error) {
+	if pr.Data[0] != 0x05 {
+		return
+	}
+	tmp := &packet0x05{}
+	if err = binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+		return nil, err
+	}
+	msg := parsePlayerMessage(string(bytes.Split(tmp.Message[:], []byte{0x00})[0])).Text
+	if strings.HasPrefix(msg, ".") {
+		findings = append(findings, cc.finding(DotCommand, pr,
+			fmt.Sprintf("sent command %q", msg)))
+	}
+	return
+}
+
+// DeadSenderRule reports gameplay packets sent by a player after their time to
+// die. TimeToDie is in milliseconds like the output of TimeToDieWorker. Entries
+// left at zero are found from commander deaths and rejections in the stream.
+type DeadSenderRule struct {
+	TimeToDie [10]int
+	reported  [10]bool
+}
+
+// Check implements CheatRule
+func (r *DeadSenderRule) Check(cc *CheatContext, pr PacketRec) (findings []Finding, err error) {
+	n := int(pr.Sender) - 1
+	ttd := r.TimeToDie[n]
+	if ttd != 0 && cc.Milliseconds > ttd && !r.reported[n] {
+		switch pr.Data[0] {
+		case 0x09, 0x0d, 0x12, 0x0b, 0x05:
+			r.reported[n] = true
+			findings = append(findings, cc.finding(DeadSender, pr,
+				fmt.Sprintf("sent a %02x packet %d ms after dying", pr.Data[0], cc.Milliseconds-ttd)))
+		}
+		return
+	}
+	if ttd != 0 {
+		return
+	}
+	dead, err := isDeathPacket(pr, cc.Game)
+	if err != nil {
+		return nil, err
+	}
+	if dead {
+		r.TimeToDie[n] = cc.Milliseconds
+	}
+	return
+}
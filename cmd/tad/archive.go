@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+
+	"github.com/cosmouser/tad"
+	"github.com/cosmouser/tad/archive"
+)
+
+func runIndex(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("index", flag.ContinueOnError)
+	indexPath := fs.String("index", "index.jsonl", "index file to add the recordings to")
+	workers := fs.Int("workers", 0, "recordings analyzed at once, defaults to one per CPU")
+	namesPath := fs.String("names", "", "gob file of unit names like taesc900.gob")
+	retry := fs.Bool("retry", false, "analyze recordings that failed in an earlier run again")
+	verbose := fs.Bool("v", false, "print each recording as it is indexed")
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+	if err := checkDir(fs); err != nil {
+		return err
+	}
+	names, err := loadNames(*namesPath)
+	if err != nil {
+		return err
+	}
+	ix := &archive.Indexer{Workers: *workers, UnitNames: names, RetryFailed: *retry}
+	if *verbose {
+		ix.Progress = func(e *archive.Entry) {
+			switch {
+			case e.Failed():
+				fmt.Fprintln(stdout, e.Error)
+			case e.DuplicateOf != "":
+				fmt.Fprintf(stdout, "%v: duplicate of %v\n", e.Path, e.DuplicateOf)
+			default:
+				fmt.Fprintf(stdout, "%v: %v\n", e.Path, e.Report.Map)
+			}
+		}
+	}
+	stats, err := index(ix, fs.Arg(0), *indexPath)
+	fmt.Fprintf(stdout, "indexed %d, skipped %d, duplicates %d, failed %d\n", stats.Indexed, stats.Skipped, stats.Duplicates, stats.Failed)
+	return err
+}
+
+// checkDir checks that the only argument left after the flags is a directory
+func checkDir(fs *flag.FlagSet) error {
+	if fs.NArg() != 1 {
+		return usageError("expected one directory, got %d arguments", fs.NArg())
+	}
+	if info, err := os.Stat(fs.Arg(0)); err != nil || !info.IsDir() {
+		return &exitError{exitUnreadable, fmt.Errorf("%v is not a directory", fs.Arg(0))}
+	}
+	return nil
+}
+
+// index runs ix until it finishes or is interrupted
+func index(ix *archive.Indexer, dir, indexPath string) (archive.IndexStats, error) {
+	// an interrupted run is picked up by the next one
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	stats, err := ix.Index(ctx, dir, indexPath)
+	if errors.Is(err, context.Canceled) {
+		return stats, fmt.Errorf("interrupted, run again to carry on")
+	}
+	return stats, err
+}
+
+func runLadder(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("ladder", flag.ContinueOnError)
+	indexPath := fs.String("index", "index.jsonl", "index file of the recordings, updated before rating")
+	workers := fs.Int("workers", 0, "recordings analyzed at once, defaults to one per CPU")
+	namesPath := fs.String("names", "", "gob file of unit names like taesc900.gob")
+	overridesPath := fs.String("overrides", "", "JSON file of manual identity merges and splits")
+	format := fs.String("format", "text", "output format: text or json")
+	top := fs.Int("top", 0, "print only the best n players")
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+	if err := checkFormat(*format, "text", "json"); err != nil {
+		return err
+	}
+	if err := checkDir(fs); err != nil {
+		return err
+	}
+	names, err := loadNames(*namesPath)
+	if err != nil {
+		return err
+	}
+	var overrides []archive.Override
+	if *overridesPath != "" {
+		data, err := os.ReadFile(*overridesPath)
+		if err != nil {
+			return &exitError{exitUnreadable, err}
+		}
+		if err := json.Unmarshal(data, &overrides); err != nil {
+			return usageError("reading overrides: %v", err)
+		}
+	}
+	if _, err := index(&archive.Indexer{Workers: *workers, UnitNames: names}, fs.Arg(0), *indexPath); err != nil {
+		return err
+	}
+	entries, err := archive.LoadIndex(*indexPath)
+	if err != nil {
+		return err
+	}
+	r := archive.NewResolver(overrides)
+	var games []*tad.GameReport
+	for _, e := range entries {
+		if e.Report != nil {
+			r.Add(e.Report)
+			games = append(games, e.Report)
+		}
+	}
+	ladder := &archive.Ladder{Identify: archive.Identified(r.Identities())}
+	ladder.Rebuild(games)
+	standings := ladder.Standings()
+	if *top > 0 && *top < len(standings) {
+		standings = standings[:*top]
+	}
+	if *format == "json" {
+		return writeJSON(stdout, standings)
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "rank\trating\tgames\twins\tlosses\tdraws\tname")
+	for i, pr := range standings {
+		fmt.Fprintf(tw, "%d\t%.0f\t%d\t%d\t%d\t%d\t%v\n", i+1, pr.Rating, pr.Games, pr.Wins, pr.Losses, pr.Draws, pr.Name)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	_ "image/png" // map pictures are usually png
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/cosmouser/tad"
+)
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeCSV(w io.Writer, records [][]string) error {
+	cw := csv.NewWriter(w)
+	cw.WriteAll(records)
+	return cw.Error()
+}
+
+func sideName(side byte) string {
+	switch side {
+	case 0:
+		return "arm"
+	case 1:
+		return "core"
+	case 2:
+		return "watcher"
+	}
+	return strconv.Itoa(int(side))
+}
+
+type playerInfo struct {
+	Number int    `json:"number"`
+	Name   string `json:"name"`
+	Side   string `json:"side"`
+	Color  int    `json:"color"`
+	IP     string `json:"ip,omitempty"`
+	Status string `json:"status,omitempty"`
+	Cheats bool   `json:"cheats"`
+}
+
+type gameInfo struct {
+	Map         string       `json:"map"`
+	Players     []playerInfo `json:"players"`
+	Version     string       `json:"version,omitempty"`
+	Recorded    string       `json:"recorded,omitempty"`
+	RecFrom     string       `json:"recFrom,omitempty"`
+	Length      string       `json:"length"`
+	Unitsum     string       `json:"unitsum"`
+	Fingerprint string       `json:"fingerprint"`
+}
+
+func runInfo(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("info", flag.ContinueOnError)
+	format := fs.String("format", "text", "output format: text or json")
+	path, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkFormat(*format, "text", "json"); err != nil {
+		return err
+	}
+	r, err := openReplay(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	gp := r.game
+	info := gameInfo{
+		Map:         gp.MapName,
+		Version:     gp.Version,
+		Recorded:    gp.RecDate,
+		RecFrom:     gp.RecFrom,
+		Length:      (time.Duration(gp.Milliseconds) * time.Millisecond).String(),
+		Unitsum:     gp.Unitsum,
+		Fingerprint: gp.GetFingerprint(),
+	}
+	for _, p := range gp.Players {
+		info.Players = append(info.Players, playerInfo{
+			Number: int(p.Number),
+			Name:   p.Name,
+			Side:   sideName(p.Side),
+			Color:  int(p.Color),
+			IP:     p.IP,
+			Status: p.Status,
+			Cheats: p.Cheats,
+		})
+	}
+	if *format == "json" {
+		return writeJSON(stdout, info)
+	}
+	fmt.Fprintf(stdout, "map:         %v\n", info.Map)
+	fmt.Fprintf(stdout, "version:     %v\n", info.Version)
+	fmt.Fprintf(stdout, "recorded:    %v\n", info.Recorded)
+	fmt.Fprintf(stdout, "length:      %v\n", info.Length)
+	fmt.Fprintf(stdout, "unitsum:     %v\n", info.Unitsum)
+	fmt.Fprintf(stdout, "fingerprint: %v\n", info.Fingerprint)
+	fmt.Fprintln(stdout, "players:")
+	for _, p := range info.Players {
+		_, err = fmt.Fprintf(stdout, "  %d %-20v side: %-7v color: %d ip: %v\n", p.Number, p.Name, p.Side, p.Color, p.IP)
+	}
+	return err
+}
+
+func runDump(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("dump", flag.ContinueOnError)
+	namesPath := fs.String("names", "", "gob file of unit names like taesc900.gob")
+	path, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	names, err := loadNames(*namesPath)
+	if err != nil {
+		return err
+	}
+	r, err := openReplay(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return r.check(tad.DumpWorker(r.stream(), stdout, names))
+}
+
+func runScores(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("scores", flag.ContinueOnError)
+	format := fs.String("format", "json", "output format: json or csv")
+	path, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkFormat(*format, "json", "csv"); err != nil {
+		return err
+	}
+	r, err := openReplay(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	series, err := tad.ScoreSeriesWorker(r.stream(), tad.GenPnames(r.game.Players))
+	if err := r.check(err); err != nil {
+		return err
+	}
+	if *format == "json" {
+		return writeJSON(stdout, series)
+	}
+	names := make([]string, 0, len(series))
+	for name := range series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	records := [][]string{{"player", "milliseconds", "metal", "energy", "total_metal", "total_energy", "excess_metal", "excess_energy", "kills", "losses"}}
+	for _, name := range names {
+		for _, s := range series[name] {
+			records = append(records, []string{
+				name,
+				strconv.Itoa(s.Milliseconds),
+				fmt.Sprint(s.Metal),
+				fmt.Sprint(s.Energy),
+				fmt.Sprint(s.TotalM),
+				fmt.Sprint(s.TotalE),
+				fmt.Sprint(s.ExcessM),
+				fmt.Sprint(s.ExcessE),
+				strconv.Itoa(s.Kills),
+				strconv.Itoa(s.Losses),
+			})
+		}
+	}
+	return writeCSV(stdout, records)
+}
+
+type unitRow struct {
+	Player         int    `json:"player"`
+	NetID          int    `json:"netID"`
+	Name           string `json:"name,omitempty"`
+	Produced       int    `json:"produced"`
+	FirstProduced  int    `json:"firstProduced"`
+	Kills          int    `json:"kills"`
+	Deaths         int    `json:"deaths"`
+	DamageDealt    int    `json:"damageDealt"`
+	DamageReceived int    `json:"damageReceived"`
+}
+
+func runUnits(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("units", flag.ContinueOnError)
+	format := fs.String("format", "json", "output format: json or csv")
+	namesPath := fs.String("names", "", "gob file of unit names like taesc900.gob")
+	path, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkFormat(*format, "json", "csv"); err != nil {
+		return err
+	}
+	names, err := loadNames(*namesPath)
+	if err != nil {
+		return err
+	}
+	r, err := openReplay(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	counts, err := tad.UnitCountWorker(r.stream())
+	if err := r.check(err); err != nil {
+		return err
+	}
+	var rows []unitRow
+	for i, uc := range counts {
+		netIDs := make([]int, 0, len(uc))
+		for netID := range uc {
+			netIDs = append(netIDs, netID)
+		}
+		sort.Ints(netIDs)
+		for _, netID := range netIDs {
+			utr := uc[netID]
+			rows = append(rows, unitRow{
+				Player:         i + 1,
+				NetID:          netID,
+				Name:           names[uint16(netID)],
+				Produced:       utr.Produced,
+				FirstProduced:  utr.FirstProduced,
+				Kills:          utr.GetKills(),
+				Deaths:         utr.GetDeaths(),
+				DamageDealt:    utr.DamageDealt,
+				DamageReceived: utr.DamageReceived,
+			})
+		}
+	}
+	if *format == "json" {
+		return writeJSON(stdout, rows)
+	}
+	records := [][]string{{"player", "net_id", "name", "produced", "first_produced", "kills", "deaths", "damage_dealt", "damage_received"}}
+	for _, u := range rows {
+		records = append(records, []string{
+			strconv.Itoa(u.Player),
+			strconv.Itoa(u.NetID),
+			u.Name,
+			strconv.Itoa(u.Produced),
+			strconv.Itoa(u.FirstProduced),
+			strconv.Itoa(u.Kills),
+			strconv.Itoa(u.Deaths),
+			strconv.Itoa(u.DamageDealt),
+			strconv.Itoa(u.DamageReceived),
+		})
+	}
+	return writeCSV(stdout, records)
+}
+
+func runChat(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("chat", flag.ContinueOnError)
+	format := fs.String("format", "json", "output format: json or csv")
+	path, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkFormat(*format, "json", "csv"); err != nil {
+		return err
+	}
+	r, err := openReplay(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	messages, err := tad.ChatWorker(r.stream(), *r.game, tad.ChatOptions{FlagWatchers: true, FlagDead: true})
+	if err := r.check(err); err != nil {
+		return err
+	}
+	if *format == "json" {
+		return writeJSON(stdout, messages)
+	}
+	records := [][]string{{"milliseconds", "sender", "scope", "recipient", "text", "watcher", "dead"}}
+	for _, m := range messages {
+		records = append(records, []string{
+			strconv.Itoa(m.Sent),
+			m.SenderName,
+			m.Scope.String(),
+			m.Recipient,
+			m.Text,
+			strconv.FormatBool(m.Watcher),
+			strconv.FormatBool(m.Dead),
+		})
+	}
+	return writeCSV(stdout, records)
+}
+
+func runGif(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("gif", flag.ContinueOnError)
+	outPath := fs.String("o", "", "file to write the gif to")
+	mapPath := fs.String("map", "", "picture of the map to draw under the units")
+	mapSize := fs.String("mapsize", "", "size of the map in game pixels like 6144x7680")
+	interval := fs.Int("interval", 0, "milliseconds of game time between frames")
+	path, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *outPath == "" {
+		return usageError("-o is required")
+	}
+	// the map picture is usually scaled down so its size says nothing about the map's
+	if *mapSize == "" {
+		return usageError("-mapsize is required")
+	}
+	var w, h int
+	if _, err := fmt.Sscanf(*mapSize, "%dx%d", &w, &h); err != nil || w <= 0 || h <= 0 {
+		return usageError("bad -mapsize %q", *mapSize)
+	}
+	rect := image.Rect(0, 0, w, h)
+	var mapPic image.Image
+	if *mapPath != "" {
+		f, err := os.Open(*mapPath)
+		if err != nil {
+			return &exitError{exitUnreadable, err}
+		}
+		mapPic, _, err = image.Decode(f)
+		f.Close()
+		if err != nil {
+			return usageError("reading map picture: %v", err)
+		}
+	}
+	if mapPic == nil {
+		// only the size is used which leaves the background transparent
+		mapPic = image.Rect(0, 0, 640, 640*rect.Dy()/rect.Dx())
+	}
+	r, err := openReplay(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	opts := tad.RenderOptions{FrameInterval: *interval}
+	frames, err := tad.FramesWorkerWithOptions(r.stream(), r.game.MaxUnits, opts)
+	if err := r.check(err); err != nil {
+		return err
+	}
+	tad.InterpolateUnitMovement(frames)
+	out, err := os.Create(*outPath)
+	if err != nil {
+		return err
+	}
+	if err := r.game.DrawGifWithOptions(out, frames, mapPic, rect, opts); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cosmouser/tad"
+)
+
+// stdin is where the inspector reads commands from
+var stdin io.Reader = os.Stdin
+
+const inspectHelp = `commands:
+  n [count]             next packet
+  p [count]             previous packet
+  s                     show the current packet again
+  g <index>             go to a packet by its index
+  m <move>              go to the first packet of a move
+  t <mm:ss>             go to the first packet at a game time
+  f marker <m,...>      only stop at these markers like 0x0d,0x2c
+  f sender <s,...>      only stop at packets from these players
+  f clear               stop at every packet
+  u <unit id>           show what a unit id was at the current packet
+  h                     show this help
+  q                     quit`
+
+func runInspect(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	namesPath := fs.String("names", "", "gob file of unit names like taesc900.gob")
+	path, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	names, err := loadNames(*namesPath)
+	if err != nil {
+		return err
+	}
+	r, err := openReplay(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	in := tad.NewInspector(r.stream(), names)
+	if in.Len() == 0 {
+		return malformed(fmt.Errorf("no packets in %v", path))
+	}
+	fmt.Fprintf(stdout, "%d packets, h for help\n", in.Len())
+	showPacket(stdout, in.Current())
+	scanner := bufio.NewScanner(stdin)
+	for {
+		fmt.Fprint(stdout, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(stdout)
+			return scanner.Err()
+		}
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "q" {
+			return nil
+		}
+		if err := inspectCommand(stdout, in, fields); err != nil {
+			fmt.Fprintln(stdout, err)
+		}
+	}
+}
+
+// inspectCommand runs one line of the inspector
+func inspectCommand(stdout io.Writer, in *tad.Inspector, fields []string) error {
+	arg := func(i int) string {
+		if i < len(fields) {
+			return fields[i]
+		}
+		return ""
+	}
+	count := func() (int, error) {
+		if arg(1) == "" {
+			return 1, nil
+		}
+		return strconv.Atoi(arg(1))
+	}
+	var found bool
+	switch fields[0] {
+	case "n", "p":
+		n, err := count()
+		if err != nil {
+			return err
+		}
+		if fields[0] == "n" {
+			found = in.Next(n)
+		} else {
+			found = in.Prev(n)
+		}
+	case "s":
+		found = true
+	case "g":
+		i, err := strconv.Atoi(arg(1))
+		if err != nil || i < 0 || i >= in.Len() {
+			return fmt.Errorf("index must be between 0 and %d", in.Len()-1)
+		}
+		in.Pos, found = i, true
+	case "m":
+		move, err := strconv.Atoi(arg(1))
+		if err != nil {
+			return err
+		}
+		found = in.SeekMove(move)
+	case "t":
+		var min, sec int
+		if _, err := fmt.Sscanf(arg(1), "%d:%d", &min, &sec); err != nil {
+			return fmt.Errorf("time must look like mm:ss")
+		}
+		found = in.SeekTime((min*60 + sec) * 1000)
+	case "f":
+		return setFilter(stdout, in, arg(1), arg(2))
+	case "u":
+		id, err := strconv.ParseUint(arg(1), 0, 16)
+		if err != nil {
+			return err
+		}
+		netID, ok := in.Unit(uint16(id), in.Pos)
+		if !ok {
+			return fmt.Errorf("unit %#04x hasn't been seen yet", id)
+		}
+		_, err = fmt.Fprintf(stdout, "unit %#04x is %v (netid %d)\n", id, in.UnitName(uint16(id), in.Pos), netID)
+		return err
+	case "h":
+		_, err := fmt.Fprintln(stdout, inspectHelp)
+		return err
+	default:
+		return fmt.Errorf("unknown command %q, h for help", fields[0])
+	}
+	if !found {
+		fmt.Fprintln(stdout, "no more matching packets")
+	}
+	showPacket(stdout, in.Current())
+	return nil
+}
+
+func setFilter(stdout io.Writer, in *tad.Inspector, kind, list string) error {
+	var values []byte
+	if list != "" {
+		for _, v := range strings.Split(list, ",") {
+			b, err := strconv.ParseUint(v, 0, 8)
+			if err != nil {
+				return err
+			}
+			values = append(values, byte(b))
+		}
+	}
+	switch kind {
+	case "marker":
+		in.Filter.Markers = values
+	case "sender":
+		in.Filter.Senders = values
+	case "clear":
+		in.Filter = tad.InspectFilter{}
+	default:
+		return fmt.Errorf("filter by marker or sender, or clear the filter")
+	}
+	_, err := fmt.Fprintf(stdout, "filter: markers %x senders %v\n", in.Filter.Markers, in.Filter.Senders)
+	return err
+}
+
+func showPacket(w io.Writer, ip tad.InspectedPacket) {
+	fmt.Fprintf(w, "#%d move %d %02d:%02d player %d marker %02x\n",
+		ip.Index,
+		ip.Move,
+		ip.Milliseconds/60000,
+		ip.Milliseconds/1000%60,
+		ip.Sender,
+		ip.Data[0])
+	fmt.Fprintln(w, ip.Message)
+	// unknown markers already have a hex dump in their message
+	if ip.Fields != "" {
+		fmt.Fprintln(w, ip.Fields)
+		fmt.Fprint(w, ip.Hex)
+	}
+}
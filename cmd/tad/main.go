@@ -0,0 +1,210 @@
+// Command tad reads Total Annihilation demo recordings (.ted files).
+//
+// Usage:
+//
+//	tad info [-format text|json] file.ted
+//	tad dump [-names taesc900.gob] file.ted
+//	tad scores [-format json|csv] file.ted
+//	tad units [-format json|csv] [-names taesc900.gob] file.ted
+//	tad chat [-format json|csv] file.ted
+//	tad gif -o out.gif -mapsize 6144x7680 [-map picture.png] [-interval ms] file.ted
+//	tad inspect [-names taesc900.gob] file.ted
+//	tad index [-index index.jsonl] [-workers n] [-names taesc900.gob] [-retry] [-v] directory
+//	tad ladder [-index index.jsonl] [-overrides overrides.json] [-format text|json] [-top n] directory
+//
+// The exit code is 0 on success, 1 when output fails, 2 for bad usage, 3 when the
+// file can't be read and 4 when the file is not a valid recording.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cosmouser/tad"
+	log "github.com/sirupsen/logrus"
+)
+
+// exit codes
+const (
+	exitOK = iota
+	exitFailure
+	exitUsage
+	exitUnreadable
+	exitMalformed
+)
+
+// exitError is an error with the exit code it should end the program with
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func usageError(format string, a ...interface{}) error {
+	return &exitError{exitUsage, fmt.Errorf(format, a...)}
+}
+
+func malformed(err error) error {
+	return &exitError{exitMalformed, err}
+}
+
+type command struct {
+	run   func(args []string, stdout io.Writer) error
+	usage string
+}
+
+var commands = map[string]command{
+	"info":    {runInfo, "print the map, players and recording details"},
+	"dump":    {runDump, "print every packet in the recording"},
+	"scores":  {runScores, "export the score series of each player"},
+	"units":   {runUnits, "export unit counts and kills of each player"},
+	"chat":    {runChat, "export the in-game chat"},
+	"gif":     {runGif, "draw an animation of the game"},
+	"inspect": {runInspect, "step through the packets of the recording"},
+	"index":   {runIndex, "analyze a directory of recordings into an index file"},
+	"ladder":  {runLadder, "rate the players of a directory of recordings"},
+}
+
+func main() {
+	log.SetOutput(os.Stderr)
+	log.SetLevel(log.WarnLevel)
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) (code int) {
+	// a panic is a recording the parser couldn't handle, not bad usage
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(stderr, "tad: %v\n", r)
+			code = exitMalformed
+		}
+	}()
+	if len(args) == 0 {
+		printUsage(stderr)
+		return exitUsage
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "tad: unknown command %q\n", args[0])
+		printUsage(stderr)
+		return exitUsage
+	}
+	if err := cmd.run(args[1:], stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitUsage
+		}
+		fmt.Fprintf(stderr, "tad %v: %v\n", args[0], err)
+		var ee *exitError
+		if errors.As(err, &ee) {
+			return ee.code
+		}
+		return exitFailure
+	}
+	return exitOK
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: tad <command> [flags] file.ted")
+	for _, name := range []string{"info", "dump", "scores", "units", "chat", "gif", "inspect", "index", "ladder"} {
+		fmt.Fprintf(w, "  %-8s %v\n", name, commands[name].usage)
+	}
+}
+
+// parseFlags parses a subcommand's flags and returns the replay path
+func parseFlags(fs *flag.FlagSet, args []string) (string, error) {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return "", usageError("usage: tad %v [flags] file.ted", fs.Name())
+		}
+		return "", usageError("%v", err)
+	}
+	if fs.NArg() != 1 {
+		return "", usageError("expected one .ted file, got %d arguments", fs.NArg())
+	}
+	return fs.Arg(0), nil
+}
+
+// replay is an open recording
+type replay struct {
+	file   *os.File
+	game   *tad.Game
+	prs    <-chan tad.PacketRec
+	cancel context.CancelFunc
+}
+
+func openReplay(path string) (*replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &exitError{exitUnreadable, err}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	gp, prs, err := tad.Analyze(ctx, f)
+	if err != nil {
+		cancel()
+		f.Close()
+		return nil, malformed(err)
+	}
+	return &replay{file: f, game: gp, prs: prs, cancel: cancel}, nil
+}
+
+func (r *replay) Close() error {
+	r.cancel()
+	return r.file.Close()
+}
+
+// check gives the error of a worker, or of the packets ending early, as malformed
+func (r *replay) check(err error) error {
+	if err == nil {
+		err = r.game.Err()
+	}
+	if err != nil {
+		return malformed(err)
+	}
+	return nil
+}
+
+// stream copies the packets into a channel the workers can take
+func (r *replay) stream() chan tad.PacketRec {
+	out := make(chan tad.PacketRec)
+	go func() {
+		defer close(out)
+		for pr := range r.prs {
+			out <- pr
+		}
+	}()
+	return out
+}
+
+// loadNames reads unit names from a gob file. No path gives no names.
+func loadNames(path string) (map[uint16]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &exitError{exitUnreadable, err}
+	}
+	defer f.Close()
+	names, err := tad.LoadUnitNames(f)
+	if err != nil {
+		return nil, usageError("reading unit names: %v", err)
+	}
+	return names, nil
+}
+
+func checkFormat(format string, allowed ...string) error {
+	for _, a := range allowed {
+		if format == a {
+			return nil
+		}
+	}
+	return usageError("unknown format %q", format)
+}
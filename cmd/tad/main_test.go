@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExitCodes(t *testing.T) {
+	garbage := filepath.Join(t.TempDir(), "garbage.ted")
+	if err := os.WriteFile(garbage, []byte("not a recording"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		args []string
+		code int
+	}{
+		{nil, exitUsage},
+		{[]string{"nope"}, exitUsage},
+		{[]string{"info"}, exitUsage},
+		{[]string{"scores", "-format", "xml", garbage}, exitUsage},
+		{[]string{"gif", garbage}, exitUsage},
+		{[]string{"gif", "-o", filepath.Join(t.TempDir(), "out.gif"), garbage}, exitUsage},
+		{[]string{"gif", "-o", filepath.Join(t.TempDir(), "out.gif"), "-mapsize", "100x100", garbage}, exitMalformed},
+		{[]string{"info", filepath.Join(t.TempDir(), "missing.ted")}, exitUnreadable},
+		{[]string{"info", garbage}, exitMalformed},
+		{[]string{"dump", garbage}, exitMalformed},
+		{[]string{"ladder", garbage}, exitUnreadable},
+		{[]string{"ladder", "-format", "xml", t.TempDir()}, exitUsage},
+		{[]string{"ladder", "-index", filepath.Join(t.TempDir(), "index.jsonl"), t.TempDir()}, exitOK},
+	}
+	for _, c := range cases {
+		var stdout, stderr bytes.Buffer
+		if code := run(c.args, &stdout, &stderr); code != c.code {
+			t.Errorf("tad %v exited with %d, wanted %d: %v", c.args, code, c.code, stderr.String())
+		}
+	}
+}
+
+func TestPanicExitCode(t *testing.T) {
+	commands["panic"] = command{func([]string, io.Writer) error { panic("bad packet") }, ""}
+	defer delete(commands, "panic")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"panic"}, &stdout, &stderr); code != exitMalformed {
+		t.Errorf("a panic exited with %d, wanted %d", code, exitMalformed)
+	}
+}
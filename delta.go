@@ -0,0 +1,143 @@
+package tad
+
+import "sort"
+
+// defaultKeyframeInterval is how many frames apart keyframes are when none is given
+const defaultKeyframeInterval = 30
+
+// FrameDelta is how the units changed since the frame before it. Changed has the units
+// that were spawned, moved or changed and Removed has the units that died. Keyframes
+// have every unit in Changed so frames can be rebuilt starting from them.
+type FrameDelta struct {
+	Number   int
+	Time     int
+	Keyframe bool
+	Changed  map[uint16]TAUnit
+	Removed  []uint16
+	Effects  []Effect
+	Cameras  []CameraPosition
+}
+
+// DeltaFrames are the frames of a game stored as deltas with a keyframe every
+// KeyframeInterval frames. They take a fraction of the memory of full frames in
+// long games where most units sit still between frames.
+type DeltaFrames struct {
+	KeyframeInterval int
+	Deltas           []FrameDelta
+}
+
+// DeltaFramesWorker consumes packets from a stream like FramesWorkerWithOptions but
+// keeps only what changed between frames. The options' KeyframeInterval sets how
+// often whole frames are stored.
+func DeltaFramesWorker(stream chan PacketRec, maxUnits int, opts RenderOptions) (df *DeltaFrames, err error) {
+	df = &DeltaFrames{KeyframeInterval: opts.KeyframeInterval}
+	if df.KeyframeInterval <= 0 {
+		df.KeyframeInterval = defaultKeyframeInterval
+	}
+	last := make(map[uint16]TAUnit)
+	err = captureFrames(stream, maxUnits, opts, func(tval int, units map[uint16]*TAUnit, effects []Effect, cameras []CameraPosition) {
+		delta := FrameDelta{
+			Number:   len(df.Deltas),
+			Time:     tval,
+			Keyframe: len(df.Deltas)%df.KeyframeInterval == 0,
+			Changed:  make(map[uint16]TAUnit),
+			Effects:  effects,
+			Cameras:  cameras,
+		}
+		for k, v := range units {
+			if old, ok := last[k]; delta.Keyframe || !ok || old != *v {
+				delta.Changed[k] = *v
+				last[k] = *v
+			}
+		}
+		for k := range last {
+			if _, ok := units[k]; !ok {
+				delete(last, k)
+				if !delta.Keyframe {
+					delta.Removed = append(delta.Removed, k)
+				}
+			}
+		}
+		sort.Slice(delta.Removed, func(i, j int) bool { return delta.Removed[i] < delta.Removed[j] })
+		df.Deltas = append(df.Deltas, delta)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Len returns the number of frames
+func (df *DeltaFrames) Len() int {
+	return len(df.Deltas)
+}
+
+// deltaPlayer rebuilds frames in order by applying one delta at a time
+type deltaPlayer struct {
+	df    *DeltaFrames
+	units map[uint16]TAUnit
+	next  int
+}
+
+// step applies the next delta and returns its frame. The frame's units are copies.
+func (dp *deltaPlayer) step() PlaybackFrame {
+	delta := dp.df.Deltas[dp.next]
+	dp.next++
+	if delta.Keyframe || dp.units == nil {
+		dp.units = make(map[uint16]TAUnit, len(delta.Changed))
+	}
+	for k, v := range delta.Changed {
+		dp.units[k] = v
+	}
+	for _, k := range delta.Removed {
+		delete(dp.units, k)
+	}
+	frame := PlaybackFrame{
+		Number:  delta.Number,
+		Time:    delta.Time,
+		Units:   make(map[uint16]*TAUnit, len(dp.units)),
+		Effects: delta.Effects,
+		Cameras: delta.Cameras,
+	}
+	for k, v := range dp.units {
+		tau := v
+		frame.Units[k] = &tau
+	}
+	return frame
+}
+
+// Frame rebuilds frame i starting from the keyframe before it
+func (df *DeltaFrames) Frame(i int) PlaybackFrame {
+	start := i
+	for start > 0 && !df.Deltas[start].Keyframe {
+		start--
+	}
+	dp := &deltaPlayer{df: df, next: start}
+	frame := dp.step()
+	for dp.next <= i {
+		frame = dp.step()
+	}
+	return frame
+}
+
+// Each rebuilds the frames in order and calls fn with each one. Only the frame being
+// handled is held in memory unless fn keeps it.
+func (df *DeltaFrames) Each(fn func(PlaybackFrame) error) error {
+	dp := &deltaPlayer{df: df}
+	for dp.next < len(df.Deltas) {
+		if err := fn(dp.step()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Frames rebuilds every frame
+func (df *DeltaFrames) Frames() []PlaybackFrame {
+	frames := make([]PlaybackFrame, 0, len(df.Deltas))
+	df.Each(func(f PlaybackFrame) error {
+		frames = append(frames, f)
+		return nil
+	})
+	return frames
+}
@@ -3,12 +3,8 @@ package tad
 import (
 	"image"
 	"image/color"
-	"image/draw"
-	"image/gif"
 	"io"
 	"math"
-	"runtime"
-	"sync"
 	"time"
 
 	"github.com/cosmouser/tnt"
@@ -16,11 +12,6 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-type numberedFrame struct {
-	Number   int
-	Paletted *image.Paletted
-}
-
 type unitClass int
 
 const (
@@ -31,7 +22,7 @@ const (
 	airClass
 )
 
-func drawUnit(dc *gg.Context, t *TAUnit, scale float64, colors []color.RGBA) {
+func drawUnit(dc *gg.Context, t *TAUnit, scale float64, size float64, c color.Color) {
 	dc.SetColor(tnt.TAPalette[0x55])
 	if t == nil || t.Finished == false {
 		return
@@ -39,36 +30,37 @@ func drawUnit(dc *gg.Context, t *TAUnit, scale float64, colors []color.RGBA) {
 	if t.Pos.X < 0 || t.Pos.Y < 0 || t.Pos.X > 65536*2 || t.Pos.Y > 65536*2 {
 		return
 	}
+	x, y := scale*float64(t.Pos.X), scale*float64(t.Pos.Y)
 	switch t.Class {
 	case buildingClass:
-		dc.DrawRectangle(scale*float64(t.Pos.X)-4, scale*float64(t.Pos.Y)-4, 8, 8)
+		dc.DrawRectangle(x-4*size, y-4*size, 8*size, 8*size)
 		dc.Fill()
-		dc.SetColor(colors[t.Owner])
-		dc.DrawRectangle(scale*float64(t.Pos.X)-3, scale*float64(t.Pos.Y)-3, 6, 6)
+		dc.SetColor(c)
+		dc.DrawRectangle(x-3*size, y-3*size, 6*size, 6*size)
 		dc.Fill()
 	case mobileClass:
-		dc.DrawPoint(scale*float64(t.Pos.X), scale*float64(t.Pos.Y), 3.8)
+		dc.DrawPoint(x, y, 3.8*size)
 		dc.Fill()
-		dc.SetColor(colors[t.Owner])
-		dc.DrawPoint(scale*float64(t.Pos.X), scale*float64(t.Pos.Y), 3)
+		dc.SetColor(c)
+		dc.DrawPoint(x, y, 3*size)
 		dc.Fill()
 	case factoryClass:
-		dc.DrawRoundedRectangle(scale*float64(t.Pos.X)-6, scale*float64(t.Pos.Y)-6, 12, 12, 2)
+		dc.DrawRoundedRectangle(x-6*size, y-6*size, 12*size, 12*size, 2*size)
 		dc.Fill()
-		dc.SetColor(colors[t.Owner])
-		dc.DrawRoundedRectangle(scale*float64(t.Pos.X)-5, scale*float64(t.Pos.Y)-5, 10, 10, 2)
+		dc.SetColor(c)
+		dc.DrawRoundedRectangle(x-5*size, y-5*size, 10*size, 10*size, 2*size)
 		dc.Fill()
 	case commanderClass:
-		dc.DrawRegularPolygon(5, scale*float64(t.Pos.X), scale*float64(t.Pos.Y), 5, 0)
+		dc.DrawRegularPolygon(5, x, y, 5*size, 0)
 		dc.Fill()
-		dc.SetColor(colors[t.Owner])
-		dc.DrawRegularPolygon(5, scale*float64(t.Pos.X), scale*float64(t.Pos.Y), 4, 0)
+		dc.SetColor(c)
+		dc.DrawRegularPolygon(5, x, y, 4*size, 0)
 		dc.Fill()
 	case airClass:
-		dc.DrawRegularPolygon(3, scale*float64(t.Pos.X), scale*float64(t.Pos.Y), 5, 0)
+		dc.DrawRegularPolygon(3, x, y, 5*size, 0)
 		dc.Fill()
-		dc.SetColor(colors[t.Owner])
-		dc.DrawRegularPolygon(3, scale*float64(t.Pos.X), scale*float64(t.Pos.Y), 4, 0)
+		dc.SetColor(c)
+		dc.DrawRegularPolygon(3, x, y, 4*size, 0)
 		dc.Fill()
 	}
 	return
@@ -95,131 +87,236 @@ func (t *TAUnit) updatePos(timeVal int) {
 	t.Pos.Time = timeVal
 }
 
-// DrawGif uses a list of PlaybackFrames to create an animation of the game
-func DrawGif(w io.Writer, frames []PlaybackFrame, mapPic image.Image, rect image.Rectangle) error {
-	outGif := gif.GIF{
-		Disposal: make([]byte, len(frames)),
-		Image:    make([]*image.Paletted, len(frames)),
-		Delay:    make([]int, len(frames)),
+// RenderOptions changes how frames are captured and drawn. The zero value captures a
+// frame every 10 seconds of game time and draws the whole game over the map picture
+// as it is at a tenth of a second per frame.
+type RenderOptions struct {
+	Darken     float64 // 0 to 1, how much to darken the map picture
+	Desaturate float64 // 0 to 1, how much to take the color out of the map picture
+
+	FrameInterval    int     // milliseconds of game time between frames in FramesWorkerWithOptions
+	KeyframeInterval int     // frames between whole frames in DeltaFramesWorker, 0 means 30
+	Speed            float64 // seconds of game time played per second of animation
+	Width            int     // output width in pixels, 0 follows the map picture
+	Height           int     // output height in pixels, 0 follows the map picture
+	MarkerScale      float64 // multiplies the size of unit markers, 0 means 1
+	// MapSize is the size of the map in game pixels, which RenderSnapshot needs as the
+	// recording doesn't have it
+	MapSize image.Point
+
+	// ColorMap maps a unit's Owner to a color index before drawing. Leave it nil
+	// for frames that went through SmoothUnitMovement as their owners are colors.
+	ColorMap map[int]int
+	// PlayerColors overrides the color drawn for a color index
+	PlayerColors map[int]color.Color
+
+	Start int // milliseconds, frames before this are skipped
+	End   int // milliseconds, frames after this are skipped, 0 means the end of the game
+
+	HideBuildings  bool
+	HideCommanders bool
+	HideMobile     bool
+	HideFactories  bool
+	HideAir        bool
+
+	EffectWindow int  // milliseconds shots and explosions fade over, 0 means one frame interval
+	HideEffects  bool // leaves out shots and explosions
+	ShowCameras  bool // outlines where each player's screen is
+
+	// Perspective hides enemy units and effects that the player with this owner and
+	// their PerspectiveAllies could not see. 0 shows everything. Owners are player
+	// numbers in frames straight from FramesWorker.
+	Perspective       int
+	PerspectiveAllies []int
+	// Sight has the sight radius of each unit type for Perspective. Units without
+	// one see defaultSight game pixels.
+	Sight UnitTable
+}
+
+// defaultFrameInterval is the game time between frames when none is given
+const defaultFrameInterval = 10000
+
+// playerColors are the colors of the player color indexes in the palette
+var playerColors = []color.RGBA{
+	tnt.TAPalette[252].(color.RGBA),
+	tnt.TAPalette[249].(color.RGBA),
+	tnt.TAPalette[17].(color.RGBA),
+	tnt.TAPalette[250].(color.RGBA),
+	tnt.TAPalette[36].(color.RGBA),
+	tnt.TAPalette[218].(color.RGBA),
+	tnt.TAPalette[208].(color.RGBA),
+	tnt.TAPalette[93].(color.RGBA),
+	tnt.TAPalette[100].(color.RGBA),
+	tnt.TAPalette[210].(color.RGBA),
+}
+
+// inWindow reports whether a frame at tval milliseconds should be drawn
+func (opts *RenderOptions) inWindow(tval int) bool {
+	return tval >= opts.Start && (opts.End == 0 || tval <= opts.End)
+}
+
+// shows reports whether units of the class should be drawn
+func (opts *RenderOptions) shows(class unitClass) bool {
+	switch class {
+	case buildingClass:
+		return !opts.HideBuildings
+	case commanderClass:
+		return !opts.HideCommanders
+	case mobileClass:
+		return !opts.HideMobile
+	case factoryClass:
+		return !opts.HideFactories
+	case airClass:
+		return !opts.HideAir
 	}
-	for i := range outGif.Disposal {
-		outGif.Disposal[i] = gif.DisposalPrevious
+	return true
+}
+
+// unitColor finds the color to draw a unit in. ok is false when the owner has no color.
+func (opts *RenderOptions) unitColor(t *TAUnit) (c color.Color, ok bool) {
+	idx := t.Owner
+	if opts.ColorMap != nil {
+		if idx, ok = opts.ColorMap[t.Owner]; !ok {
+			return nil, false
+		}
+	}
+	if c, ok = opts.PlayerColors[idx]; ok {
+		return c, true
+	}
+	if idx < 0 || idx >= len(playerColors) {
+		return nil, false
+	}
+	return playerColors[idx], true
+}
+
+// markerScale returns the marker size multiplier
+func (opts *RenderOptions) markerScale() float64 {
+	if opts.MarkerScale <= 0 {
+		return 1
 	}
-	for i := range outGif.Delay {
-		outGif.Delay[i] = 10
+	return opts.MarkerScale
+}
+
+// outputSize works out the size of the drawn frames from the map picture and options
+func (opts *RenderOptions) outputSize(mapPic image.Rectangle) (width int, height int) {
+	width, height = opts.Width, opts.Height
+	switch {
+	case width == 0 && height == 0:
+		return mapPic.Dx(), mapPic.Dy()
+	case height == 0:
+		height = int(math.Round(float64(width) * float64(mapPic.Dy()) / float64(mapPic.Dx())))
+	case width == 0:
+		width = int(math.Round(float64(height) * float64(mapPic.Dx()) / float64(mapPic.Dy())))
 	}
-	maxDim := math.Max(float64(mapPic.Bounds().Size().X), float64(mapPic.Bounds().Size().Y))
-	var scale float64
+	return
+}
+
+// unitScale works out how much to scale unit positions by to fit a map of size rect in
+// the output picture
+func (opts *RenderOptions) unitScale(mapPic image.Rectangle, rect image.Rectangle) float64 {
+	outW, outH := opts.outputSize(mapPic)
+	maxDim := math.Max(float64(outW), float64(outH))
 	if rect.Size().X > rect.Size().Y {
-		scale = maxDim / float64(rect.Size().X)
-	} else {
-		scale = maxDim / float64(rect.Size().Y)
+		return maxDim / float64(rect.Size().X)
 	}
-	ts1 := time.Now()
-	gifPalette := tnt.TAPalette
-	gifPalette[0] = image.Transparent
-	playerColors := []color.RGBA{
-		tnt.TAPalette[252].(color.RGBA),
-		tnt.TAPalette[249].(color.RGBA),
-		tnt.TAPalette[17].(color.RGBA),
-		tnt.TAPalette[250].(color.RGBA),
-		tnt.TAPalette[36].(color.RGBA),
-		tnt.TAPalette[218].(color.RGBA),
-		tnt.TAPalette[208].(color.RGBA),
-		tnt.TAPalette[93].(color.RGBA),
-		tnt.TAPalette[100].(color.RGBA),
-		tnt.TAPalette[210].(color.RGBA),
-	}
-	frameGen := func() <-chan PlaybackFrame {
-		frameStream := make(chan PlaybackFrame)
-		go func() {
-			defer close(frameStream)
-			for i := range frames {
-				frameStream <- frames[i]
-			}
-		}()
-		return frameStream
-	}
-	done := make(chan interface{})
-	frameStream := frameGen()
-
-	frameDrawer := func(done <-chan interface{}, frameStream <-chan PlaybackFrame) <-chan numberedFrame {
-		palettedStream := make(chan numberedFrame)
-		go func() {
-			defer close(palettedStream)
-			for {
-				select {
-				case <-done:
-					return
-				case incomingFrame := <-frameStream:
-					dc := gg.NewContext(mapPic.Bounds().Size().X, mapPic.Bounds().Size().Y)
-					for _, tau := range incomingFrame.Units {
-						drawUnit(dc, tau, scale, playerColors)
-					}
-					imgItem := dc.Image()
-					palettedImage := image.NewPaletted(imgItem.Bounds(), gifPalette)
-					draw.Draw(palettedImage, palettedImage.Rect, imgItem, imgItem.Bounds().Min, draw.Over)
-					palettedStream <- numberedFrame{
-						Number:   incomingFrame.Number,
-						Paletted: palettedImage,
-					}
-				}
-			}
-		}()
-		return palettedStream
-	}
-	fanIn := func(done <-chan interface{}, channels ...<-chan numberedFrame) <-chan numberedFrame {
-		var wg sync.WaitGroup
-		multiplexedStream := make(chan numberedFrame)
-		multiplex := func(c <-chan numberedFrame) {
-			defer wg.Done()
-			for i := range c {
-				select {
-				case <-done:
-					return
-				case multiplexedStream <- i:
-				}
-			}
+	return maxDim / float64(rect.Size().Y)
+}
+
+// drawUnits draws the units of a frame that the options allow
+func (opts *RenderOptions) drawUnits(dc *gg.Context, units map[uint16]*TAUnit, scale float64) {
+	size := opts.markerScale()
+	for _, tau := range units {
+		if tau == nil || !opts.shows(tau.Class) {
+			continue
 		}
-		wg.Add(len(channels))
-		for _, c := range channels {
-			go multiplex(c)
+		if c, ok := opts.unitColor(tau); ok {
+			drawUnit(dc, tau, scale, size, c)
 		}
-		go func() {
-			wg.Wait()
-			close(multiplexedStream)
-		}()
-		return multiplexedStream
-	}
-	numDrawers := runtime.NumCPU()
-	drawers := make([]<-chan numberedFrame, numDrawers)
-	for i := 0; i < numDrawers; i++ {
-		drawers[i] = frameDrawer(done, frameStream)
-	}
-	frameStatus := make([]bool, len(frames))
-	multiplexedStream := fanIn(done, drawers...)
-	for f := range multiplexedStream {
-		outGif.Image[f.Number] = f.Paletted
-		frameStatus[f.Number] = true
-		finished := false
-		for _, v := range frameStatus {
-			if v {
-				finished = true
-			} else {
-				finished = false
-				break
-			}
+	}
+}
+
+// frameDelays works out the delay in hundredths of a second of each frame from the
+// game time of the frames
+func (opts *RenderOptions) frameDelays(times []int) []int {
+	delays := make([]int, len(times))
+	for i := range delays {
+		delays[i] = 10
+		if opts.Speed <= 0 {
+			continue
+		}
+		gap := opts.FrameInterval
+		if i+1 < len(times) {
+			gap = times[i+1] - times[i]
+		} else if i > 0 {
+			gap = times[i] - times[i-1]
 		}
-		if finished {
-			break
+		if gap <= 0 {
+			gap = defaultFrameInterval
+		}
+		// most viewers don't play delays under 2 at the given speed
+		delays[i] = int(math.Max(2, math.Round(float64(gap)/opts.Speed/10)))
+	}
+	return delays
+}
+
+// windowFrames returns the frames inside the time window renumbered from zero
+func (opts *RenderOptions) windowFrames(frames []PlaybackFrame) []PlaybackFrame {
+	if opts.Start == 0 && opts.End == 0 {
+		return frames
+	}
+	var out []PlaybackFrame
+	for _, f := range frames {
+		if opts.inWindow(f.Time) {
+			f.Number = len(out)
+			out = append(out, f)
 		}
 	}
+	return out
+}
+
+// DrawGif uses a list of PlaybackFrames to create an animation of the game
+func DrawGif(w io.Writer, frames []PlaybackFrame, mapPic image.Image, rect image.Rectangle) error {
+	return DrawGifWithOptions(w, frames, mapPic, rect, RenderOptions{})
+}
 
+// DrawGifWithOptions draws an animation of the game over the map picture. When mapPic is
+// an image.Rectangle only its size is used and the background is left transparent.
+// Frames are streamed to the encoder as they are drawn.
+func DrawGifWithOptions(w io.Writer, frames []PlaybackFrame, mapPic image.Image, rect image.Rectangle, opts RenderOptions) error {
+	ts1 := time.Now()
+	frames = opts.windowFrames(frames)
+	if err := RenderFrames(NewGifSink(w, false), frames, mapPic, rect, opts); err != nil {
+		return err
+	}
 	log.Printf("drawing %d frames took %v at %f fps", len(frames), time.Since(ts1), float64(len(frames))/time.Since(ts1).Seconds())
-	gif.EncodeAll(w, &outGif)
 	log.WithFields(log.Fields{
 		"numFrames": len(frames),
 	}).Info()
-
 	return nil
 }
+
+// mapPicture scales the map picture to the output size after applying the darken and
+// desaturate options. A map picture that is only an image.Rectangle is left transparent.
+func mapPicture(mapPic image.Image, opts RenderOptions) *image.RGBA {
+	width, height := opts.outputSize(mapPic.Bounds())
+	if _, ok := mapPic.(image.Rectangle); ok {
+		return image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+	darken := math.Min(math.Max(opts.Darken, 0), 1)
+	desaturate := math.Min(math.Max(opts.Desaturate, 0), 1)
+	dc := gg.NewContext(width, height)
+	dc.Scale(float64(width)/float64(mapPic.Bounds().Dx()), float64(height)/float64(mapPic.Bounds().Dy()))
+	dc.DrawImage(mapPic, -mapPic.Bounds().Min.X, -mapPic.Bounds().Min.Y)
+	adjusted := dc.Image().(*image.RGBA)
+	if darken > 0 || desaturate > 0 {
+		for i := 0; i < len(adjusted.Pix); i += 4 {
+			r, g, b := float64(adjusted.Pix[i]), float64(adjusted.Pix[i+1]), float64(adjusted.Pix[i+2])
+			gray := 0.299*r + 0.587*g + 0.114*b
+			r = (r + (gray-r)*desaturate) * (1 - darken)
+			g = (g + (gray-g)*desaturate) * (1 - darken)
+			b = (b + (gray-b)*desaturate) * (1 - darken)
+			adjusted.Pix[i], adjusted.Pix[i+1], adjusted.Pix[i+2] = uint8(r), uint8(g), uint8(b)
+		}
+	}
+	return adjusted
+}
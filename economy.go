@@ -0,0 +1,207 @@
+package tad
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// ResourceEventKind says which resource an event is about and whether it ran out or overflowed
+type ResourceEventKind int
+
+// ResourceEventKind values reported by ResourceWorker
+const (
+	MetalStall ResourceEventKind = iota
+	EnergyStall
+	MetalOverflow
+	EnergyOverflow
+)
+
+func (k ResourceEventKind) String() string {
+	switch k {
+	case MetalStall:
+		return "metal stall"
+	case EnergyStall:
+		return "energy stall"
+	case MetalOverflow:
+		return "metal overflow"
+	case EnergyOverflow:
+		return "energy overflow"
+	}
+	return fmt.Sprintf("resource event(%d)", int(k))
+}
+
+// ResourceEvent is a period of time where a player's storage was empty or full.
+// Wasted is the excess produced during an overflow.
+type ResourceEvent struct {
+	Kind   ResourceEventKind
+	Start  int // milliseconds
+	End    int // milliseconds
+	Wasted float64
+}
+
+// Duration returns the length of the event in milliseconds
+func (re *ResourceEvent) Duration() int {
+	return re.End - re.Start
+}
+
+// Callout describes the event for a coach
+func (re *ResourceEvent) Callout() string {
+	span := fmt.Sprintf("between minute %d and %d", re.Start/60000, re.End/60000)
+	switch re.Kind {
+	case MetalOverflow:
+		return fmt.Sprintf("floated %.0f metal %v", re.Wasted, span)
+	case EnergyOverflow:
+		return fmt.Sprintf("floated %.0f energy %v", re.Wasted, span)
+	}
+	return fmt.Sprintf("%v for %d seconds %v", re.Kind, re.Duration()/1000, span)
+}
+
+// ResourceReport has a player's storage over time and the stalls and overflows found in it.
+// Stalled and Overflowed are in milliseconds.
+type ResourceReport struct {
+	Samples          []SPLite
+	Events           []ResourceEvent
+	MetalStalled     int
+	EnergyStalled    int
+	MetalOverflowed  int
+	EnergyOverflowed int
+	WastedM          float64
+	WastedE          float64
+}
+
+// Callouts describes each event of the report
+func (rr *ResourceReport) Callouts() []string {
+	out := make([]string, len(rr.Events))
+	for i := range rr.Events {
+		out[i] = rr.Events[i].Callout()
+	}
+	return out
+}
+
+// stallThreshold is the fraction of storage under which a player counts as stalled
+// and overflowThreshold is the fraction over which storage counts as full
+const (
+	stallThreshold    = 0.01
+	overflowThreshold = 0.99
+)
+
+type resourceTracker struct {
+	report *ResourceReport
+	open   [4]*ResourceEvent
+	last   *SPLite
+}
+
+// set opens or closes the event of kind k at the sample's time
+func (rt *resourceTracker) set(k ResourceEventKind, active bool, sample SPLite, wasted float64) {
+	ev := rt.open[k]
+	switch {
+	case active && ev == nil:
+		rt.open[k] = &ResourceEvent{Kind: k, Start: sample.Milliseconds, End: sample.Milliseconds, Wasted: wasted}
+	case active:
+		ev.End = sample.Milliseconds
+		ev.Wasted += wasted
+	case ev != nil:
+		ev.End = sample.Milliseconds
+		ev.Wasted += wasted
+		rt.close(k)
+	}
+}
+
+func (rt *resourceTracker) close(k ResourceEventKind) {
+	ev := rt.open[k]
+	if ev == nil {
+		return
+	}
+	rt.open[k] = nil
+	rt.report.Events = append(rt.report.Events, *ev)
+	switch k {
+	case MetalStall:
+		rt.report.MetalStalled += ev.Duration()
+	case EnergyStall:
+		rt.report.EnergyStalled += ev.Duration()
+	case MetalOverflow:
+		rt.report.MetalOverflowed += ev.Duration()
+		rt.report.WastedM += ev.Wasted
+	case EnergyOverflow:
+		rt.report.EnergyOverflowed += ev.Duration()
+		rt.report.WastedE += ev.Wasted
+	}
+}
+
+func (rt *resourceTracker) add(sample SPLite) {
+	rt.report.Samples = append(rt.report.Samples, sample)
+	// storage hasn't been reported yet
+	if sample.StorageM == 0 && sample.StorageE == 0 {
+		return
+	}
+	var growM, growE float64
+	if rt.last != nil {
+		growM = sample.ExcessM - rt.last.ExcessM
+		growE = sample.ExcessE - rt.last.ExcessE
+	}
+	// a resource without storage can't be stalled or overflowing
+	hasM, hasE := sample.StorageM > 0, sample.StorageE > 0
+	rt.set(MetalStall, hasM && sample.StoredM <= sample.StorageM*stallThreshold, sample, 0)
+	rt.set(EnergyStall, hasE && sample.StoredE <= sample.StorageE*stallThreshold, sample, 0)
+	rt.set(MetalOverflow, hasM && sample.StoredM >= sample.StorageM*overflowThreshold && growM > 0, sample, growM)
+	rt.set(EnergyOverflow, hasE && sample.StoredE >= sample.StorageE*overflowThreshold && growE > 0, sample, growE)
+	rt.last = &sample
+}
+
+// ResourceWorker consumes 0x28 packets from a stream and reports when each player
+// was stalled or overflowing their storage
+func ResourceWorker(stream chan PacketRec, pnameMap map[byte]string) (reports map[string]*ResourceReport, err error) {
+	reports = make(map[string]*ResourceReport)
+	trackers := make(map[string]*resourceTracker)
+	var (
+		scorePacket packet0x28
+		clock       int
+		lastMove    int
+	)
+	for pr := range stream {
+		if pr.Move != lastMove {
+			clock += int(pr.Time)
+			lastMove = pr.Move
+		}
+		if pr.Data[0] != 0x28 {
+			continue
+		}
+		name, ok := pnameMap[pr.Sender]
+		if !ok {
+			continue
+		}
+		err = binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, &scorePacket)
+		if err != nil {
+			return nil, err
+		}
+		if trackers[name] == nil {
+			reports[name] = &ResourceReport{}
+			trackers[name] = &resourceTracker{report: reports[name]}
+		}
+		trackers[name].add(SPLite{
+			Kills:        int(scorePacket.Kills),
+			Losses:       int(scorePacket.Losses),
+			TotalE:       float64(scorePacket.TotalE),
+			TotalM:       float64(scorePacket.TotalM),
+			ExcessE:      float64(scorePacket.ExcessE),
+			ExcessM:      float64(scorePacket.ExcessM),
+			StoredM:      float64(scorePacket.StoredM),
+			StoredE:      float64(scorePacket.StoredE),
+			StorageM:     float64(scorePacket.StorageM),
+			StorageE:     float64(scorePacket.StorageE),
+			Milliseconds: clock,
+		})
+	}
+	// close events that last until the end of the game
+	for _, rt := range trackers {
+		for k := range rt.open {
+			rt.close(ResourceEventKind(k))
+		}
+		sort.SliceStable(rt.report.Events, func(i, j int) bool {
+			return rt.report.Events[i].Start < rt.report.Events[j].Start
+		})
+	}
+	return
+}
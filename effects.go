@@ -0,0 +1,140 @@
+package tad
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+
+	"github.com/fogleman/gg"
+)
+
+// EffectKind is the kind of a short lived event drawn over the units
+type EffectKind int
+
+// EffectKind values collected by FramesWorkerWithOptions
+const (
+	ShotEffect EffectKind = iota
+	ExplosionEffect
+)
+
+func (k EffectKind) String() string {
+	if k == ExplosionEffect {
+		return "explosion"
+	}
+	return "shot"
+}
+
+// Effect is a shot from 0x0d packets or an explosion from 0x10 packets. Owner is the
+// player of the shooter and a shot travels from X, Y to ToX, ToY.
+type Effect struct {
+	Kind  EffectKind
+	Owner int
+	X     int
+	Y     int
+	ToX   int
+	ToY   int
+	Time  int // milliseconds
+}
+
+// effectFromPacket makes an effect out of a 0x0d or 0x10 packet. Units are the tracked
+// units before the packet is applied. ok is false for other packets and explosions of
+// units that aren't known.
+func effectFromPacket(pr PacketRec, units map[uint16]*TAUnit, clock int) (ef Effect, ok bool, err error) {
+	switch pr.Data[0] {
+	case 0x0d:
+		tmp := &packet0x0d{}
+		if err = binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+			return
+		}
+		ef = Effect{
+			Kind:  ShotEffect,
+			Owner: int(pr.Sender),
+			X:     int(tmp.OriginX),
+			Y:     int(tmp.OriginY),
+			ToX:   int(tmp.DestX),
+			ToY:   int(tmp.DestY),
+			Time:  clock,
+		}
+		if tau, found := units[tmp.ShooterID]; found && tau != nil {
+			ef.Owner = tau.Owner
+		}
+		return ef, true, nil
+	case 0x10:
+		tmp := &packet0x10{}
+		if err = binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+			return
+		}
+		tau, found := units[tmp.UnitID]
+		if !found || tau == nil {
+			return
+		}
+		ef = Effect{
+			Kind:  ExplosionEffect,
+			Owner: tau.Owner,
+			X:     tau.Pos.X,
+			Y:     tau.Pos.Y,
+			ToX:   tau.Pos.X,
+			ToY:   tau.Pos.Y,
+			Time:  clock,
+		}
+		return ef, true, nil
+	}
+	return
+}
+
+// effectWindow returns how long in milliseconds effects stay on screen
+func (opts *RenderOptions) effectWindow() int {
+	switch {
+	case opts.EffectWindow > 0:
+		return opts.EffectWindow
+	case opts.FrameInterval > 0:
+		return opts.FrameInterval
+	}
+	return defaultFrameInterval
+}
+
+// recentEffects returns a copy of the effects that are still on screen at tval
+func (opts *RenderOptions) recentEffects(effects []Effect, tval int) []Effect {
+	window := opts.effectWindow()
+	var out []Effect
+	for _, ef := range effects {
+		if ef.Time <= tval && tval-ef.Time < window {
+			out = append(out, ef)
+		}
+	}
+	return out
+}
+
+// drawEffects draws the effects that are on screen at tval in their owner's color.
+// They fade out as they get older.
+func (opts *RenderOptions) drawEffects(dc *gg.Context, effects []Effect, tval int, scale float64) {
+	if opts.HideEffects {
+		return
+	}
+	window := float64(opts.effectWindow())
+	size := opts.markerScale()
+	for _, ef := range effects {
+		age := float64(tval - ef.Time)
+		if age < 0 || age >= window {
+			continue
+		}
+		c, ok := opts.unitColor(&TAUnit{Owner: ef.Owner})
+		if !ok {
+			continue
+		}
+		fade := 1 - age/window
+		r, g, b, _ := c.RGBA()
+		faded := color.NRGBA{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(0xff * fade)}
+		dc.SetColor(faded)
+		switch ef.Kind {
+		case ShotEffect:
+			dc.SetLineWidth(size)
+			dc.DrawLine(scale*float64(ef.X), scale*float64(ef.Y), scale*float64(ef.ToX), scale*float64(ef.ToY))
+			dc.Stroke()
+		case ExplosionEffect:
+			// bursts grow as they fade
+			dc.DrawCircle(scale*float64(ef.X), scale*float64(ef.Y), (3+5*(1-fade))*size)
+			dc.Fill()
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package tad
+
+import (
+	"bytes"
+	"fmt"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// EventKind is what a PacketEvent says happened
+type EventKind int
+
+// EventKind values found by EventDecoder
+const (
+	OtherEvent EventKind = iota
+	UnitSpawnedEvent
+	UnitBuiltEvent
+	UnitKilledEvent
+	UnitDamagedEvent
+	UnitStateEvent
+	ShotEvent
+	ChatEvent
+	ScoreEvent
+	AllianceEvent
+	CameraEvent
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case UnitSpawnedEvent:
+		return "unit spawned"
+	case UnitBuiltEvent:
+		return "unit built"
+	case UnitKilledEvent:
+		return "unit killed"
+	case UnitDamagedEvent:
+		return "unit damaged"
+	case UnitStateEvent:
+		return "unit state"
+	case ShotEvent:
+		return "shot"
+	case ChatEvent:
+		return "chat"
+	case ScoreEvent:
+		return "score"
+	case AllianceEvent:
+		return "alliance"
+	case CameraEvent:
+		return "camera"
+	}
+	return "other"
+}
+
+// PacketEvent is a packet decoded into what happened in the game. Which fields are set
+// depends on Kind:
+//
+//	UnitSpawnedEvent  Unit, NetID, X, Y
+//	UnitBuiltEvent    Unit and the builder in Other
+//	UnitKilledEvent   Unit and the destroyer in Other
+//	UnitDamagedEvent  Unit, the damager in Other and the damage in Amount
+//	UnitStateEvent    Unit and the state in Amount
+//	ShotEvent         Unit shooting from X, Y at ToX, ToY
+//	ChatEvent         Text
+//	ScoreEvent        Score
+//	AllianceEvent     Player allying or unallying Ally by their TDPIDs, Allied
+//	CameraEvent       X, Y of the sender's screen
+//	OtherEvent        Data
+type PacketEvent struct {
+	Kind         EventKind
+	Index        int
+	Move         int
+	Milliseconds int
+	Sender       byte
+	Marker       byte
+	Unit         uint16
+	Other        uint16
+	NetID        uint16
+	X, Y         int
+	ToX, ToY     int
+	Amount       int
+	Text         string
+	Score        SPLite
+	Player, Ally int32
+	Allied       bool
+	Data         []byte
+}
+
+// EventDecoder turns a stream of packets into PacketEvents, keeping the game clock
+type EventDecoder struct {
+	index    int
+	clock    int
+	lastMove int
+}
+
+// Decode decodes the next packet of the stream. A packet that can't be decoded, like
+// one cut short, is given as an OtherEvent with its data along with the error.
+func (d *EventDecoder) Decode(pr PacketRec) (ev PacketEvent, err error) {
+	if pr.Move != d.lastMove {
+		d.clock += int(pr.Time)
+		d.lastMove = pr.Move
+	}
+	ev = PacketEvent{
+		Index:        d.index,
+		Move:         pr.Move,
+		Milliseconds: d.clock,
+		Sender:       pr.Sender,
+		Marker:       pr.Data[0],
+	}
+	d.index++
+	tap, err := loadTAPacket(pr.Data)
+	if err != nil {
+		ev.Data = pr.Data
+		return ev, fmt.Errorf("decoding %02x packet of move %d: %v", pr.Data[0], pr.Move, err)
+	}
+	switch tmp := tap.(type) {
+	case *packet0x09:
+		ev.Kind, ev.Unit, ev.NetID, ev.X, ev.Y = UnitSpawnedEvent, tmp.UnitID, tmp.NetID, int(tmp.XPos), int(tmp.YPos)
+	case *packet0x12:
+		ev.Kind, ev.Unit, ev.Other = UnitBuiltEvent, tmp.BuiltID, tmp.BuiltByID
+	case *packet0x0c:
+		ev.Kind, ev.Unit, ev.Other = UnitKilledEvent, tmp.Destroyed, tmp.Destroyer
+	case *packet0x0b:
+		ev.Kind, ev.Unit, ev.Other, ev.Amount = UnitDamagedEvent, tmp.DamagedID, tmp.DamagerID, int(tmp.Damage)
+	case *packet0x11:
+		ev.Kind, ev.Unit, ev.Amount = UnitStateEvent, tmp.UnitID, int(tmp.State)
+	case *packet0x0d:
+		ev.Kind, ev.Unit = ShotEvent, tmp.ShooterID
+		ev.X, ev.Y, ev.ToX, ev.ToY = int(tmp.OriginX), int(tmp.OriginY), int(tmp.DestX), int(tmp.DestY)
+	case *packet0x05:
+		text, err := charmap.Windows1252.NewDecoder().Bytes(bytes.Split(tmp.Message[:], []byte{0x00})[0])
+		if err != nil {
+			ev.Data = pr.Data
+			return ev, fmt.Errorf("decoding %02x packet of move %d: %v", pr.Data[0], pr.Move, err)
+		}
+		ev.Kind, ev.Text = ChatEvent, string(text)
+	case *packet0x28:
+		ev.Kind = ScoreEvent
+		ev.Score = SPLite{
+			Kills:        int(tmp.Kills),
+			Losses:       int(tmp.Losses),
+			TotalE:       float64(tmp.TotalE),
+			TotalM:       float64(tmp.TotalM),
+			ExcessE:      float64(tmp.ExcessE),
+			ExcessM:      float64(tmp.ExcessM),
+			StoredM:      float64(tmp.StoredM),
+			StoredE:      float64(tmp.StoredE),
+			StorageM:     float64(tmp.StorageM),
+			StorageE:     float64(tmp.StorageE),
+			Milliseconds: d.clock,
+		}
+	case *packet0x23:
+		ev.Kind, ev.Player, ev.Ally, ev.Allied = AllianceEvent, tmp.Player, tmp.Allied, tmp.Status == 1
+	case *packet0xfc:
+		ev.Kind, ev.X, ev.Y = CameraEvent, int(tmp.XPos), int(tmp.YPos)
+	default:
+		ev.Data = pr.Data
+	}
+	return ev, nil
+}
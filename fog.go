@@ -0,0 +1,78 @@
+package tad
+
+// defaultSight is the sight radius in game pixels of units missing from the sight table
+const defaultSight = 400
+
+// sightCircle is the area around a unit that it can see
+type sightCircle struct {
+	x, y, r int
+}
+
+// vision is what the perspective player and their allies can see
+type vision []sightCircle
+
+// sees reports whether a game position is inside any sight circle
+func (v vision) sees(x, y int) bool {
+	for _, c := range v {
+		dx, dy := x-c.x, y-c.y
+		if dx*dx+dy*dy <= c.r*c.r {
+			return true
+		}
+	}
+	return false
+}
+
+// friendly reports whether units of the owner share vision with the perspective player
+func (opts *RenderOptions) friendly(owner int) bool {
+	if owner == opts.Perspective {
+		return true
+	}
+	for _, ally := range opts.PerspectiveAllies {
+		if owner == ally {
+			return true
+		}
+	}
+	return false
+}
+
+// visionOf estimates what the perspective player could see from their finished units
+func (opts *RenderOptions) visionOf(units map[uint16]*TAUnit) vision {
+	var v vision
+	for _, tau := range units {
+		if tau == nil || !tau.Finished || !opts.friendly(tau.Owner) {
+			continue
+		}
+		r := defaultSight
+		if info, ok := opts.Sight[tau.NetID]; ok && info.Sight > 0 {
+			r = info.Sight
+		}
+		v = append(v, sightCircle{tau.Pos.X, tau.Pos.Y, r})
+	}
+	return v
+}
+
+// perspective removes the enemy units and effects the perspective player could not see.
+// The units and effects are returned as they are when there is no perspective.
+func (opts *RenderOptions) perspective(units map[uint16]*TAUnit, effects []Effect) (map[uint16]*TAUnit, []Effect) {
+	if opts.Perspective == 0 {
+		return units, effects
+	}
+	v := opts.visionOf(units)
+	seen := make(map[uint16]*TAUnit, len(units))
+	for k, tau := range units {
+		if tau == nil {
+			continue
+		}
+		if opts.friendly(tau.Owner) || v.sees(tau.Pos.X, tau.Pos.Y) {
+			seen[k] = tau
+		}
+	}
+	var seenEffects []Effect
+	for _, ef := range effects {
+		// a shot is seen when either end of it is
+		if opts.friendly(ef.Owner) || v.sees(ef.X, ef.Y) || v.sees(ef.ToX, ef.ToY) {
+			seenEffects = append(seenEffects, ef)
+		}
+	}
+	return seen, seenEffects
+}
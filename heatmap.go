@@ -0,0 +1,198 @@
+package tad
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// defaultCellSize is the size of a heatmap cell in game pixels when none is given
+const defaultCellSize = 64
+
+// presenceSampleInterval is how often in milliseconds unit positions are added to
+// the presence heatmaps
+const presenceSampleInterval = 1000
+
+// Heatmap is a grid of values over the map. Each cell covers CellSize by CellSize
+// game pixels and Values is in row order.
+type Heatmap struct {
+	CellSize int
+	Columns  int
+	Rows     int
+	Values   []float64
+}
+
+// NewHeatmap creates an empty heatmap covering a map of size rect
+func NewHeatmap(rect image.Rectangle, cellSize int) *Heatmap {
+	if cellSize <= 0 {
+		cellSize = defaultCellSize
+	}
+	hm := &Heatmap{
+		CellSize: cellSize,
+		Columns:  (rect.Dx() + cellSize - 1) / cellSize,
+		Rows:     (rect.Dy() + cellSize - 1) / cellSize,
+	}
+	hm.Values = make([]float64, hm.Columns*hm.Rows)
+	return hm
+}
+
+// Add adds v to the cell at game position x, y. Positions off the map are ignored.
+func (hm *Heatmap) Add(x, y int, v float64) {
+	if x < 0 || y < 0 {
+		return
+	}
+	col, row := x/hm.CellSize, y/hm.CellSize
+	if col >= hm.Columns || row >= hm.Rows {
+		return
+	}
+	hm.Values[row*hm.Columns+col] += v
+}
+
+// At returns the value of the cell at game position x, y
+func (hm *Heatmap) At(x, y int) float64 {
+	if x < 0 || y < 0 || x/hm.CellSize >= hm.Columns || y/hm.CellSize >= hm.Rows {
+		return 0
+	}
+	return hm.Values[(y/hm.CellSize)*hm.Columns+x/hm.CellSize]
+}
+
+// Max returns the largest value in the heatmap
+func (hm *Heatmap) Max() (max float64) {
+	for _, v := range hm.Values {
+		max = math.Max(max, v)
+	}
+	return
+}
+
+// Merge adds the values of another heatmap of the same size to hm
+func (hm *Heatmap) Merge(other *Heatmap) {
+	if other == nil || len(other.Values) != len(hm.Values) {
+		return
+	}
+	for i, v := range other.Values {
+		hm.Values[i] += v
+	}
+}
+
+// HeatmapLayers has a heatmap per player number for each kind of activity.
+// Presence is in milliseconds that units spent in a cell, Deaths is the number of
+// units lost in a cell and Damage is the damage units took in a cell.
+type HeatmapLayers struct {
+	Presence map[int]*Heatmap
+	Deaths   map[int]*Heatmap
+	Damage   map[int]*Heatmap
+}
+
+// layer returns the player's heatmap from a layer and creates it when it is missing
+func layer(hms map[int]*Heatmap, player int, rect image.Rectangle, cellSize int) *Heatmap {
+	if hms[player] == nil {
+		hms[player] = NewHeatmap(rect, cellSize)
+	}
+	return hms[player]
+}
+
+// HeatmapWorker consumes packets from a stream and builds heatmaps of where each player's
+// units were, died and took damage on a map of size rect. Unit positions come from the
+// same packets FramesWorker uses.
+func HeatmapWorker(stream chan PacketRec, maxUnits int, rect image.Rectangle, cellSize int) (layers *HeatmapLayers, err error) {
+	layers = &HeatmapLayers{
+		Presence: make(map[int]*Heatmap),
+		Deaths:   make(map[int]*Heatmap),
+		Damage:   make(map[int]*Heatmap),
+	}
+	ut := newUnitTracker(maxUnits)
+	var clock, lastSample, lastMove int
+	for pr := range stream {
+		if pr.Move != lastMove {
+			clock += int(pr.Time)
+			lastMove = pr.Move
+		}
+		if clock-lastSample >= presenceSampleInterval {
+			for _, tau := range ut.units {
+				if tau.Finished {
+					layer(layers.Presence, tau.Owner, rect, cellSize).Add(tau.Pos.X, tau.Pos.Y, float64(clock-lastSample))
+				}
+			}
+			lastSample = clock
+		}
+		switch pr.Data[0] {
+		case 0x0c:
+			tmp := &packet0x0c{}
+			if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+				return nil, err
+			}
+			if tau, ok := ut.units[tmp.Destroyed]; ok && tau != nil {
+				layer(layers.Deaths, tau.Owner, rect, cellSize).Add(tau.Pos.X, tau.Pos.Y, 1)
+			}
+		case 0x0b:
+			tmp := &packet0x0b{}
+			if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+				return nil, err
+			}
+			if tau, ok := ut.units[tmp.DamagedID]; ok && tau != nil && tmp.Unknown2 == 1 {
+				layer(layers.Damage, tau.Owner, rect, cellSize).Add(tau.Pos.X, tau.Pos.Y, float64(tmp.Damage))
+			}
+		}
+		if err := ut.update(pr, clock); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// heatColor maps a value from 0 to 1 on to a blue, green, yellow and red ramp that
+// becomes more opaque as it gets hotter
+func heatColor(v float64) color.NRGBA {
+	v = math.Min(math.Max(v, 0), 1)
+	stops := []color.NRGBA{
+		{0x00, 0x00, 0xff, 0x00},
+		{0x00, 0xff, 0x00, 0x90},
+		{0xff, 0xff, 0x00, 0xb0},
+		{0xff, 0x00, 0x00, 0xd0},
+	}
+	pos := v * float64(len(stops)-1)
+	i := int(pos)
+	if i >= len(stops)-1 {
+		return stops[len(stops)-1]
+	}
+	f := pos - float64(i)
+	lerp := func(a, b uint8) uint8 {
+		return uint8(float64(a) + (float64(b)-float64(a))*f)
+	}
+	return color.NRGBA{
+		lerp(stops[i].R, stops[i+1].R),
+		lerp(stops[i].G, stops[i+1].G),
+		lerp(stops[i].B, stops[i+1].B),
+		lerp(stops[i].A, stops[i+1].A),
+	}
+}
+
+// RenderHeatmap writes a PNG of the heatmap over the map picture. It is scaled the
+// same way as DrawGif so it lines up with the map picture for a map of size rect.
+func RenderHeatmap(w io.Writer, hm *Heatmap, mapPic image.Image, rect image.Rectangle, opts RenderOptions) error {
+	dc := gg.NewContextForRGBA(mapPicture(mapPic, opts))
+	scale := opts.unitScale(mapPic.Bounds(), rect)
+	max := hm.Max()
+	if max > 0 {
+		cell := float64(hm.CellSize) * scale
+		for row := 0; row < hm.Rows; row++ {
+			for col := 0; col < hm.Columns; col++ {
+				v := hm.Values[row*hm.Columns+col]
+				if v <= 0 {
+					continue
+				}
+				// the square root keeps quiet cells visible next to the hottest one
+				dc.SetColor(heatColor(math.Sqrt(v / max)))
+				dc.DrawRectangle(float64(col)*cell, float64(row)*cell, cell, cell)
+				dc.Fill()
+			}
+		}
+	}
+	return png.Encode(w, dc.Image())
+}
@@ -0,0 +1,190 @@
+package tad
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// InspectedPacket is a packet with its place in the game and what it decodes to.
+// Fields describes the decoded struct and is empty for markers without one.
+type InspectedPacket struct {
+	Index        int
+	Milliseconds int
+	PacketRec
+	Message string
+	Fields  string
+	Hex     string
+}
+
+// InspectFilter limits which packets an Inspector steps through. Empty lists match everything.
+type InspectFilter struct {
+	Markers []byte
+	Senders []byte
+}
+
+func (f *InspectFilter) matches(pr PacketRec) bool {
+	return (len(f.Markers) == 0 || containsByte(f.Markers, pr.Data[0])) &&
+		(len(f.Senders) == 0 || containsByte(f.Senders, pr.Sender))
+}
+
+func containsByte(list []byte, b byte) bool {
+	for _, v := range list {
+		if v == b {
+			return true
+		}
+	}
+	return false
+}
+
+// unitSighting is when a unit ID was given to a unit type by a 0x09 packet
+type unitSighting struct {
+	index int
+	netID uint16
+}
+
+// Inspector holds every packet of a game for stepping through them. Pos is the packet
+// being looked at and Filter picks which packets Next and Prev stop at.
+type Inspector struct {
+	Packets   []PacketRec
+	UnitNames map[uint16]string
+	Filter    InspectFilter
+	Pos       int
+	times     []int
+	units     map[uint16][]unitSighting
+}
+
+// NewInspector reads a stream of packets into an Inspector. unitNames maps NetIDs to
+// names and can be nil.
+func NewInspector(stream chan PacketRec, unitNames map[uint16]string) *Inspector {
+	in := &Inspector{
+		UnitNames: unitNames,
+		units:     make(map[uint16][]unitSighting),
+	}
+	var clock, lastMove int
+	for pr := range stream {
+		if pr.Move != lastMove {
+			clock += int(pr.Time)
+			lastMove = pr.Move
+		}
+		if tap, err := loadTAPacket(pr.Data); err == nil {
+			if tmp, ok := tap.(*packet0x09); ok {
+				in.units[tmp.UnitID] = append(in.units[tmp.UnitID], unitSighting{len(in.Packets), tmp.NetID})
+			}
+		}
+		in.Packets = append(in.Packets, pr)
+		in.times = append(in.times, clock)
+	}
+	return in
+}
+
+// Len returns the number of packets
+func (in *Inspector) Len() int {
+	return len(in.Packets)
+}
+
+// unitMem builds the unitmem of printMessage for the packet at index i
+func (in *Inspector) unitMem(i int) map[uint16]uint16 {
+	mem := make(map[uint16]uint16)
+	for unitID := range in.units {
+		if netID, ok := in.Unit(unitID, i); ok {
+			mem[unitID] = netID
+		}
+	}
+	return mem
+}
+
+// Unit returns the NetID a unit ID belonged to at the packet at index i
+func (in *Inspector) Unit(unitID uint16, i int) (netID uint16, ok bool) {
+	for _, s := range in.units[unitID] {
+		if s.index > i {
+			break
+		}
+		netID, ok = s.netID, true
+	}
+	return
+}
+
+// UnitName returns the name of the unit with a unit ID at the packet at index i
+func (in *Inspector) UnitName(unitID uint16, i int) string {
+	netID, ok := in.Unit(unitID, i)
+	if !ok {
+		return ""
+	}
+	if name, ok := in.UnitNames[netID]; ok {
+		return name
+	}
+	return fmt.Sprintf("netid %d", netID)
+}
+
+// At decodes the packet at index i
+func (in *Inspector) At(i int) InspectedPacket {
+	pr := in.Packets[i]
+	ip := InspectedPacket{
+		Index:        i,
+		Milliseconds: in.times[i],
+		PacketRec:    pr,
+		Hex:          hex.Dump(pr.Data),
+	}
+	tap, err := loadTAPacket(pr.Data)
+	if err != nil {
+		ip.Message = fmt.Sprintf("%02x: could not decode: %v", pr.Data[0], err)
+		return ip
+	}
+	ip.Message = tap.printMessage(in.UnitNames, in.unitMem(i))
+	if _, unknown := tap.(*packetDefault); !unknown {
+		ip.Fields = fmt.Sprintf("%+v", tap)
+	}
+	return ip
+}
+
+// Current decodes the packet at Pos
+func (in *Inspector) Current() InspectedPacket {
+	return in.At(in.Pos)
+}
+
+// Next moves Pos forward count packets that match the filter. ok is false when there
+// weren't that many and Pos is left at the last match.
+func (in *Inspector) Next(count int) (ok bool) {
+	return in.step(1, count)
+}
+
+// Prev moves Pos back count packets that match the filter
+func (in *Inspector) Prev(count int) (ok bool) {
+	return in.step(-1, count)
+}
+
+func (in *Inspector) step(dir, count int) bool {
+	for i := in.Pos + dir; i >= 0 && i < len(in.Packets); i += dir {
+		if !in.Filter.matches(in.Packets[i]) {
+			continue
+		}
+		in.Pos = i
+		count--
+		if count <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// seek moves Pos to the first matching packet at or after index i
+func (in *Inspector) seek(i int) bool {
+	for ; i < len(in.Packets); i++ {
+		if in.Filter.matches(in.Packets[i]) {
+			in.Pos = i
+			return true
+		}
+	}
+	return false
+}
+
+// SeekTime moves Pos to the first matching packet at or after ms milliseconds
+func (in *Inspector) SeekTime(ms int) bool {
+	return in.seek(sort.SearchInts(in.times, ms))
+}
+
+// SeekMove moves Pos to the first matching packet of a move or the ones after it
+func (in *Inspector) SeekMove(move int) bool {
+	return in.seek(sort.Search(len(in.Packets), func(i int) bool { return in.Packets[i].Move >= move }))
+}
@@ -0,0 +1,125 @@
+// Package tedtest writes small .ted recordings for tests. The recordings have the
+// sections the parser reads and whatever moves a test gives them.
+package tedtest
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// extra sector types, see the sectorType constants of package tad
+const (
+	CommentsSector = iota + 1
+	LobbyChatSector
+	VersionSector
+	DateSector
+	RecFromSector
+	PlayerAddrSector
+)
+
+// Player is a player of a Demo
+type Player struct {
+	Number byte
+	Name   string
+	Side   byte // 0=arm,1=core,2=watch
+	Color  byte
+	TDPID  int32
+}
+
+// Sector is an extra sector of a Demo. Data is written as is.
+type Sector struct {
+	Type int32
+	Data []byte
+}
+
+// Move is a move of a Demo. Data starts with 0x03 for an uncompressed move and is
+// followed by its packets the way the recorder smartpaks them.
+type Move struct {
+	Time   uint16 // milliseconds since the last move
+	Sender byte
+	Data   []byte
+}
+
+// Demo is a recording to write
+type Demo struct {
+	Map      string
+	MaxUnits uint16
+	Players  []Player
+	Sectors  []Sector
+	Units    []uint32 // IDs of the units in the unit sync data, the CRCs are the IDs
+	Moves    []Move
+}
+
+// Packets makes an uncompressed move of packets
+func Packets(packets ...[]byte) []byte {
+	return append([]byte{0x03}, bytes.Join(packets, nil)...)
+}
+
+// Bytes writes the recording
+func (d *Demo) Bytes() []byte {
+	var out bytes.Buffer
+	section := func(data []byte) {
+		binary.Write(&out, binary.LittleEndian, uint16(len(data)+2))
+		out.Write(data)
+	}
+	var sum bytes.Buffer
+	sum.WriteString("TA Demo\x00")
+	sum.Write([]byte{5, 0, byte(len(d.Players))})
+	binary.Write(&sum, binary.LittleEndian, d.MaxUnits)
+	var mapName [64]byte
+	copy(mapName[:], d.Map)
+	sum.Write(mapName[:])
+	section(sum.Bytes())
+
+	section([]byte{byte(len(d.Sectors))})
+	for _, s := range d.Sectors {
+		data := make([]byte, 4, 4+len(s.Data))
+		binary.LittleEndian.PutUint32(data, uint32(s.Type))
+		section(append(data, s.Data...))
+	}
+	for _, p := range d.Players {
+		var name [64]byte
+		copy(name[:], p.Name)
+		section(append([]byte{p.Color, p.Side, p.Number}, name[:]...))
+	}
+	for _, p := range d.Players {
+		section(append([]byte{p.Number}, statusMsg(p)...))
+	}
+	var units bytes.Buffer
+	for _, id := range d.Units {
+		rec := make([]byte, 14)
+		rec[1] = 0x02
+		binary.LittleEndian.PutUint32(rec[6:], id)
+		binary.LittleEndian.PutUint32(rec[10:], id)
+		units.Write(rec)
+		rec = make([]byte, 14)
+		rec[1] = 0x03
+		binary.LittleEndian.PutUint32(rec[6:], id)
+		units.Write(rec)
+	}
+	section(units.Bytes())
+
+	for _, m := range d.Moves {
+		data := make([]byte, 3, 3+len(m.Data))
+		binary.LittleEndian.PutUint16(data, m.Time)
+		data[2] = m.Sender
+		section(append(data, m.Data...))
+	}
+	return out.Bytes()
+}
+
+// statusMsg makes the encrypted status packet that holds the player's color and TDPID
+func statusMsg(p Player) []byte {
+	plain := make([]byte, 200)
+	plain[0] = 0x03
+	binary.LittleEndian.PutUint32(plain[152:], uint32(p.TDPID))
+	plain[0x9e] = p.Color
+	enc := append([]byte{}, plain...)
+	var check int
+	for i := 3; i < len(enc)-3; i++ {
+		enc[i] = plain[i] ^ byte(i)
+		check += int(enc[i])
+	}
+	binary.LittleEndian.PutUint16(enc[1:], uint16(check))
+	return enc
+}
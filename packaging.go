@@ -4,22 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"image"
-	"image/color"
-	"image/draw"
-	"image/gif"
 	"io"
 	"math"
-	"runtime"
 	"strconv"
+	"strings"
 	"sync"
-	"time"
 
-	"github.com/cosmouser/tnt"
-	"github.com/fogleman/gg"
-	"github.com/google/uuid"
-	log "github.com/sirupsen/logrus"
 	"golang.org/x/text/encoding/charmap"
 )
 
@@ -61,7 +55,10 @@ func Analyze(ctx context.Context, rs io.ReadSeeker) (gp *Game, prs <-chan Packet
 	if err != nil {
 		return
 	}
-	gameOffset := getGameOffset(rs)
+	gameOffset, err := getGameOffset(rs)
+	if err != nil {
+		return
+	}
 	err = getGameLengthAndTTD(rs, gp)
 	if err != nil {
 		return
@@ -74,10 +71,61 @@ func Analyze(ctx context.Context, rs io.ReadSeeker) (gp *Game, prs <-chan Packet
 		err = errors.New("seek to gameoffset failed")
 		return
 	}
-	prs = prGenerator(ctx, rs, gp.TotalMoves, gp.MaxUnits)
+	gp.stream = new(packetStream)
+	prs = prGenerator(ctx, rs, gp.TotalMoves, gp.MaxUnits, gp.stream)
 	return
 }
 
+// Err returns the error that stopped the packets of Analyze before the end of the
+// recording, which is the context's error when it was done first. It is only set once
+// the packet channel is closed.
+func (gp *Game) Err() error {
+	if gp.stream == nil {
+		return nil
+	}
+	return gp.stream.err
+}
+
+// RunWorkers copies every packet of a stream to each worker and waits for all of them.
+// A worker that returns early has the rest of its packets thrown away so the others
+// keep going. The first error of a worker is returned and a worker that panics
+// returns its panic as an error.
+func RunWorkers(prs <-chan PacketRec, workers ...func(stream chan PacketRec) error) error {
+	streams := make([]chan PacketRec, len(workers))
+	errs := make([]error, len(workers))
+	var wg sync.WaitGroup
+	wg.Add(len(workers))
+	for i := range workers {
+		streams[i] = make(chan PacketRec)
+		go func(i int) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					errs[i] = fmt.Errorf("worker %d: %v", i, r)
+				}
+				for range streams[i] {
+				}
+			}()
+			errs[i] = workers[i](streams[i])
+		}(i)
+	}
+	for pr := range prs {
+		for i := range streams {
+			streams[i] <- pr
+		}
+	}
+	for i := range streams {
+		close(streams[i])
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // TeamsWorker consumes packets from a stream and returns the numbers of the
 // players that the recording player has allied
 func TeamsWorker(stream chan PacketRec, gp Game) (allies []int, err error) {
@@ -169,6 +217,10 @@ func ScoreSeriesWorker(stream chan PacketRec, pnameMap map[byte]string) (series
 			litePacket.TotalM = float64(scorePacket.TotalM)
 			litePacket.ExcessE = float64(scorePacket.ExcessE)
 			litePacket.ExcessM = float64(scorePacket.ExcessM)
+			litePacket.StoredM = float64(scorePacket.StoredM)
+			litePacket.StoredE = float64(scorePacket.StoredE)
+			litePacket.StorageM = float64(scorePacket.StorageM)
+			litePacket.StorageE = float64(scorePacket.StorageE)
 			if math.IsNaN(litePacket.Energy) || math.IsInf(litePacket.Energy, 1) {
 				litePacket.Energy = 1
 			}
@@ -232,20 +284,53 @@ func FinalScoresWorker(stream chan PacketRec, pnameMap map[byte]string) (finalSc
 
 // PlayerMessagesWorker consumes packets from a stream and returns a slice of messages from players
 func PlayerMessagesWorker(stream chan PacketRec) (messages []PlayerMessage, err error) {
+	return ChatWorker(stream, Game{}, ChatOptions{})
+}
+
+// ChatWorker consumes packets from a stream and returns a slice of messages from players
+// with their senders named from the game. The options flag chat from watchers and the dead.
+func ChatWorker(stream chan PacketRec, gp Game, opts ChatOptions) (messages []PlayerMessage, err error) {
 	var clock int
 	var lastToken int
+	var isDead [10]bool
+	pnames := GenPnames(gp.Players)
+	textDecoder := charmap.Windows1252.NewDecoder()
 	for pr := range stream {
 		if pr.Move != lastToken {
 			clock += int(pr.Time)
 			lastToken = pr.Move
 		}
-		if pr.Data[0] == 0x05 && pr.Sender != 0 {
+		if pr.Sender < 1 || pr.Sender > 10 {
+			continue
+		}
+		dead, err := isDeathPacket(pr, &gp)
+		if err != nil {
+			return nil, err
+		}
+		if dead {
+			isDead[int(pr.Sender)-1] = true
+		}
+		if pr.Data[0] == 0x05 {
 			tmp := &packet0x05{}
 			if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
 				return nil, err
 			}
 			split := bytes.Split(tmp.Message[:], []byte{0x00})
-			messages = append(messages, PlayerMessage{Message: string(split[0]), Sent: clock})
+			text, err := textDecoder.Bytes(split[0])
+			if err != nil {
+				return nil, err
+			}
+			pm := parsePlayerMessage(string(text))
+			pm.Sent = clock
+			pm.Sender = int(pr.Sender)
+			pm.SenderName = pnames[pr.Sender]
+			if opts.FlagWatchers && int(pr.Sender) <= len(gp.Players) {
+				pm.Watcher = gp.Players[int(pr.Sender)-1].Side == 2
+			}
+			if opts.FlagDead {
+				pm.Dead = isDead[int(pr.Sender)-1]
+			}
+			messages = append(messages, pm)
 		}
 	}
 	return
@@ -258,6 +343,7 @@ func UnitCountWorker(stream chan PacketRec) (uc []map[int]*UnitTypeRecord, err e
 	unitmem := make(map[uint16]*TAUnit)
 	var clock int
 	var lastToken int
+	var lastID int // numbers the units like unitTracker
 	const maxUnits = 1000
 	for pr := range stream {
 		if pr.Move != lastToken {
@@ -269,11 +355,12 @@ func UnitCountWorker(stream chan PacketRec) (uc []map[int]*UnitTypeRecord, err e
 			if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
 				return nil, err
 			}
+			lastID++
 			unitmem[tmp.UnitID] = &TAUnit{
 				Owner:    int(pr.Sender),
 				NetID:    tmp.NetID,
 				Finished: false,
-				ID:       uuid.New().String(),
+				ID:       lastID,
 			}
 			// check to see if its the first unit aka commander
 			if int(tmp.UnitID)%maxUnits == 1 {
@@ -356,23 +443,12 @@ func TimeToDieWorker(stream chan PacketRec, gp Game) (ttd [10]int, err error) {
 			clock += int(pr.Time)
 			lastToken = pr.Move
 		}
-		if pr.Data[0] == 0x0c {
-			tmp := &packet0x0c{}
-			if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
-				return ttd, err
-			}
-			if int(tmp.Destroyed)%gp.MaxUnits == 1 {
-				// pr.Sender - 1 is now dead
-				ttd[int(pr.Sender)-1] = clock
-			}
+		dead, err := isDeathPacket(pr, &gp)
+		if err != nil {
+			return ttd, err
 		}
-		if pr.Data[0] == 0x1b {
-			tdpid := binary.LittleEndian.Uint32(pr.Data[1:5])
-			sv := pr.Data[5]
-			if sv == 6 && int32(tdpid) == gp.Players[int(pr.Sender)-1].TDPID {
-				// pr.Sender -1 is now rejected
-				ttd[int(pr.Sender)-1] = clock
-			}
+		if dead {
+			ttd[int(pr.Sender)-1] = clock
 		}
 	}
 	// add a millisecond for difference
@@ -385,16 +461,84 @@ func TimeToDieWorker(stream chan PacketRec, gp Game) (ttd [10]int, err error) {
 	return
 }
 
+// CommanderDeathsWorker consumes packets from a stream and returns the indexes of the
+// players whose commander was destroyed
+func CommanderDeathsWorker(stream chan PacketRec, gp Game) (deaths []int, err error) {
+	for pr := range stream {
+		dead, err := isCommanderDeath(pr, &gp)
+		if err != nil {
+			return deaths, err
+		}
+		if dead && !containsInt(deaths, int(pr.Sender)-1) {
+			deaths = append(deaths, int(pr.Sender)-1)
+		}
+	}
+	return
+}
+
+// ActionsWorker consumes packets from a stream and counts the actions of each player.
+// Recordings don't hold unit orders so builds started and unit state changes are
+// counted. Camera moves aren't as they are sent while the player only scrolls.
+func ActionsWorker(stream chan PacketRec) (actions [10]int, err error) {
+	for pr := range stream {
+		switch pr.Data[0] {
+		case 0x09, 0x11:
+			if i := int(pr.Sender) - 1; i >= 0 && i < len(actions) {
+				actions[i]++
+			}
+		}
+	}
+	return
+}
+
+// isCommanderDeath reports whether pr says its sender's commander was destroyed
+func isCommanderDeath(pr PacketRec, gp *Game) (bool, error) {
+	if pr.Data[0] != 0x0c {
+		return false, nil
+	}
+	tmp := &packet0x0c{}
+	if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+		return false, err
+	}
+	// pr.Sender - 1 is now dead
+	return gp.MaxUnits > 0 && int(tmp.Destroyed)%gp.MaxUnits == 1, nil
+}
+
+// isDeathPacket reports whether pr says its sender died, either because their
+// commander was destroyed or because they were rejected from the game
+func isDeathPacket(pr PacketRec, gp *Game) (bool, error) {
+	switch pr.Data[0] {
+	case 0x0c:
+		return isCommanderDeath(pr, gp)
+	case 0x1b:
+		if len(pr.Data) < 6 || int(pr.Sender) > len(gp.Players) {
+			return false, nil
+		}
+		tdpid := binary.LittleEndian.Uint32(pr.Data[1:5])
+		sv := pr.Data[5]
+		// pr.Sender -1 is now rejected
+		return sv == 6 && int32(tdpid) == gp.Players[int(pr.Sender)-1].TDPID, nil
+	}
+	return false, nil
+}
+
 // FramesWorker consumes packets from a stream and returns a series of PlaybackFrames for
 // drawing a GIF
 func FramesWorker(stream chan PacketRec, maxUnits int) (frames []PlaybackFrame, err error) {
-	unitmem := make(map[uint16]*TAUnit)
-	addFrame := func(tval int) {
+	return FramesWorkerWithOptions(stream, maxUnits, RenderOptions{})
+}
+
+// FramesWorkerWithOptions consumes packets from a stream and returns a PlaybackFrame for
+// every FrameInterval of the options that is inside their time window
+func FramesWorkerWithOptions(stream chan PacketRec, maxUnits int, opts RenderOptions) (frames []PlaybackFrame, err error) {
+	err = captureFrames(stream, maxUnits, opts, func(tval int, units map[uint16]*TAUnit, effects []Effect, cameras []CameraPosition) {
 		newFrame := PlaybackFrame{}
 		newFrame.Time = tval
+		newFrame.Effects = effects
+		newFrame.Cameras = cameras
 		newFrame.Number = len(frames)
 		newFrame.Units = make(map[uint16]*TAUnit)
-		for k, v := range unitmem {
+		for k, v := range units {
 			newFrame.Units[k] = new(TAUnit)
 			newFrame.Units[k].Owner = v.Owner
 			newFrame.Units[k].NetID = v.NetID
@@ -407,269 +551,288 @@ func FramesWorker(stream chan PacketRec, maxUnits int) (frames []PlaybackFrame,
 			newFrame.Units[k].Class = v.Class
 		}
 		frames = append(frames, newFrame)
+	})
+	if err != nil {
+		return nil, err
 	}
-	var clock, lastTime int
-	var lastMove int
-	var unitSpaces [10]uint16
+	return
+}
+
+// captureFrames follows the units in a stream and calls addFrame every FrameInterval
+// of the options that is inside their time window. The units belong to the tracker and
+// change after addFrame returns while the effects and cameras are copies.
+func captureFrames(stream chan PacketRec, maxUnits int, opts RenderOptions, addFrame func(tval int, units map[uint16]*TAUnit, effects []Effect, cameras []CameraPosition)) error {
+	interval := opts.FrameInterval
+	if interval <= 0 {
+		interval = defaultFrameInterval
+	}
+	c := newCapture(maxUnits, opts)
+	var lastTime int
 	for pr := range stream {
-		if pr.Move != lastMove {
-			clock += int(pr.Time)
-			lastMove = pr.Move
+		if err := c.update(pr); err != nil {
+			return err
 		}
-		if pr.Data[0] == 0x09 {
-			tmp := &packet0x09{}
-			if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
-				return nil, err
-			}
-			unitmem[tmp.UnitID] = &TAUnit{
-				Owner:    int(pr.Sender),
-				NetID:    tmp.NetID,
-				Finished: false,
-				Pos: point{
-					X:    int(tmp.XPos),
-					Y:    int(tmp.YPos),
-					ID:   uuid.New().String(),
-					Time: clock,
-				},
-				ID: uuid.New().String(),
-			}
-			// check to see if its the first unit aka commander
-			if int(tmp.UnitID)%maxUnits == 1 {
-				unitmem[tmp.UnitID].Finished = true
-				unitmem[tmp.UnitID].Class = commanderClass
-				unitSpaces[int(pr.Sender)-1] = tmp.UnitID
+		if curTime := c.clock / interval; curTime > lastTime {
+			lastTime = curTime
+			// effects that have faded are dropped even when the frame is skipped
+			c.effects = opts.recentEffects(c.effects, c.clock)
+			if opts.inWindow(c.clock) {
+				addFrame(c.clock, c.ut.units, append([]Effect(nil), c.effects...), latestCameras(c.cameras))
 			}
 		}
-		if pr.Data[0] == 0x12 {
-			tmp := &packet0x12{}
-			if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
-				return nil, err
-			}
-			if tau, ok := unitmem[tmp.BuiltID]; ok && tau != nil {
-				unitmem[tmp.BuiltID].Finished = true
-			}
-			if tau, ok := unitmem[tmp.BuiltByID]; ok && tau != nil {
-				if tau.Class == factoryClass {
-					if tau2, ok := unitmem[tmp.BuiltID]; ok && tau2 != nil {
-						unitmem[tmp.BuiltID].Class = mobileClass
-					}
-				}
-			}
+	}
+	return nil
+}
 
+// capture follows the units, effects and cameras of a stream for captureFrames and
+// SnapshotWorker
+type capture struct {
+	opts     RenderOptions
+	ut       *unitTracker
+	effects  []Effect
+	cameras  map[int]CameraPosition
+	clock    int
+	lastMove int
+}
+
+func newCapture(maxUnits int, opts RenderOptions) *capture {
+	return &capture{
+		opts:    opts,
+		ut:      newUnitTracker(maxUnits),
+		cameras: make(map[int]CameraPosition),
+	}
+}
+
+// clockAt gives the game time of a packet before it is applied
+func (c *capture) clockAt(pr PacketRec) int {
+	if pr.Move != c.lastMove {
+		return c.clock + int(pr.Time)
+	}
+	return c.clock
+}
+
+// update applies a packet
+func (c *capture) update(pr PacketRec) error {
+	c.clock, c.lastMove = c.clockAt(pr), pr.Move
+	if !c.opts.HideEffects {
+		ef, ok, err := effectFromPacket(pr, c.ut.units, c.clock)
+		if err != nil {
+			return err
 		}
-		if pr.Data[0] == 0x11 {
-			tmp := &packet0x11{}
-			if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
-				return nil, err
-			}
-			// 9 == factory is building
-			if tmp.State == 9 {
-				if tau, ok := unitmem[tmp.UnitID]; ok && tau != nil && tau.Class == buildingClass {
-					tau.Class = factoryClass
-				}
-			}
-			if tmp.State == 2 {
-				if tau, ok := unitmem[tmp.UnitID]; ok && tau != nil && tau.Class == mobileClass {
-					tau.Class = airClass
-				}
-			}
+		if ok {
+			c.effects = append(c.effects, ef)
 		}
-		if pr.Data[0] == 0x0c {
-			tmp := &packet0x0c{}
-			if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
-				return nil, err
-			}
-			if tau, ok := unitmem[tmp.Destroyed]; ok || tau != nil {
-				delete(unitmem, tmp.Destroyed)
-			}
+	}
+	cp, ok, err := cameraFromPacket(pr, c.clock)
+	if err != nil {
+		return err
+	}
+	if ok {
+		c.cameras[cp.Owner] = cp
+	}
+	return c.ut.update(pr, c.clock)
+}
+
+// DumpWorker consumes packets from a stream and writes a line for each one like
+// playbackMsg with the game time and move number in front. Packets that can't be
+// decoded are written in hex like the Inspector does. unitNames maps NetIDs to names
+// and can be nil.
+func DumpWorker(stream chan PacketRec, w io.Writer, unitNames map[uint16]string) error {
+	unitmem := make(map[uint16]uint16)
+	var clock, lastMove int
+	for pr := range stream {
+		if pr.Move != lastMove {
+			clock += int(pr.Time)
+			lastMove = pr.Move
 		}
-		if pr.Data[0] == 0x0d {
-			tmp := &packet0x0d{}
-			if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
-				return nil, err
-			}
-			if tau, ok := unitmem[tmp.ShooterID]; ok && tau != nil {
-				tau.Pos.X = int(tmp.OriginX)
-				tau.Pos.Y = int(tmp.OriginY)
-				tau.Pos.Time = clock
-				tau.Pos.ID = uuid.New().String()
-			}
-			if tau, ok := unitmem[tmp.ShotID]; ok && tau != nil && tau.Class != buildingClass {
-				tau.Pos.X = int(tmp.DestX)
-				tau.Pos.Y = int(tmp.DestY)
-				tau.Pos.Time = clock
-				tau.Pos.ID = uuid.New().String()
-			}
-		}
-		if pr.Data[0] == 0x2c && len(pr.Data) >= 0x1a {
-			// if 0x9: - 0xc00 isn't the unitid's netid, ignore
-			x2cUnitID := binary.LittleEndian.Uint16(pr.Data[0x7:])
-			x2cNetID := binary.LittleEndian.Uint16(pr.Data[0x9:])
-			x2cXPos := binary.LittleEndian.Uint16(pr.Data[0xb:])
-			x2cYPos := binary.LittleEndian.Uint16(pr.Data[0xd:])
-			x2cUnitID += unitSpaces[int(pr.Sender)-1]
-			if tau, ok := unitmem[x2cUnitID]; ok && tau != nil {
-				if x2cNetID-0xc00 == tau.NetID {
-					tau.Pos.X = int(x2cXPos) * 16
-					tau.Pos.Y = int(x2cYPos) * 16
-					tau.Pos.Time = clock
-					tau.Pos.ID = uuid.New().String()
-				}
-			}
+		var msg string
+		tap, err := loadTAPacket(pr.Data)
+		if err != nil {
+			msg = fmt.Sprintf("%02x: could not decode: %v\n%v", pr.Data[0], err, strings.TrimSuffix(hex.Dump(pr.Data), "\n"))
+			tap = nil
+		} else {
+			msg = tap.printMessage(unitNames, unitmem)
+		}
+		_, err = fmt.Fprintf(w, "%02d:%02d move %d: player %d sent %v\n",
+			clock/60000,
+			clock/1000%60,
+			pr.Move,
+			pr.Sender,
+			msg)
+		if err != nil {
+			return err
 		}
-		if curTime := clock / 10000; curTime > lastTime {
-			addFrame(clock)
-			lastTime = curTime
+		if tmp, ok := tap.(*packet0x09); ok {
+			unitmem[tmp.UnitID] = tmp.NetID
 		}
 	}
-	return
+	return nil
 }
 
-// DrawGif writes a gif of the frames and takes the max dimension of the output picture to
-// scale the points to the image from their original coordinates.
-func (gp *Game) DrawGif(w io.Writer, frames []PlaybackFrame, mapPic image.Rectangle, rect image.Rectangle) error {
-	outGif := gif.GIF{
-		Disposal: make([]byte, len(frames)),
-		Image:    make([]*image.Paletted, len(frames)),
-		Delay:    make([]int, len(frames)),
-	}
-	for i := range outGif.Disposal {
-		outGif.Disposal[i] = gif.DisposalPrevious
-	}
-	for i := range outGif.Delay {
-		outGif.Delay[i] = 10
-	}
-	maxDim := math.Max(float64(mapPic.Size().X), float64(mapPic.Size().Y))
-	var scale float64
-	if rect.Size().X > rect.Size().Y {
-		scale = maxDim / float64(rect.Size().X)
-	} else {
-		scale = maxDim / float64(rect.Size().Y)
-	}
-	outMaxDimX := mapPic.Size().X
-	outMaxDimY := mapPic.Size().Y
-	ts1 := time.Now()
-	gifPalette := tnt.TAPalette
-	gifPalette[0] = image.Transparent
-	playerColors := []color.RGBA{
-		tnt.TAPalette[252].(color.RGBA),
-		tnt.TAPalette[249].(color.RGBA),
-		tnt.TAPalette[17].(color.RGBA),
-		tnt.TAPalette[250].(color.RGBA),
-		tnt.TAPalette[36].(color.RGBA),
-		tnt.TAPalette[218].(color.RGBA),
-		tnt.TAPalette[208].(color.RGBA),
-		tnt.TAPalette[93].(color.RGBA),
-		tnt.TAPalette[100].(color.RGBA),
-		tnt.TAPalette[210].(color.RGBA),
-	}
-	frameGen := func() <-chan PlaybackFrame {
-		frameStream := make(chan PlaybackFrame)
-		go func() {
-			defer close(frameStream)
-			for i := range frames {
-				frameStream <- frames[i]
-			}
-		}()
-		return frameStream
-	}
-	done := make(chan interface{})
-	frameStream := frameGen()
-
-	frameDrawer := func(done <-chan interface{}, frameStream <-chan PlaybackFrame) <-chan numberedFrame {
-		palettedStream := make(chan numberedFrame)
-		go func() {
-			defer close(palettedStream)
-			for {
-				select {
-				case <-done:
-					return
-				case incomingFrame := <-frameStream:
-					dc := gg.NewContext(outMaxDimX, outMaxDimY)
-					for _, tau := range incomingFrame.Units {
-						drawUnit(dc, tau, scale, playerColors)
-					}
-					imgItem := dc.Image()
-					palettedImage := image.NewPaletted(imgItem.Bounds(), gifPalette)
-					draw.Draw(palettedImage, palettedImage.Rect, imgItem, imgItem.Bounds().Min, draw.Over)
-					palettedStream <- numberedFrame{
-						Number:   incomingFrame.Number,
-						Paletted: palettedImage,
-					}
+// unitTracker follows the owner, class and last known position of every unit
+// from the packets that FramesWorker uses
+type unitTracker struct {
+	maxUnits   int
+	units      map[uint16]*TAUnit
+	unitSpaces [10]uint16
+	lastID     int // ID of the latest unit
+}
+
+func newUnitTracker(maxUnits int) *unitTracker {
+	return &unitTracker{
+		maxUnits: maxUnits,
+		units:    make(map[uint16]*TAUnit),
+	}
+}
+
+// update applies a packet to the tracked units at clock milliseconds
+func (ut *unitTracker) update(pr PacketRec, clock int) error {
+	if pr.Data[0] == 0x09 {
+		tmp := &packet0x09{}
+		if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+			return err
+		}
+		ut.lastID++
+		ut.units[tmp.UnitID] = &TAUnit{
+			Owner:    int(pr.Sender),
+			NetID:    tmp.NetID,
+			Finished: false,
+			Pos: point{
+				X:    int(tmp.XPos),
+				Y:    int(tmp.YPos),
+				ID:   1,
+				Time: clock,
+			},
+			ID: ut.lastID,
+		}
+		// check to see if its the first unit aka commander
+		if ut.maxUnits > 0 && int(tmp.UnitID)%ut.maxUnits == 1 {
+			ut.units[tmp.UnitID].Finished = true
+			ut.units[tmp.UnitID].Class = commanderClass
+			ut.unitSpaces[int(pr.Sender)-1] = tmp.UnitID
+		}
+	}
+	if pr.Data[0] == 0x12 {
+		tmp := &packet0x12{}
+		if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+			return err
+		}
+		if tau, ok := ut.units[tmp.BuiltID]; ok && tau != nil {
+			ut.units[tmp.BuiltID].Finished = true
+		}
+		if tau, ok := ut.units[tmp.BuiltByID]; ok && tau != nil {
+			if tau.Class == factoryClass {
+				if tau2, ok := ut.units[tmp.BuiltID]; ok && tau2 != nil {
+					ut.units[tmp.BuiltID].Class = mobileClass
 				}
 			}
-		}()
-		return palettedStream
+		}
+
 	}
-	fanIn := func(done <-chan interface{}, channels ...<-chan numberedFrame) <-chan numberedFrame {
-		var wg sync.WaitGroup
-		multiplexedStream := make(chan numberedFrame)
-		multiplex := func(c <-chan numberedFrame) {
-			defer wg.Done()
-			for i := range c {
-				select {
-				case <-done:
-					return
-				case multiplexedStream <- i:
-				}
+	if pr.Data[0] == 0x11 {
+		tmp := &packet0x11{}
+		if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+			return err
+		}
+		// 9 == factory is building
+		if tmp.State == 9 {
+			if tau, ok := ut.units[tmp.UnitID]; ok && tau != nil && tau.Class == buildingClass {
+				tau.Class = factoryClass
 			}
 		}
-		wg.Add(len(channels))
-		for _, c := range channels {
-			go multiplex(c)
-		}
-		go func() {
-			wg.Wait()
-			close(multiplexedStream)
-		}()
-		return multiplexedStream
-	}
-	numDrawers := runtime.NumCPU()
-	drawers := make([]<-chan numberedFrame, numDrawers)
-	for i := 0; i < numDrawers; i++ {
-		drawers[i] = frameDrawer(done, frameStream)
-	}
-	frameStatus := make([]bool, len(frames))
-	multiplexedStream := fanIn(done, drawers...)
-	for f := range multiplexedStream {
-		outGif.Image[f.Number] = f.Paletted
-		frameStatus[f.Number] = true
-		finished := false
-		for _, v := range frameStatus {
-			if v {
-				finished = true
-			} else {
-				finished = false
-				break
+		if tmp.State == 2 {
+			if tau, ok := ut.units[tmp.UnitID]; ok && tau != nil && tau.Class == mobileClass {
+				tau.Class = airClass
 			}
 		}
-		if finished {
-			break
+	}
+	if pr.Data[0] == 0x0c {
+		tmp := &packet0x0c{}
+		if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+			return err
+		}
+		if tau, ok := ut.units[tmp.Destroyed]; ok || tau != nil {
+			delete(ut.units, tmp.Destroyed)
 		}
 	}
+	if pr.Data[0] == 0x0d {
+		tmp := &packet0x0d{}
+		if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
+			return err
+		}
+		if tau, ok := ut.units[tmp.ShooterID]; ok && tau != nil {
+			tau.Pos.X = int(tmp.OriginX)
+			tau.Pos.Y = int(tmp.OriginY)
+			tau.Pos.Time = clock
+			tau.Pos.ID++
+		}
+		if tau, ok := ut.units[tmp.ShotID]; ok && tau != nil && tau.Class != buildingClass {
+			tau.Pos.X = int(tmp.DestX)
+			tau.Pos.Y = int(tmp.DestY)
+			tau.Pos.Time = clock
+			tau.Pos.ID++
+		}
+	}
+	if pr.Data[0] == 0x2c && len(pr.Data) >= 0x1a {
+		// if 0x9: - 0xc00 isn't the unitid's netid, ignore
+		x2cUnitID := binary.LittleEndian.Uint16(pr.Data[0x7:])
+		x2cNetID := binary.LittleEndian.Uint16(pr.Data[0x9:])
+		x2cXPos := binary.LittleEndian.Uint16(pr.Data[0xb:])
+		x2cYPos := binary.LittleEndian.Uint16(pr.Data[0xd:])
+		x2cUnitID += ut.unitSpaces[int(pr.Sender)-1]
+		if tau, ok := ut.units[x2cUnitID]; ok && tau != nil {
+			if x2cNetID-0xc00 == tau.NetID {
+				tau.Pos.X = int(x2cXPos) * 16
+				tau.Pos.Y = int(x2cYPos) * 16
+				tau.Pos.Time = clock
+				tau.Pos.ID++
+			}
+		}
+	}
+	return nil
+}
 
-	log.Printf("drawing %d frames took %v at %f fps", len(frames), time.Since(ts1), float64(len(frames))/time.Since(ts1).Seconds())
-	gif.EncodeAll(w, &outGif)
-	log.WithFields(log.Fields{
-		"numFrames": len(frames),
-	}).Info()
+// DrawGif writes a gif of the frames and takes the max dimension of the output picture to
+// scale the points to the image from their original coordinates. The units are drawn over
+// mapPic unless it is only an image.Rectangle.
+func (gp *Game) DrawGif(w io.Writer, frames []PlaybackFrame, mapPic image.Image, rect image.Rectangle) error {
+	return DrawGifWithOptions(w, frames, mapPic, rect, RenderOptions{})
+}
 
-	return nil
+// DrawGifWithOptions draws frames straight from FramesWorker with the player colors of
+// the game. The options' ColorMap is set from MakeColorMap when it is nil.
+func (gp *Game) DrawGifWithOptions(w io.Writer, frames []PlaybackFrame, mapPic image.Image, rect image.Rectangle, opts RenderOptions) error {
+	if opts.ColorMap == nil {
+		opts.ColorMap = gp.MakeColorMap()
+	}
+	return DrawGifWithOptions(w, frames, mapPic, rect, opts)
 }
 
 // SmoothUnitMovement uses a colorMap to sync colors and adjust unit positions
 func SmoothUnitMovement(frames []PlaybackFrame, colorMap map[int]int) {
-	nullPoint := point{
-		X:    0,
-		Y:    0,
-		ID:   uuid.New().String(),
-		Time: 0,
+	for i := range frames {
+		for _, tau := range frames[i].Units {
+			tau.Owner = colorMap[tau.Owner]
+		}
+		for j := range frames[i].Effects {
+			frames[i].Effects[j].Owner = colorMap[frames[i].Effects[j].Owner]
+		}
+		for j := range frames[i].Cameras {
+			frames[i].Cameras[j].Owner = colorMap[frames[i].Cameras[j].Owner]
+		}
 	}
+	InterpolateUnitMovement(frames)
+}
+
+// InterpolateUnitMovement adjusts unit positions between their known locations
+// without changing their owners
+func InterpolateUnitMovement(frames []PlaybackFrame) {
+	// marks units without a later position so they aren't looked at again
+	nullPoint := point{ID: -1}
 	for i := range frames {
 		for tauID, tau := range frames[i].Units {
-			tau.Owner = colorMap[tau.Owner]
 			toChange := []int{}
-			if tau.NextPos.ID == "" {
+			if tau.NextPos.ID == 0 {
 				nextFrame := 0
 				for f := i; f < len(frames); f++ {
 					if unit, ok := frames[f].Units[tauID]; !ok || unit.ID != tau.ID {
@@ -711,6 +874,7 @@ func UnitDataSeriesWorker(stream <-chan PacketRec) (out map[int][]UDSRecord, err
 		lastSPLite  int
 		clock       int
 		lastToken   int
+		lastID      int // numbers the units like unitTracker
 	)
 	for pr := range stream {
 		if pr.Move != lastToken {
@@ -736,6 +900,10 @@ func UnitDataSeriesWorker(stream <-chan PacketRec) (out map[int][]UDSRecord, err
 			litePacket.TotalM = float64(scorePacket.TotalM)
 			litePacket.ExcessE = float64(scorePacket.ExcessE)
 			litePacket.ExcessM = float64(scorePacket.ExcessM)
+			litePacket.StoredM = float64(scorePacket.StoredM)
+			litePacket.StoredE = float64(scorePacket.StoredE)
+			litePacket.StorageM = float64(scorePacket.StorageM)
+			litePacket.StorageE = float64(scorePacket.StorageE)
 			if math.IsNaN(litePacket.Energy) || math.IsInf(litePacket.Energy, 1) {
 				litePacket.Energy = 1
 			}
@@ -754,11 +922,12 @@ func UnitDataSeriesWorker(stream <-chan PacketRec) (out map[int][]UDSRecord, err
 			if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
 				return nil, err
 			}
+			lastID++
 			unitmem[tmp.UnitID] = &TAUnit{
 				Owner:    int(pr.Sender),
 				NetID:    tmp.NetID,
 				Finished: false,
-				ID:       uuid.New().String(),
+				ID:       lastID,
 			}
 		}
 		if pr.Data[0] == 0x0c {
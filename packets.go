@@ -226,6 +226,20 @@ type packet0x0d struct {
 	Unknown7  byte
 }
 
+func (p *packet0x0d) printMessage(unitNames map[uint16]string, unitMem map[uint16]uint16) string {
+	return fmt.Sprintf("%02x: %v (%04x) fired from (%d, %d) at (%d, %d)",
+		p.Marker,
+		unitNames[unitMem[p.ShooterID]],
+		p.ShooterID,
+		p.OriginX,
+		p.OriginY,
+		p.DestX,
+		p.DestY)
+}
+func (p *packet0x0d) GetMarker() byte {
+	return p.Marker
+}
+
 // Unit state change
 type packet0x11 struct {
 	Marker byte
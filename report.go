@@ -0,0 +1,335 @@
+package tad
+
+import (
+	"context"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ReportSchemaVersion is the version of the GameReport JSON. It goes up when a field is
+// removed or changes meaning. Adding fields doesn't change it as long as they are
+// omitempty, which keeps them optional in the schema so older reports still validate.
+const ReportSchemaVersion = 1
+
+// GameReport gathers the analysis of a game into one document with a stable JSON shape.
+// Its schema is in gamereport.schema.json. Times are in milliseconds of game time.
+type GameReport struct {
+	SchemaVersion int            `json:"schemaVersion"`
+	Map           string         `json:"map"`
+	Recorder      string         `json:"recorder"`
+	Recorded      string         `json:"recorded,omitempty"` // RFC 3339
+	RecordedFrom  string         `json:"recordedFrom,omitempty"`
+	Comments      string         `json:"comments,omitempty"`
+	Milliseconds  int            `json:"milliseconds"`
+	Moves         int            `json:"moves"`
+	MaxUnits      int            `json:"maxUnits"`
+	Unitsum       string         `json:"unitsum"`
+	Fingerprint   string         `json:"fingerprint"`
+	Key           string         `json:"key,omitempty"` // see Game.GetGameKey
+	Players       []PlayerReport `json:"players"`
+	LobbyChat     []LobbyReport  `json:"lobbyChat"`
+	Chat          []ChatReport   `json:"chat"`
+}
+
+// PlayerReport is a player of a GameReport
+type PlayerReport struct {
+	Number      int           `json:"number"`
+	Name        string        `json:"name"`
+	Side        string        `json:"side"` // arm, core or watcher
+	Color       int           `json:"color"`
+	Status      string        `json:"status,omitempty"`
+	IP          string        `json:"ip,omitempty"`
+	Cheats      bool          `json:"cheats"`
+	TDPID       int32         `json:"tdpid,omitempty"`
+	Allied      bool          `json:"allied"` // allied with the player who recorded the game
+	TimeToDie   int           `json:"timeToDie,omitempty"`
+	Died        bool          `json:"died,omitempty"` // their commander was destroyed
+	Actions     int           `json:"actions,omitempty"`
+	FoulPlay    bool          `json:"foulPlay"`
+	FinalScore  *FinalScore   `json:"finalScore,omitempty"`
+	ScoreSeries []ScoreSample `json:"scoreSeries"`
+	Units       []UnitReport  `json:"units"`
+}
+
+// ScoreSample is an SPLite with JSON names
+type ScoreSample struct {
+	Milliseconds  int     `json:"milliseconds"`
+	Kills         int     `json:"kills"`
+	Losses        int     `json:"losses"`
+	Metal         float64 `json:"metal"`  // per second
+	Energy        float64 `json:"energy"` // per second
+	TotalMetal    float64 `json:"totalMetal"`
+	TotalEnergy   float64 `json:"totalEnergy"`
+	ExcessMetal   float64 `json:"excessMetal"`
+	ExcessEnergy  float64 `json:"excessEnergy"`
+	StoredMetal   float64 `json:"storedMetal"`
+	StoredEnergy  float64 `json:"storedEnergy"`
+	MetalStorage  float64 `json:"metalStorage"`
+	EnergyStorage float64 `json:"energyStorage"`
+}
+
+// UnitReport is a UnitTypeRecord of one unit type built by a player
+type UnitReport struct {
+	NetID          int         `json:"netId"`
+	Name           string      `json:"name,omitempty"`
+	Produced       int         `json:"produced"`
+	FirstProduced  int         `json:"firstProduced"`
+	DamageDealt    int         `json:"damageDealt"`
+	DamageReceived int         `json:"damageReceived"`
+	Kills          []UnitCount `json:"kills"`
+	Deaths         []UnitCount `json:"deaths"`
+}
+
+// UnitCount is how many units of a type were killed by or killed a UnitReport's type
+type UnitCount struct {
+	NetID int    `json:"netId"`
+	Name  string `json:"name,omitempty"`
+	Count int    `json:"count"`
+}
+
+// LobbyReport is a LobbyMessage of a GameReport
+type LobbyReport struct {
+	Kind    string `json:"kind"`
+	Speaker string `json:"speaker,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	Text    string `json:"text"`
+}
+
+// ChatReport is a PlayerMessage of a GameReport
+type ChatReport struct {
+	Milliseconds int    `json:"milliseconds"`
+	Sender       int    `json:"sender"`
+	SenderName   string `json:"senderName"`
+	Scope        string `json:"scope"` // all, allies or private
+	Recipient    string `json:"recipient,omitempty"`
+	Text         string `json:"text"`
+	Watcher      bool   `json:"watcher"`
+	Dead         bool   `json:"dead"`
+}
+
+// ReportParts are the worker results a GameReport is made from. Parts that are left
+// out leave their fields empty.
+type ReportParts struct {
+	FinalScores []FinalScore
+	FoulPlay    []int
+	ScoreSeries map[string][]SPLite
+	UnitCounts  []map[int]*UnitTypeRecord
+	Chat        []PlayerMessage
+	Teams       []int
+	TimeToDie   [10]int
+	Deaths      []int
+	Actions     [10]int
+	UnitNames   map[uint16]string
+}
+
+// AnalyzeReport analyzes a demo with every worker a GameReport needs. unitNames can be nil.
+func AnalyzeReport(ctx context.Context, rs io.ReadSeeker, unitNames map[uint16]string) (*GameReport, error) {
+	gp, prs, err := Analyze(ctx, rs)
+	if err != nil {
+		return nil, err
+	}
+	return GameReportWorker(prs, gp, unitNames)
+}
+
+// GameReportWorker runs every worker a GameReport needs over the packets of gp. It fails
+// when the packets stopped early, see Game.Err.
+func GameReportWorker(prs <-chan PacketRec, gp *Game, unitNames map[uint16]string) (*GameReport, error) {
+	parts := ReportParts{UnitNames: unitNames}
+	pnames := GenPnames(gp.Players)
+	err := RunWorkers(prs,
+		func(stream chan PacketRec) (err error) {
+			parts.FinalScores, parts.FoulPlay, err = FinalScoresWorker(stream, pnames)
+			return
+		},
+		func(stream chan PacketRec) (err error) {
+			parts.ScoreSeries, err = ScoreSeriesWorker(stream, pnames)
+			return
+		},
+		func(stream chan PacketRec) (err error) {
+			parts.UnitCounts, err = UnitCountWorker(stream)
+			return
+		},
+		func(stream chan PacketRec) (err error) {
+			parts.Chat, err = ChatWorker(stream, *gp, ChatOptions{FlagWatchers: true, FlagDead: true})
+			return
+		},
+		func(stream chan PacketRec) (err error) {
+			parts.Teams, err = TeamsWorker(stream, *gp)
+			return
+		},
+		func(stream chan PacketRec) (err error) {
+			parts.TimeToDie, err = TimeToDieWorker(stream, *gp)
+			return
+		},
+		func(stream chan PacketRec) (err error) {
+			parts.Deaths, err = CommanderDeathsWorker(stream, *gp)
+			return
+		},
+		func(stream chan PacketRec) (err error) {
+			parts.Actions, err = ActionsWorker(stream)
+			return
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err = gp.Err(); err != nil {
+		return nil, err
+	}
+	return gp.NewGameReport(parts), nil
+}
+
+// NewGameReport puts the game and the results of its workers together
+func (gp *Game) NewGameReport(parts ReportParts) *GameReport {
+	report := &GameReport{
+		SchemaVersion: ReportSchemaVersion,
+		Map:           gp.MapName,
+		Recorder:      gp.Version,
+		RecordedFrom:  gp.RecFrom,
+		Comments:      gp.Comments,
+		Milliseconds:  gp.Milliseconds,
+		Moves:         gp.TotalMoves,
+		MaxUnits:      gp.MaxUnits,
+		Unitsum:       gp.Unitsum,
+		Fingerprint:   gp.GetFingerprint(),
+		Key:           gp.GetGameKey(),
+		Players:       make([]PlayerReport, 0, len(gp.Players)),
+		LobbyChat:     make([]LobbyReport, 0, len(gp.LobbyMessages)),
+		Chat:          make([]ChatReport, 0, len(parts.Chat)),
+	}
+	if !gp.Recorded.IsZero() {
+		report.Recorded = gp.Recorded.Format(time.RFC3339)
+	}
+	for _, p := range gp.Players {
+		report.Players = append(report.Players, playerReport(p, parts))
+	}
+	for _, m := range gp.LobbyMessages {
+		report.LobbyChat = append(report.LobbyChat, LobbyReport{
+			Kind:    m.Kind.String(),
+			Speaker: m.Speaker,
+			Subject: m.Subject,
+			Text:    m.Text,
+		})
+	}
+	for _, m := range parts.Chat {
+		report.Chat = append(report.Chat, ChatReport{
+			Milliseconds: m.Sent,
+			Sender:       m.Sender,
+			SenderName:   m.SenderName,
+			Scope:        m.Scope.String(),
+			Recipient:    m.Recipient,
+			Text:         m.Text,
+			Watcher:      m.Watcher,
+			Dead:         m.Dead,
+		})
+	}
+	return report
+}
+
+func playerReport(p DemoPlayer, parts ReportParts) PlayerReport {
+	i := int(p.Number) - 1
+	pr := PlayerReport{
+		Number:      int(p.Number),
+		Name:        p.Name,
+		Side:        sideName(p.Side),
+		Color:       int(p.Color),
+		Status:      p.Status,
+		IP:          p.IP,
+		Cheats:      p.Cheats,
+		TDPID:       p.TDPID,
+		Allied:      containsInt(parts.Teams, i),
+		Died:        containsInt(parts.Deaths, i),
+		FoulPlay:    containsInt(parts.FoulPlay, i),
+		ScoreSeries: make([]ScoreSample, 0, len(parts.ScoreSeries[p.Name])),
+		Units:       make([]UnitReport, 0),
+	}
+	if i >= 0 && i < len(parts.TimeToDie) {
+		pr.TimeToDie = parts.TimeToDie[i]
+		pr.Actions = parts.Actions[i]
+	}
+	for j := range parts.FinalScores {
+		if parts.FinalScores[j].Player == p.Name {
+			fs := parts.FinalScores[j]
+			pr.FinalScore = &fs
+		}
+	}
+	for _, s := range parts.ScoreSeries[p.Name] {
+		pr.ScoreSeries = append(pr.ScoreSeries, ScoreSample{
+			Milliseconds:  s.Milliseconds,
+			Kills:         s.Kills,
+			Losses:        s.Losses,
+			Metal:         finite(s.Metal),
+			Energy:        finite(s.Energy),
+			TotalMetal:    finite(s.TotalM),
+			TotalEnergy:   finite(s.TotalE),
+			ExcessMetal:   finite(s.ExcessM),
+			ExcessEnergy:  finite(s.ExcessE),
+			StoredMetal:   finite(s.StoredM),
+			StoredEnergy:  finite(s.StoredE),
+			MetalStorage:  finite(s.StorageM),
+			EnergyStorage: finite(s.StorageE),
+		})
+	}
+	if i >= 0 && i < len(parts.UnitCounts) {
+		for netID, utr := range parts.UnitCounts[i] {
+			pr.Units = append(pr.Units, UnitReport{
+				NetID:          netID,
+				Name:           parts.UnitNames[uint16(netID)],
+				Produced:       utr.Produced,
+				FirstProduced:  utr.FirstProduced,
+				DamageDealt:    utr.DamageDealt,
+				DamageReceived: utr.DamageReceived,
+				Kills:          unitCounts(utr.Kills, parts.UnitNames),
+				Deaths:         unitCounts(utr.Deaths, parts.UnitNames),
+			})
+		}
+		sort.Slice(pr.Units, func(a, b int) bool { return pr.Units[a].NetID < pr.Units[b].NetID })
+	}
+	return pr
+}
+
+// unitCounts turns the Kills or Deaths of a UnitTypeRecord into a list sorted by NetID
+func unitCounts(counts map[string]int, unitNames map[uint16]string) []UnitCount {
+	out := make([]UnitCount, 0, len(counts))
+	for key, count := range counts {
+		netID, err := strconv.Atoi(key)
+		if err != nil {
+			continue
+		}
+		out = append(out, UnitCount{NetID: netID, Name: unitNames[uint16(netID)], Count: count})
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].NetID < out[b].NetID })
+	return out
+}
+
+func sideName(side byte) string {
+	switch side {
+	case 0:
+		return "arm"
+	case 1:
+		return "core"
+	case 2:
+		return "watcher"
+	}
+	return strconv.Itoa(int(side))
+}
+
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// finite replaces the NaN and infinite rates of empty score intervals, which JSON can't hold
+func finite(f float64) float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0
+	}
+	return f
+}
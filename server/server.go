@@ -0,0 +1,334 @@
+// Package server serves replay analysis over HTTP. It takes .ted uploads and answers
+// with a JSON report of the game, GIF animations and PNG score charts.
+//
+// Every endpoint takes a POST with the recording either as the raw body or as the
+// "demo" part of a multipart form:
+//
+//	POST /analyze                      tad.GameReport of the game as JSON
+//	POST /render/gif?mapsize=WxH       GIF animation, the "map" form part is drawn under the units
+//	POST /render/chart?metric=kills    PNG chart of a score metric like metal-income or kills
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/png" // map pictures are usually png
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cosmouser/tad"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaults for Config
+const (
+	DefaultMaxUpload = 16 << 20
+	DefaultTimeout   = 120 * time.Second
+)
+
+// Config limits the work done for each request. Zero values use the defaults.
+type Config struct {
+	MaxUpload int64         // bytes of the whole request body
+	Timeout   time.Duration // time to analyze and render a recording
+	UnitNames map[uint16]string
+}
+
+// httpError is an error with the status code it should be answered with
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(format string, a ...interface{}) error {
+	return &httpError{http.StatusBadRequest, fmt.Errorf(format, a...)}
+}
+
+type server struct {
+	Config
+}
+
+// NewHandler returns a handler serving the analysis endpoints
+func NewHandler(cfg Config) http.Handler {
+	if cfg.MaxUpload <= 0 {
+		cfg.MaxUpload = DefaultMaxUpload
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	s := &server{cfg}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/analyze", s.handle(s.analyze))
+	mux.HandleFunc("/render/gif", s.handle(s.renderGif))
+	mux.HandleFunc("/render/chart", s.handle(s.renderChart))
+	return mux
+}
+
+// upload is a recording sent with a request and the other parts of its form
+type upload struct {
+	demo *bytes.Reader
+	form *multipart.Form
+}
+
+// part reads a file from the form, giving nil when it wasn't sent
+func (u *upload) part(name string) ([]byte, error) {
+	if u.form == nil || len(u.form.File[name]) == 0 {
+		return nil, nil
+	}
+	f, err := u.form.File[name][0].Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// handle reads the upload and runs an endpoint under the timeout, writing errors as JSON
+func (s *server) handle(endpoint func(ctx context.Context, w http.ResponseWriter, r *http.Request, u *upload) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, &httpError{http.StatusMethodNotAllowed, errors.New("recordings must be sent with POST")})
+			return
+		}
+		u, err := s.readUpload(w, r)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(r.Context(), s.Timeout)
+			defer cancel()
+			err = endpoint(ctx, w, r, u)
+		}
+		if err != nil {
+			writeError(w, err)
+		}
+	}
+}
+
+func (s *server) readUpload(w http.ResponseWriter, r *http.Request) (*upload, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.MaxUpload)
+	u := new(upload)
+	var data []byte
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err = r.ParseMultipartForm(s.MaxUpload); err == nil {
+			u.form = r.MultipartForm
+			data, err = u.part("demo")
+			if err == nil && data == nil {
+				return nil, badRequest("the form has no demo part")
+			}
+		}
+	} else {
+		data, err = io.ReadAll(r.Body)
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, &httpError{http.StatusRequestEntityTooLarge, fmt.Errorf("uploads are limited to %d bytes", s.MaxUpload)}
+	}
+	if err != nil {
+		return nil, badRequest("reading upload: %v", err)
+	}
+	if len(data) == 0 {
+		return nil, badRequest("no recording was sent")
+	}
+	u.demo = bytes.NewReader(data)
+	return u, nil
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var he *httpError
+	if errors.As(err, &he) {
+		status = he.status
+	}
+	if status == http.StatusInternalServerError {
+		log.WithFields(log.Fields{
+			"error": err,
+		}).Error("request failed")
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// open parses the recording, answering malformed ones with 422
+func open(ctx context.Context, u *upload) (*tad.Game, <-chan tad.PacketRec, error) {
+	gp, prs, err := tad.Analyze(ctx, u.demo)
+	if err != nil {
+		return nil, nil, &httpError{http.StatusUnprocessableEntity, fmt.Errorf("not a valid recording: %v", err)}
+	}
+	return gp, prs, nil
+}
+
+var errAnalysisTimeout = &httpError{http.StatusGatewayTimeout, errors.New("analysis took too long")}
+
+// analysisError answers a packet stream that ended early. It was cut off by the
+// timeout when it stopped with the context's error and otherwise it was malformed.
+func analysisError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return errAnalysisTimeout
+	}
+	return &httpError{http.StatusUnprocessableEntity, err}
+}
+
+// runWorkers runs tad.RunWorkers, answering an early end of the stream with
+// analysisError
+func runWorkers(gp *tad.Game, prs <-chan tad.PacketRec, workers ...func(stream chan tad.PacketRec) error) error {
+	err := tad.RunWorkers(prs, workers...)
+	if err == nil {
+		err = gp.Err()
+	}
+	if err != nil {
+		return analysisError(err)
+	}
+	return nil
+}
+
+func (s *server) analyze(ctx context.Context, w http.ResponseWriter, r *http.Request, u *upload) error {
+	report, err := tad.AnalyzeReport(ctx, u.demo, s.UnitNames)
+	if err != nil {
+		return analysisError(fmt.Errorf("not a valid recording: %w", err))
+	}
+	writeJSON(w, http.StatusOK, report)
+	return nil
+}
+
+// render runs draw in the background so the response can give up at the timeout. draw
+// should stop once ctx is done.
+func render(ctx context.Context, w http.ResponseWriter, contentType string, draw func(ctx context.Context, w io.Writer) error) error {
+	var buf bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- draw(ctx, &buf)
+	}()
+	select {
+	case <-ctx.Done():
+		return &httpError{http.StatusGatewayTimeout, errors.New("rendering took too long")}
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func (s *server) renderGif(ctx context.Context, w http.ResponseWriter, r *http.Request, u *upload) error {
+	var mapPic image.Image
+	// the map picture is usually scaled down so its size says nothing about the map's
+	size := r.FormValue("mapsize")
+	if size == "" {
+		return badRequest("mapsize is required")
+	}
+	var width, height int
+	if _, err := fmt.Sscanf(size, "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+		return badRequest("bad mapsize %q", size)
+	}
+	rect := image.Rect(0, 0, width, height)
+	picture, err := u.part("map")
+	if err != nil {
+		return badRequest("reading map picture: %v", err)
+	}
+	if picture != nil {
+		if mapPic, _, err = image.Decode(bytes.NewReader(picture)); err != nil {
+			return badRequest("reading map picture: %v", err)
+		}
+	}
+	if mapPic == nil {
+		// only the size is used which leaves the background transparent
+		mapPic = image.Rect(0, 0, 640, 640*rect.Dy()/rect.Dx())
+	}
+	var opts tad.RenderOptions
+	if interval := r.FormValue("interval"); interval != "" {
+		if opts.FrameInterval, err = strconv.Atoi(interval); err != nil || opts.FrameInterval < 0 {
+			return badRequest("bad interval %q", interval)
+		}
+	}
+	gp, prs, err := open(ctx, u)
+	if err != nil {
+		return err
+	}
+	var frames []tad.PlaybackFrame
+	err = runWorkers(gp, prs, func(stream chan tad.PacketRec) (err error) {
+		frames, err = tad.FramesWorkerWithOptions(stream, gp.MaxUnits, opts)
+		return
+	})
+	if err != nil {
+		return err
+	}
+	tad.InterpolateUnitMovement(frames)
+	if opts.ColorMap == nil {
+		opts.ColorMap = gp.MakeColorMap()
+	}
+	return render(ctx, w, "image/gif", func(ctx context.Context, w io.Writer) error {
+		return tad.RenderFrames(&ctxSink{ctx, tad.NewGifSink(w, false)}, frames, mapPic, rect, opts)
+	})
+}
+
+// ctxSink stops a render between frames once ctx is done
+type ctxSink struct {
+	ctx context.Context
+	tad.FrameSink
+}
+
+func (s *ctxSink) WriteFrame(img image.Image, delay time.Duration) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	return s.FrameSink.WriteFrame(img, delay)
+}
+
+// chartMetrics are the names of the metrics /render/chart takes
+var chartMetrics = func() map[string]tad.ChartMetric {
+	metrics := make(map[string]tad.ChartMetric)
+	for m := tad.MetalIncome; m <= tad.LossCount; m++ {
+		metrics[strings.ReplaceAll(m.String(), " ", "-")] = m
+	}
+	return metrics
+}()
+
+func (s *server) renderChart(ctx context.Context, w http.ResponseWriter, r *http.Request, u *upload) error {
+	name := r.FormValue("metric")
+	if name == "" {
+		name = "metal-income"
+	}
+	metric, ok := chartMetrics[name]
+	if !ok {
+		return badRequest("unknown metric %q", name)
+	}
+	gp, prs, err := open(ctx, u)
+	if err != nil {
+		return err
+	}
+	var series map[string][]tad.SPLite
+	var ttd [10]int
+	err = runWorkers(gp, prs, func(stream chan tad.PacketRec) (err error) {
+		series, err = tad.ScoreSeriesWorker(stream, tad.GenPnames(gp.Players))
+		return
+	}, func(stream chan tad.PacketRec) (err error) {
+		ttd, err = tad.TimeToDieWorker(stream, *gp)
+		return
+	})
+	if err != nil {
+		return err
+	}
+	chart := gp.NewChart(series, metric, ttd)
+	return render(ctx, w, "image/png", func(_ context.Context, w io.Writer) error {
+		return chart.WritePNG(w)
+	})
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cosmouser/tad"
+)
+
+func TestHandlerErrors(t *testing.T) {
+	h := NewHandler(Config{MaxUpload: 1024})
+	form := func(parts map[string]string) (*bytes.Buffer, string) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for name, content := range parts {
+			fw, _ := mw.CreateFormFile(name, name)
+			fw.Write([]byte(content))
+		}
+		mw.Close()
+		return &body, mw.FormDataContentType()
+	}
+	noDemo, noDemoType := form(map[string]string{"map": "x"})
+	garbage, garbageType := form(map[string]string{"demo": "not a recording"})
+	noSize, noSizeType := form(map[string]string{"demo": "not a recording", "map": "x"})
+	cases := []struct {
+		method, target, contentType string
+		body                        []byte
+		status                      int
+	}{
+		{http.MethodGet, "/analyze", "", nil, http.StatusMethodNotAllowed},
+		{http.MethodPost, "/analyze", "application/octet-stream", nil, http.StatusBadRequest},
+		{http.MethodPost, "/analyze", "application/octet-stream", make([]byte, 1025), http.StatusRequestEntityTooLarge},
+		{http.MethodPost, "/analyze", "application/octet-stream", []byte("not a recording"), http.StatusUnprocessableEntity},
+		{http.MethodPost, "/analyze", noDemoType, noDemo.Bytes(), http.StatusBadRequest},
+		{http.MethodPost, "/analyze", garbageType, garbage.Bytes(), http.StatusUnprocessableEntity},
+		{http.MethodPost, "/render/gif", "application/octet-stream", []byte("not a recording"), http.StatusBadRequest},
+		{http.MethodPost, "/render/gif", noSizeType, noSize.Bytes(), http.StatusBadRequest},
+		{http.MethodPost, "/render/gif?mapsize=100x100", "application/octet-stream", []byte("not a recording"), http.StatusUnprocessableEntity},
+		{http.MethodPost, "/render/chart?metric=nope", "application/octet-stream", []byte("not a recording"), http.StatusBadRequest},
+		{http.MethodPost, "/render/chart?metric=total-metal", "application/octet-stream", []byte("not a recording"), http.StatusUnprocessableEntity},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.target, bytes.NewReader(c.body))
+		if c.contentType != "" {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.status {
+			t.Errorf("%v %v answered %d, wanted %d: %v", c.method, c.target, rec.Code, c.status, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%v %v answered with %v, wanted a JSON error", c.method, c.target, ct)
+		}
+	}
+}
+
+func TestRunWorkers(t *testing.T) {
+	prs := make(chan tad.PacketRec)
+	go func() {
+		defer close(prs)
+		for i := 0; i < 10; i++ {
+			prs <- tad.PacketRec{Move: i, Data: []byte{0x2c}}
+		}
+	}()
+	var counted int
+	failure := errors.New("bad packet")
+	err := runWorkers(new(tad.Game), prs,
+		func(stream chan tad.PacketRec) error {
+			for range stream {
+				counted++
+			}
+			return nil
+		},
+		func(stream chan tad.PacketRec) error {
+			<-stream
+			return failure
+		},
+	)
+	if counted != 10 {
+		t.Errorf("wanted every packet after another worker failed, got %d", counted)
+	}
+	var he *httpError
+	if !errors.As(err, &he) || he.status != http.StatusUnprocessableEntity || !errors.Is(he.err, failure) {
+		t.Errorf("wanted the worker's error as a 422, got %v", err)
+	}
+}
+
+type countSink struct{ frames int }
+
+func (s *countSink) WriteFrame(img image.Image, delay time.Duration) error {
+	s.frames++
+	return nil
+}
+
+func (s *countSink) Close() error { return nil }
+
+func TestCtxSink(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	inner := new(countSink)
+	sink := &ctxSink{ctx, inner}
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	if err := sink.WriteFrame(img, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := sink.WriteFrame(img, time.Second); !errors.Is(err, context.Canceled) {
+		t.Errorf("wanted the render stopped once ctx was done, got %v", err)
+	}
+	if inner.frames != 1 {
+		t.Errorf("wanted 1 frame drawn, got %d", inner.frames)
+	}
+}
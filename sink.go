@@ -0,0 +1,285 @@
+package tad
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/cosmouser/tnt"
+	"github.com/fogleman/gg"
+)
+
+// FrameSink receives the frames of an animation in order. delay is how long the frame
+// is shown for. Close finishes the animation after the last frame.
+type FrameSink interface {
+	WriteFrame(img image.Image, delay time.Duration) error
+	Close() error
+}
+
+// RenderFrames draws the frames in full color over the map picture and writes them to
+// the sink in order. The sink is closed at the end.
+func RenderFrames(sink FrameSink, frames []PlaybackFrame, mapPic image.Image, rect image.Rectangle, opts RenderOptions) error {
+	frames = opts.windowFrames(frames)
+	times := make([]int, len(frames))
+	for i := range frames {
+		times[i] = frames[i].Time
+	}
+	next := 0
+	return renderFrames(sink, times, func() PlaybackFrame {
+		next++
+		return frames[next-1]
+	}, mapPic, rect, opts)
+}
+
+// RenderDeltaFrames draws delta frames like RenderFrames. Frames are rebuilt as they
+// are drawn so only a batch of them is in memory at once.
+func RenderDeltaFrames(sink FrameSink, df *DeltaFrames, mapPic image.Image, rect image.Rectangle, opts RenderOptions) error {
+	var times []int
+	for _, delta := range df.Deltas {
+		if opts.inWindow(delta.Time) {
+			times = append(times, delta.Time)
+		}
+	}
+	dp := &deltaPlayer{df: df}
+	return renderFrames(sink, times, func() PlaybackFrame {
+		for {
+			if frame := dp.step(); opts.inWindow(frame.Time) {
+				return frame
+			}
+		}
+	}, mapPic, rect, opts)
+}
+
+// renderFrames draws the frames that next gives back one after the other at the
+// times given. Frames are drawn a batch at a time in parallel so memory use doesn't
+// grow with the length of the game.
+func renderFrames(sink FrameSink, times []int, next func() PlaybackFrame, mapPic image.Image, rect image.Rectangle, opts RenderOptions) error {
+	delays := opts.frameDelays(times)
+	scale := opts.unitScale(mapPic.Bounds(), rect)
+	background := mapPicture(mapPic, opts)
+	batch := make([]*image.RGBA, runtime.NumCPU())
+	for start := 0; start < len(times); start += len(batch) {
+		end := start + len(batch)
+		if end > len(times) {
+			end = len(times)
+		}
+		var wg sync.WaitGroup
+		for i := start; i < end; i++ {
+			wg.Add(1)
+			go func(i int, frame PlaybackFrame) {
+				defer wg.Done()
+				img := image.NewRGBA(background.Bounds())
+				copy(img.Pix, background.Pix)
+				dc := gg.NewContextForRGBA(img)
+				units, effects := opts.perspective(frame.Units, frame.Effects)
+				opts.drawUnits(dc, units, scale)
+				opts.drawEffects(dc, effects, frame.Time, scale)
+				opts.drawCameras(dc, frame.Cameras, scale)
+				batch[i-start] = img
+			}(i, next())
+		}
+		wg.Wait()
+		for i := start; i < end; i++ {
+			if err := sink.WriteFrame(batch[i-start], time.Duration(delays[i])*10*time.Millisecond); err != nil {
+				return err
+			}
+			batch[i-start] = nil
+		}
+	}
+	return sink.Close()
+}
+
+// changedBounds returns the smallest rectangle inside b holding every pixel for which
+// same is false. It is empty when every pixel is the same.
+func changedBounds(b image.Rectangle, same func(x, y int) bool) image.Rectangle {
+	minX, minY, maxX, maxY := b.Max.X, b.Max.Y, b.Min.X, b.Min.Y
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if same(x, y) {
+				continue
+			}
+			if x < minX {
+				minX = x
+			}
+			if x >= maxX {
+				maxX = x + 1
+			}
+			if y < minY {
+				minY = y
+			}
+			maxY = y + 1
+		}
+	}
+	if maxX <= minX {
+		return image.Rectangle{}
+	}
+	return image.Rect(minX, minY, maxX, maxY)
+}
+
+// GifSink encodes frames as a GIF with the TA palette. With Diff set every frame
+// after the first only holds the pixels that changed which keeps files small.
+// Frames are written out as they come in apart from the newest one whose delay
+// grows when the frames after it are the same. A diff frame can't turn a pixel
+// back to transparent so when the first frame has transparent pixels every frame
+// is written whole and cleared once it has been shown.
+type GifSink struct {
+	w            io.Writer
+	Diff         bool
+	palette      color.Palette
+	size         image.Point
+	started      bool
+	transparent  bool
+	last         *image.Paletted
+	pending      *image.Paletted
+	pendingDelay int
+}
+
+// NewGifSink creates a GifSink that writes to w
+func NewGifSink(w io.Writer, diff bool) *GifSink {
+	return &GifSink{w: w, Diff: diff, palette: gifPalette()}
+}
+
+// gifPalette is the TA palette with index 0 made transparent for diff frames and
+// maps without a picture
+func gifPalette() color.Palette {
+	palette := append(color.Palette{}, tnt.TAPalette...)
+	palette[0] = image.Transparent
+	return palette
+}
+
+// WriteFrame quantizes the frame to the palette and writes out the frame before it
+func (gs *GifSink) WriteFrame(img image.Image, delay time.Duration) error {
+	full := image.NewPaletted(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()), gs.palette)
+	draw.Draw(full, full.Bounds(), img, img.Bounds().Min, draw.Src)
+	hundredths := int(delay / (10 * time.Millisecond))
+	frame := full
+	if gs.last == nil {
+		gs.size = full.Bounds().Size()
+		gs.transparent = bytes.IndexByte(full.Pix, 0) != -1
+	} else if gs.Diff {
+		changed := changedBounds(full.Bounds(), func(x, y int) bool {
+			return full.ColorIndexAt(x, y) == gs.last.ColorIndexAt(x, y)
+		})
+		if changed.Empty() {
+			// nothing moved so the last frame is shown for longer
+			gs.pendingDelay += hundredths
+			return nil
+		}
+		if !gs.transparent {
+			frame = image.NewPaletted(changed, full.Palette)
+			for y := changed.Min.Y; y < changed.Max.Y; y++ {
+				for x := changed.Min.X; x < changed.Max.X; x++ {
+					if idx := full.ColorIndexAt(x, y); idx != gs.last.ColorIndexAt(x, y) {
+						frame.SetColorIndex(x, y, idx)
+					}
+				}
+			}
+		}
+	}
+	if err := gs.flush(); err != nil {
+		return err
+	}
+	gs.pending, gs.pendingDelay = frame, hundredths
+	gs.last = full
+	return nil
+}
+
+// flush writes the pending frame. The first one also writes the GIF header with the
+// palette and a loop forever extension.
+func (gs *GifSink) flush() error {
+	if gs.pending == nil {
+		return nil
+	}
+	disposal := byte(gif.DisposalNone)
+	if gs.transparent {
+		disposal = gif.DisposalBackground
+	}
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, &gif.GIF{
+		Image:    []*image.Paletted{gs.pending},
+		Delay:    []int{gs.pendingDelay},
+		Disposal: []byte{disposal},
+		Config: image.Config{
+			ColorModel: gs.palette,
+			Width:      gs.size.X,
+			Height:     gs.size.Y,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	gs.pending = nil
+	encoded := buf.Bytes()
+	// the header is 13 bytes followed by the global color table
+	header := 13
+	if flags := encoded[10]; flags&0x80 != 0 {
+		header += 3 << ((flags & 0x07) + 1)
+	}
+	if !gs.started {
+		gs.started = true
+		if _, err := gs.w.Write(encoded[:header]); err != nil {
+			return err
+		}
+		if _, err := gs.w.Write([]byte("\x21\xff\x0bNETSCAPE2.0\x03\x01\x00\x00\x00")); err != nil {
+			return err
+		}
+	}
+	// the frame without the trailer
+	_, err = gs.w.Write(encoded[header : len(encoded)-1])
+	return err
+}
+
+// Close writes the last frame and ends the GIF
+func (gs *GifSink) Close() error {
+	if gs.last == nil {
+		return errors.New("gif needs at least one frame")
+	}
+	if err := gs.flush(); err != nil {
+		return err
+	}
+	_, err := gs.w.Write([]byte{0x3b})
+	return err
+}
+
+// PNGSequenceSink writes every frame to its own numbered PNG file for external encoders.
+// Files are named with Prefix and the frame number like frame00000.png.
+type PNGSequenceSink struct {
+	Dir    string
+	Prefix string
+	count  int
+}
+
+// NewPNGSequenceSink creates a PNGSequenceSink that writes into dir
+func NewPNGSequenceSink(dir, prefix string) *PNGSequenceSink {
+	return &PNGSequenceSink{Dir: dir, Prefix: prefix}
+}
+
+// WriteFrame writes the next file of the sequence. The delay is up to the encoder.
+func (ps *PNGSequenceSink) WriteFrame(img image.Image, delay time.Duration) error {
+	f, err := os.Create(filepath.Join(ps.Dir, fmt.Sprintf("%s%05d.png", ps.Prefix, ps.count)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ps.count++
+	if err := png.Encode(f, img); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Close does nothing as each file is closed once it is written
+func (ps *PNGSequenceSink) Close() error {
+	return nil
+}
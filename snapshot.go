@@ -0,0 +1,158 @@
+package tad
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// FrameAt returns the state of the game at tval milliseconds. Units are moved between
+// their positions in the frames on either side of tval with updatePos, stopping at the
+// later one once tval has passed it, and effects from both frames up to tval are kept.
+// The frames must be in time order like the output of FramesWorker.
+func FrameAt(frames []PlaybackFrame, tval int) PlaybackFrame {
+	out := PlaybackFrame{Time: tval, Units: make(map[uint16]*TAUnit)}
+	// i is the first frame after tval
+	i := sort.Search(len(frames), func(i int) bool { return frames[i].Time > tval })
+	if i == 0 {
+		return out
+	}
+	before := frames[i-1]
+	out.Number = before.Number
+	var after *PlaybackFrame
+	if i < len(frames) {
+		after = &frames[i]
+	}
+	for k, v := range before.Units {
+		if v == nil {
+			continue
+		}
+		tau := *v
+		tau.NextPos = point{}
+		if after != nil {
+			if next, ok := after.Units[k]; ok && next != nil && next.ID == tau.ID && next.Pos.ID != tau.Pos.ID && next.Pos.Time > tau.Pos.Time {
+				tau.NextPos = next.Pos
+				if tval >= next.Pos.Time {
+					// the unit got there before tval and moving on would be a guess
+					tau.Pos = next.Pos
+				} else {
+					tau.updatePos(tval)
+				}
+			}
+		}
+		out.Units[k] = &tau
+	}
+	out.Cameras = append(out.Cameras, before.Cameras...)
+	out.Effects = append(out.Effects, before.Effects...)
+	if after != nil {
+		// effects that happened between the frames without repeating those in both
+		seen := make(map[Effect]bool, len(before.Effects))
+		for _, ef := range before.Effects {
+			seen[ef] = true
+		}
+		for _, ef := range after.Effects {
+			if ef.Time <= tval && !seen[ef] {
+				out.Effects = append(out.Effects, ef)
+			}
+		}
+	}
+	return out
+}
+
+// SnapshotWorker replays a stream up to tval milliseconds and gives the state of the
+// game at that moment, so it has every unit built and none destroyed by then. Units
+// are moved toward the next position they reach within a frame interval with
+// updatePos. Like FrameAt the owners are player numbers.
+func SnapshotWorker(stream chan PacketRec, maxUnits int, tval int, opts RenderOptions) (snapshot PlaybackFrame, err error) {
+	interval := opts.FrameInterval
+	if interval <= 0 {
+		interval = defaultFrameInterval
+	}
+	c := newCapture(maxUnits, opts)
+	taken := false
+	take := func() {
+		taken = true
+		snapshot = PlaybackFrame{Time: tval, Units: make(map[uint16]*TAUnit, len(c.ut.units))}
+		for k, v := range c.ut.units {
+			tau := *v
+			snapshot.Units[k] = &tau
+		}
+		snapshot.Effects = c.opts.recentEffects(c.effects, tval)
+		snapshot.Cameras = latestCameras(c.cameras)
+	}
+	for pr := range stream {
+		clock := c.clockAt(pr)
+		if !taken && clock > tval {
+			take()
+		}
+		if taken && clock > tval+interval {
+			// the rest of the stream is drained
+			continue
+		}
+		if err := c.update(pr); err != nil {
+			return snapshot, err
+		}
+		if taken {
+			for k, tau := range snapshot.Units {
+				if next, ok := c.ut.units[k]; ok && tau.NextPos.ID == 0 && next.ID == tau.ID && next.Pos.ID != tau.Pos.ID {
+					tau.NextPos = next.Pos
+				}
+			}
+		}
+	}
+	if !taken {
+		take()
+	}
+	for _, tau := range snapshot.Units {
+		if tau.NextPos.ID != 0 {
+			tau.updatePos(tval)
+		}
+	}
+	return snapshot, nil
+}
+
+// RenderSnapshot writes a PNG of every unit's position at time t of a recording over
+// the map picture. The options need the MapSize and the units are colored with the
+// game's players when their ColorMap is nil.
+func RenderSnapshot(w io.Writer, demo io.ReadSeeker, t time.Duration, mapPic image.Image, opts RenderOptions) error {
+	if opts.MapSize.X <= 0 || opts.MapSize.Y <= 0 {
+		return errors.New("RenderSnapshot needs the MapSize of the options")
+	}
+	gp, prs, err := Analyze(context.Background(), demo)
+	if err != nil {
+		return err
+	}
+	var snapshot PlaybackFrame
+	err = RunWorkers(prs, func(stream chan PacketRec) (err error) {
+		snapshot, err = SnapshotWorker(stream, gp.MaxUnits, int(t/time.Millisecond), opts)
+		return
+	})
+	if err == nil {
+		err = gp.Err()
+	}
+	if err != nil {
+		return err
+	}
+	if opts.ColorMap == nil {
+		opts.ColorMap = gp.MakeColorMap()
+	}
+	return drawSnapshot(w, snapshot, mapPic, image.Rectangle{Max: opts.MapSize}, opts)
+}
+
+// drawSnapshot writes a PNG of one frame over the map picture. rect is the size of the
+// map in game pixels.
+func drawSnapshot(w io.Writer, snapshot PlaybackFrame, mapPic image.Image, rect image.Rectangle, opts RenderOptions) error {
+	dc := gg.NewContextForRGBA(mapPicture(mapPic, opts))
+	scale := opts.unitScale(mapPic.Bounds(), rect)
+	units, effects := opts.perspective(snapshot.Units, snapshot.Effects)
+	opts.drawUnits(dc, units, scale)
+	opts.drawEffects(dc, effects, snapshot.Time, scale)
+	opts.drawCameras(dc, snapshot.Cameras, scale)
+	return png.Encode(w, dc.Image())
+}
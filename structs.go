@@ -3,6 +3,7 @@ package tad
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 type sectorType int32
@@ -18,17 +19,28 @@ const (
 
 // Game holds the state of the replay parser
 type Game struct {
-	MapName      string
-	Players      []DemoPlayer
-	LobbyChat    []string
-	Version      string
-	RecFrom      string
-	RecDate      string
-	MaxUnits     int
-	TimeToDie    [10]int
-	TotalMoves   int
-	Milliseconds int
-	Unitsum      string
+	MapName        string
+	Players        []DemoPlayer
+	LobbyChat      []string
+	LobbyMessages  []LobbyMessage
+	Version        string
+	Recorder       RecorderVersion
+	RecFrom        string
+	RecDate        string
+	Recorded       time.Time
+	Comments       string
+	UnknownSectors []RawSector
+	MaxUnits       int
+	TimeToDie      [10]int
+	TotalMoves     int
+	Milliseconds   int
+	Unitsum        string
+	stream         *packetStream
+}
+
+// packetStream keeps the error that stopped the packets of a Game early
+type packetStream struct {
+	err error
 }
 
 type summary struct {
@@ -39,6 +51,21 @@ type summary struct {
 	MapName    [64]byte
 }
 
+// RawSector is an extra sector of a type the parser doesn't know
+type RawSector struct {
+	Type int32
+	Data []byte
+}
+
+// RecorderVersion is the version of the program that recorded the demo, parsed
+// from strings like "TA Demo Recorder 0.99b"
+type RecorderVersion struct {
+	Name    string
+	Major   int
+	Minor   int
+	Variant string
+}
+
 type extraSector struct {
 	sectorType // int32
 	data       []byte
@@ -123,12 +150,15 @@ type saveHealth struct {
 	Health   [5001]int32
 }
 
-// PlaybackFrame has the locations of each unit in the game at a specific point in time
+// PlaybackFrame has the locations of each unit in the game at a specific point in time,
+// the shots and explosions shortly before it and where each player's screen was.
 // It is used by DrawGif
 type PlaybackFrame struct {
-	Number int
-	Time   int
-	Units  map[uint16]*TAUnit
+	Number  int
+	Time    int
+	Units   map[uint16]*TAUnit
+	Effects []Effect
+	Cameras []CameraPosition
 }
 
 // TAUnit is a unit in the game
@@ -136,7 +166,7 @@ type TAUnit struct {
 	Owner    int
 	NetID    uint16
 	Finished bool
-	ID       string // uuid per unit per game
+	ID       int // numbers the units of a game from 1
 	Pos      point
 	NextPos  point
 	Class    unitClass
@@ -144,7 +174,7 @@ type TAUnit struct {
 type point struct {
 	X    int
 	Y    int
-	ID   string
+	ID   int // numbers the positions of a unit from 1, 0 is none
 	Time int
 }
 
@@ -163,7 +193,7 @@ type FinalScore struct {
 	IsLast  bool    `json:"isLast"`
 }
 
-// SPLite is a smaller version of a score packet. It only contains m/e per second.
+// SPLite is a smaller version of a score packet. It contains m/e per second and storage.
 // It also has a time value for easy plotting.
 type SPLite struct {
 	Kills        int
@@ -174,6 +204,10 @@ type SPLite struct {
 	TotalM       float64
 	ExcessE      float64
 	ExcessM      float64
+	StoredM      float64
+	StoredE      float64
+	StorageM     float64
+	StorageE     float64
 	Milliseconds int
 }
 type scoreError struct {
@@ -241,8 +275,16 @@ func (utr *UnitTypeRecord) GetKills() int {
 	return total
 }
 
-// PlayerMessage is an in-game message from a player with a millisecond timestamp
+// PlayerMessage is an in-game message from a player with a millisecond timestamp.
+// Message is the whole decoded line and Text is the part after the speaker.
 type PlayerMessage struct {
-	Message string
-	Sent    int
+	Message    string
+	Sent       int
+	Sender     int
+	SenderName string
+	Scope      ChatScope
+	Recipient  string
+	Text       string
+	Watcher    bool
+	Dead       bool
 }
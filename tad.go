@@ -6,12 +6,16 @@ import (
 	"crypto/md5"
 	"crypto/sha1"
 	"encoding/binary"
+	"encoding/gob"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"math"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/text/encoding/charmap"
 
@@ -25,20 +29,16 @@ const SY_UNIT = 2455016279
 // into the returned byte slice. It returns an error when reads
 // fail or are incomplete
 func loadSection(r io.Reader) (data []byte, err error) {
-	var (
-		length    uint16
-		bytesRead int
-	)
+	var length uint16
 	err = binary.Read(r, binary.LittleEndian, &length)
 	if err != nil {
 		return
 	}
-	data = make([]byte, int(length)-2)
-	bytesRead, err = r.Read(data)
-	if err == nil {
-		return
+	if length < 2 {
+		return nil, fmt.Errorf("section length %d is too short", length)
 	}
-	if bytesRead != len(data) {
+	data = make([]byte, int(length)-2)
+	if _, err = io.ReadFull(r, data); err == io.ErrUnexpectedEOF {
 		err = errors.New("short read")
 	}
 	return
@@ -124,19 +124,32 @@ func loadExtraSectors(r io.Reader, gp *Game) error {
 		}
 		switch extra.sectorType {
 		case commentsType:
-			log.WithFields(log.Fields{
-				"content": string(extra.data),
-			}).Info("comment(s) detected")
+			comments, err := decodeSectorString(extra)
+			if err != nil {
+				return err
+			}
+			// recordings can have more than one comments sector
+			if gp.Comments != "" {
+				comments = gp.Comments + "\n" + comments
+			}
+			gp.Comments = comments
 		case lobbyChatType:
 			lobbyChat, err := parseLobbyChat(extra)
 			if err != nil {
 				return err
 			}
 			gp.LobbyChat = lobbyChat
+			gp.LobbyMessages = parseLobbyMessages(lobbyChat)
 		case versionNumberType:
 			gp.Version = string(extra.data)
+			gp.Recorder = parseRecorderVersion(gp.Version)
 		case dateStringType:
 			gp.RecDate = string(extra.data)
+			if gp.Recorded, err = parseRecDate(gp.RecDate); err != nil {
+				log.WithFields(log.Fields{
+					"error": err,
+				}).Warn("recording date was not parsed")
+			}
 		case recFromType:
 			gp.RecFrom = string(extra.data)
 		case playerAddrType:
@@ -146,6 +159,11 @@ func loadExtraSectors(r io.Reader, gp *Game) error {
 			}
 			gp.Players[playerAddrNum].IP = addr
 			playerAddrNum++
+		default:
+			gp.UnknownSectors = append(gp.UnknownSectors, RawSector{
+				Type: int32(extra.sectorType),
+				Data: extra.data,
+			})
 		}
 	}
 	return nil
@@ -188,14 +206,79 @@ func parseSummary(r io.Reader) (sum summary, err error) {
 }
 
 func parseLobbyChat(extra extraSector) (messages []string, err error) {
+	textDecoder := charmap.Windows1252.NewDecoder()
 	raw := bytes.Split(extra.data, []byte{0x0d})
 	messages = make([]string, len(raw)-1)
 	for i := range messages {
-		messages[i] = string(raw[i])
+		messages[i], err = textDecoder.String(string(raw[i]))
+		if err != nil {
+			return nil, err
+		}
 	}
 	return
 }
 
+// decodeSectorString decodes the text of an extra sector up to its first null byte
+func decodeSectorString(extra extraSector) (string, error) {
+	textDecoder := charmap.Windows1252.NewDecoder()
+	return textDecoder.String(string(bytes.Split(extra.data, []byte{0x0})[0]))
+}
+
+// recDateLayouts are the date formats the date sector is read with. Only formats
+// that can't be misread are listed, so day and month first dates like 05/06/2019
+// are left unparsed rather than guessed.
+var recDateLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006/01/02 15:04:05",
+	"Mon Jan _2 15:04:05 2006",
+	"January 2, 2006 15:04:05",
+	time.RFC1123,
+	"2006-01-02",
+}
+
+// parseRecDate parses the date sector of a demo. The zero time is returned
+// along with an error when none of recDateLayouts match.
+func parseRecDate(recDate string) (time.Time, error) {
+	recDate = strings.TrimSpace(strings.TrimRight(recDate, "\x00"))
+	for _, layout := range recDateLayouts {
+		if t, err := time.Parse(layout, recDate); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown date format %q", recDate)
+}
+
+// parseRecorderVersion splits a version sector like "TA Demo Recorder 0.99b" into
+// the recorder's name, its major and minor version and the variant after them
+func parseRecorderVersion(version string) (rv RecorderVersion) {
+	version = strings.TrimSpace(strings.TrimRight(version, "\x00"))
+	start := strings.IndexAny(version, "0123456789")
+	if start == -1 {
+		rv.Name = version
+		return
+	}
+	rv.Name = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(version[:start]), "v"))
+	rest := version[start:]
+	end := 0
+	for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+		end++
+	}
+	rv.Major, _ = strconv.Atoi(rest[:end])
+	rest = rest[end:]
+	if strings.HasPrefix(rest, ".") {
+		rest = rest[1:]
+		end = 0
+		for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+			end++
+		}
+		rv.Minor, _ = strconv.Atoi(rest[:end])
+		rest = rest[end:]
+	}
+	rv.Variant = strings.TrimSpace(rest)
+	return
+}
+
 func parseAddressBlock(extra extraSector) (ab string, err error) {
 	addressData := simpleCrypt(extra.data)
 	ip := bytes.Split(addressData[0x50:], []byte{0x0})
@@ -311,7 +394,9 @@ func getGameLengthAndTTD(r io.Reader, gp *Game) error {
 	return nil
 }
 
-func prGenerator(ctx context.Context, r io.Reader, totalMoves int, maxUnits int) <-chan PacketRec {
+// prGenerator streams the packets of the moves in r. A move that can't be read stops
+// the stream and its error is kept in ps.
+func prGenerator(ctx context.Context, r io.Reader, totalMoves int, maxUnits int, ps *packetStream) <-chan PacketRec {
 	var (
 		lastDronePack   [10]uint32
 		posSyncComplete [10]uint32
@@ -325,6 +410,12 @@ func prGenerator(ctx context.Context, r io.Reader, totalMoves int, maxUnits int)
 	packetRecStream := make(chan PacketRec)
 	go func() {
 		defer close(packetRecStream)
+		defer func() {
+			// a recording the parser doesn't expect mustn't take the program down
+			if r := recover(); r != nil {
+				ps.err = fmt.Errorf("malformed move %d: %v", loopCount, r)
+			}
+		}()
 		for err != io.EOF && loopCount < totalMoves {
 			pr := PacketRec{}
 			pr, err = loadMove(r, loopCount-1)
@@ -332,21 +423,33 @@ func prGenerator(ctx context.Context, r io.Reader, totalMoves int, maxUnits int)
 				log.WithFields(log.Fields{
 					"error": err,
 				}).Error("prGenerator failed to load move")
+				ps.err = fmt.Errorf("loading move %d: %v", loopCount, err)
 				break
 			}
-			cpdb := make([]byte, len(pr.Data))
-			for i := range pr.Data {
-				cpdb[i] = pr.Data[i]
+			if err == io.EOF {
+				break
 			}
+			if pr.Sender > 10 || pr.Sender < 1 {
+				// getGameLengthAndTTD didn't count these moves either
+				log.WithFields(log.Fields{
+					"sender": pr.Sender,
+					"move":   pr.Move,
+				}).Warn("skipping move from odd sender")
+				continue
+			}
+			var cpdb []byte
 			if recentPos[int(pr.Sender)-1] {
 				recentPos[int(pr.Sender)-1] = false
-				cpdb = unsmartpak(pr, &masterHealth, lastDronePack, false)
+				if _, err = unsmartpak(pr, &masterHealth, lastDronePack, false); err != nil {
+					ps.err = fmt.Errorf("unpacking move %d: %v", pr.Move, err)
+					return
+				}
 				posSyncComplete[int(pr.Sender)-1] = lastDronePack[int(pr.Sender)-1] + uint32(maxUnits)
 			}
-			if lastDronePack[int(pr.Sender)-1] < posSyncComplete[int(pr.Sender)-1] {
-				cpdb = unsmartpak(pr, &masterHealth, lastDronePack, false)
-			} else {
-				cpdb = unsmartpak(pr, &masterHealth, lastDronePack, true)
+			cpdb, err = unsmartpak(pr, &masterHealth, lastDronePack, lastDronePack[int(pr.Sender)-1] >= posSyncComplete[int(pr.Sender)-1])
+			if err != nil {
+				ps.err = fmt.Errorf("unpacking move %d: %v", pr.Move, err)
+				return
 			}
 			cpdb = append([]byte{cpdb[0], 'c', 'c', 0xff, 0xff, 0xff, 0xff}, cpdb[1:]...)
 			if len(cpdb) > 7 {
@@ -361,12 +464,16 @@ func prGenerator(ctx context.Context, r io.Reader, totalMoves int, maxUnits int)
 					}
 					select {
 					case <-ctx.Done():
+						ps.err = ctx.Err()
 						return
 					case packetRecStream <- msg:
 					}
 
 					switch tmp[0] {
 					case 0x2c:
+						if len(tmp) < 7 {
+							break
+						}
 						ip := binary.LittleEndian.Uint32(tmp[3:])
 						lastSerial[int(pr.Sender)-1] = ip
 					}
@@ -386,6 +493,9 @@ func loadMove(r io.Reader, lastMove int) (pr PacketRec, err error) {
 	if err != nil {
 		return pr, err
 	}
+	if len(dat) < 4 {
+		return pr, fmt.Errorf("move of %d bytes is too short", len(dat))
+	}
 	datr := bytes.NewReader(dat)
 	err = binary.Read(datr, binary.LittleEndian, &pr.Time)
 	if err != nil {
@@ -424,7 +534,7 @@ func decompressLZ77(compressed []byte, prefixLen int) (decompressed []byte, err
 	var window [4096]byte
 	var windowPos = 1
 	var writeBuf bytes.Buffer
-	if compressed[0] != 0x04 {
+	if len(compressed) < prefixLen || compressed[0] != 0x04 {
 		return compressed, nil
 	}
 	if err := writeBuf.WriteByte(0x03); err != nil {
@@ -514,12 +624,8 @@ func simpleCrypt(in []byte) []byte {
 	}
 	return out
 }
-func getGameOffset(rs io.ReadSeeker) int64 {
-	n, err := rs.Seek(0, io.SeekCurrent)
-	if err != nil {
-		panic(err)
-	}
-	return n
+func getGameOffset(rs io.ReadSeeker) (int64, error) {
+	return rs.Seek(0, io.SeekCurrent)
 }
 
 func deserialize(move PacketRec) (subs [][]byte, err error) {
@@ -537,8 +643,11 @@ func deserialize(move PacketRec) (subs [][]byte, err error) {
 		}
 	}
 	readPos := 1
-	for {
-		out := splitPacket(tmp[readPos:])
+	for readPos < len(tmp) {
+		out, err := splitPacket(tmp[readPos:])
+		if err != nil {
+			return subs, err
+		}
 		if len(out) == 0 {
 			break
 		} else {
@@ -550,11 +659,11 @@ func deserialize(move PacketRec) (subs [][]byte, err error) {
 }
 func playbackMsg(sender byte, data []byte, names map[uint16]string, unitmem map[uint16]uint16) string {
 	tap, err := loadTAPacket(data)
-	if err != nil && err != io.EOF {
-		log.Fatal(err)
+	if err == io.EOF {
+		return ""
 	}
 	if err != nil {
-		return ""
+		return fmt.Sprintf("player %d sent a bad packet: %v", sender, err)
 	}
 	msg := fmt.Sprintf("player %d sent %v", sender, tap.printMessage(names, unitmem))
 	switch tap.GetMarker() {
@@ -567,6 +676,9 @@ func playbackMsg(sender byte, data []byte, names map[uint16]string, unitmem map[
 }
 
 func loadTAPacket(pdata []byte) (taPacket, error) {
+	if len(pdata) == 0 {
+		return nil, io.EOF
+	}
 	pr := bytes.NewReader(pdata)
 	switch pdata[0] {
 	case 0x28:
@@ -625,6 +737,20 @@ func loadTAPacket(pdata []byte) (taPacket, error) {
 			return tmp, err
 		}
 		return tmp, nil
+	case 0x0d:
+		tmp := &packet0x0d{}
+		err := binary.Read(pr, binary.LittleEndian, tmp)
+		if err != nil {
+			return tmp, err
+		}
+		return tmp, nil
+	case 0x23:
+		tmp := &packet0x23{}
+		err := binary.Read(pr, binary.LittleEndian, tmp)
+		if err != nil {
+			return tmp, err
+		}
+		return tmp, nil
 	}
 	tmp := &packetDefault{}
 	b, err := pr.ReadByte()
@@ -639,29 +765,38 @@ func loadTAPacket(pdata []byte) (taPacket, error) {
 	}
 	return tmp, nil
 }
-func unsmartpak(pr PacketRec, save *saveHealth, last2cs [10]uint32, incnon2c bool) []byte {
+func unsmartpak(pr PacketRec, save *saveHealth, last2cs [10]uint32, incnon2c bool) ([]byte, error) {
 	var packnum uint32
 	var ut []byte
 	var packout bytes.Buffer
+	if len(pr.Data) == 0 || pr.Sender < 1 || pr.Sender > 10 {
+		return nil, fmt.Errorf("move of %d bytes from player %d can't be unpacked", len(pr.Data), pr.Sender)
+	}
 	c := []byte(string(pr.Data[0]) + "xx" + string(pr.Data[1:]))
 	if c[0] == 0x04 {
 		ctmp, err := decompressLZ77([]byte(c), 3)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		c = ctmp
 	}
+	if len(c) <= 3 {
+		return []byte{0x3}, nil
+	}
 	c = c[3:]
 	for {
 		s := splitPacket2(&c, true)
 		switch s[0] {
 		case 0xfe:
+			if len(s) < 5 {
+				return nil, fmt.Errorf("fe packet of %d bytes is too short", len(s))
+			}
 			packnum = binary.LittleEndian.Uint32(s[1:])
 			last2cs[int(pr.Sender)-1] = packnum
 		case 0xff:
 			err := binary.Write(&packout, binary.LittleEndian, packnum)
 			if err != nil {
-				log.Fatal(err)
+				return nil, err
 			}
 			packoutData := packout.Bytes()
 			packout.Reset()
@@ -670,23 +805,31 @@ func unsmartpak(pr PacketRec, save *saveHealth, last2cs [10]uint32, incnon2c boo
 			last2cs[int(pr.Sender)-1] = packnum
 			ut = append(ut, tmp...)
 		case 0xfd:
+			if len(s) < 3 {
+				return nil, fmt.Errorf("fd packet of %d bytes is too short", len(s))
+			}
 			err := binary.Write(&packout, binary.LittleEndian, packnum)
 			if err != nil {
-				log.Fatal(err)
+				return nil, err
 			}
 			packoutData := packout.Bytes()
 			packout.Reset()
 			tmp := append(s[:3], append(packoutData, s[3:]...)...)
-			rw := binary.LittleEndian.Uint16(tmp[7:])
-			if rw == 0xffff {
+			if len(tmp) >= 9 && binary.LittleEndian.Uint16(tmp[7:]) == 0xffff {
+				if len(tmp) < 14 || save.MaxUnits <= 0 {
+					return nil, fmt.Errorf("fd packet of %d bytes has no room for health", len(s))
+				}
 				nh := binary.LittleEndian.Uint32(tmp[10:])
-				save.Health[int(packnum%uint32(save.MaxUnits))] = int32(nh)
+				save.Health[int(packnum%uint32(save.MaxUnits))%len(save.Health)] = int32(nh)
 			}
 			packnum++
 			last2cs[int(pr.Sender)-1] = packnum
 			tmp[0] = 0x2c
 			ut = append(ut, tmp...)
 		case 0x2c:
+			if len(s) < 7 {
+				return nil, fmt.Errorf("2c packet of %d bytes is too short", len(s))
+			}
 			last2cs[int(pr.Sender)-1] = binary.LittleEndian.Uint32(s[3:])
 		default:
 			if incnon2c {
@@ -694,7 +837,7 @@ func unsmartpak(pr PacketRec, save *saveHealth, last2cs [10]uint32, incnon2c boo
 			}
 		}
 		if len(c) == 0 {
-			return append([]byte{0x3}, ut...)
+			return append([]byte{0x3}, ut...), nil
 		}
 	}
 }
@@ -775,7 +918,7 @@ func splitPacket2(data *[]byte, smartpak bool) (out []byte) {
 	}
 	return
 }
-func splitPacket(data []byte) (out []byte) {
+func splitPacket(data []byte) (out []byte, err error) {
 	if len(data) == 0 {
 		out = []byte{}
 		return
@@ -832,12 +975,10 @@ func splitPacket(data []byte) (out []byte) {
 		out = []byte{}
 		return
 	}
-	out = make([]byte, pl)
-	dr := bytes.NewReader(data)
-	bytesRead, err := dr.Read(out)
-	if bytesRead != pl || err != nil {
-		log.Fatalf("failed read for %02x packet", data[0])
+	if len(data) < pl {
+		return nil, fmt.Errorf("failed read for %02x packet", data[0])
 	}
+	out = append([]byte{}, data[:pl]...)
 	return
 }
 
@@ -866,6 +1007,27 @@ func (gp *Game) GetFingerprint() string {
 	return fmt.Sprintf("%x", sha1.Sum([]byte(party)))
 }
 
+// GetGameKey returns a hash that tells games apart where the fingerprint can't. A rematch
+// in the same lobby has the fingerprint of the game before it but not its length, moves
+// and unitsum. Copies of a recording share both.
+func (gp *Game) GetGameKey() string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(fmt.Sprintf("%v%v%v%v",
+		gp.GetFingerprint(),
+		gp.Unitsum,
+		gp.TotalMoves,
+		gp.Milliseconds,
+	))))
+}
+
+// LoadUnitNames decodes a gob of NetIDs to unit names like taesc900.gob
+func LoadUnitNames(r io.Reader) (names map[uint16]string, err error) {
+	names = make(map[uint16]string)
+	if err = gob.NewDecoder(r).Decode(&names); err != nil {
+		return nil, err
+	}
+	return
+}
+
 // GenPnames creates a non-alphabetical map of packet from to player name
 func GenPnames(players []DemoPlayer) map[byte]string {
 	pnames := make(map[byte]string)
@@ -1020,6 +1182,10 @@ func GenScoreSeries(list []PacketRec, pnameMap map[byte]string) (series map[stri
 			litePacket.TotalM = float64(scorePacket.TotalM)
 			litePacket.ExcessE = float64(scorePacket.ExcessE)
 			litePacket.ExcessM = float64(scorePacket.ExcessM)
+			litePacket.StoredM = float64(scorePacket.StoredM)
+			litePacket.StoredE = float64(scorePacket.StoredE)
+			litePacket.StorageM = float64(scorePacket.StorageM)
+			litePacket.StorageE = float64(scorePacket.StorageE)
 			if math.IsNaN(litePacket.Energy) || math.IsInf(litePacket.Energy, 1) {
 				litePacket.Energy = 1
 			}
@@ -8,22 +8,36 @@ import (
 	"encoding/csv"
 	"encoding/gob"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/png"
 	"io"
+	"math"
 	"net"
 	"os"
 	"path"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/fogleman/gg"
 	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/cosmouser/tad/internal/tedtest"
 )
 
+var update = flag.Bool("update", false, "rewrite golden files")
+
 var sample1 = path.Join("sample", "dckazikdidou.ted")
 var sample2 = path.Join("sample", "dcfnhessano.ted")
 var sample3 = path.Join("sample", "highground.ted")
@@ -654,7 +668,6 @@ func TestUnitCounts(t *testing.T) {
 				Owner:    int(pr.Sender),
 				NetID:    tmp.NetID,
 				Finished: false,
-				ID:       uuid.New().String(),
 			}
 		}
 		if pr.Data[0] == 0x12 {
@@ -812,7 +825,10 @@ func loadDemo(r io.ReadSeeker, testFunc func(PacketRec, *Game)) error {
 	}
 	sumArr := md5.Sum(sumSlice.Bytes())
 	g.Unitsum = hex.EncodeToString(sumArr[:])
-	gameOffset := getGameOffset(r)
+	gameOffset, err := getGameOffset(r)
+	if err != nil {
+		return err
+	}
 	var loopCount int
 	for err != io.EOF {
 		pr := PacketRec{}
@@ -856,13 +872,22 @@ func loadDemo(r io.ReadSeeker, testFunc func(PacketRec, *Game)) error {
 		}
 		if recentPos[int(pr.Sender)-1] {
 			recentPos[int(pr.Sender)-1] = false
-			cpdb = unsmartpak(pr, &masterHealth, lastDronePack, false)
+			cpdb, err = unsmartpak(pr, &masterHealth, lastDronePack, false)
+			if err != nil {
+				return err
+			}
 			posSyncComplete[int(pr.Sender)-1] = lastDronePack[int(pr.Sender)-1] + uint32(g.MaxUnits)
 		}
 		if lastDronePack[int(pr.Sender)-1] < posSyncComplete[int(pr.Sender)-1] {
-			cpdb = unsmartpak(pr, &masterHealth, lastDronePack, false)
+			cpdb, err = unsmartpak(pr, &masterHealth, lastDronePack, false)
+			if err != nil {
+				return err
+			}
 		} else {
-			cpdb = unsmartpak(pr, &masterHealth, lastDronePack, true)
+			cpdb, err = unsmartpak(pr, &masterHealth, lastDronePack, true)
+			if err != nil {
+				return err
+			}
 		}
 		cpdb = append([]byte{cpdb[0], 'c', 'c', 0xff, 0xff, 0xff, 0xff}, cpdb[1:]...)
 		if len(cpdb) > 7 {
@@ -1006,6 +1031,94 @@ func TestLoadSection(t *testing.T) {
 	tf.Close()
 }
 
+func TestMalformedMoves(t *testing.T) {
+	move := func(sender byte, data []byte) []byte {
+		b := make([]byte, 5, 5+len(data))
+		binary.LittleEndian.PutUint16(b, uint16(5+len(data)))
+		b[4] = sender
+		return append(b, data...)
+	}
+	var demo []byte
+	demo = append(demo, move(0, []byte{0x03, 0x00, 0x00})...)
+	demo = append(demo, move(1, []byte{0x04, 0xff})...)
+	demo = append(demo, move(2, bytes.Repeat([]byte{0xfd}, 40))...)
+	// a move that claims more bytes than are left
+	demo = append(demo, 0x40, 0x00, 0x10, 0x00, 0x01)
+
+	ps := new(packetStream)
+	prs := prGenerator(context.Background(), bytes.NewReader(demo), 10, 500, ps)
+	for range prs {
+	}
+	if ps.err == nil {
+		t.Error("wanted an error from the malformed moves")
+	}
+
+	// a stream cut short by its context ends with the context's error
+	ctx, cancel := context.WithCancel(context.Background())
+	ps = new(packetStream)
+	prs = prGenerator(ctx, bytes.NewReader(append(move(1, []byte{0x03, 0xff}), move(1, []byte{0x03, 0xff})...)), 10, 500, ps)
+	cancel()
+	for range prs {
+	}
+	if ps.err != context.Canceled {
+		t.Errorf("wanted %v from a cancelled stream, got %v", context.Canceled, ps.err)
+	}
+
+	// RunWorkers turns a panicking worker into an error
+	stream := make(chan PacketRec, 1)
+	stream <- PacketRec{Sender: 1, Data: []byte{0x2c}}
+	close(stream)
+	err := RunWorkers(stream, func(stream chan PacketRec) error {
+		for pr := range stream {
+			_ = pr.Data[10]
+		}
+		return nil
+	})
+	if err == nil {
+		t.Error("wanted the worker's panic as an error")
+	}
+}
+
+func TestGameKey(t *testing.T) {
+	demo := func(moves int) *Game {
+		d := &tedtest.Demo{
+			Map:      "Dark Comet",
+			MaxUnits: 500,
+			Players: []tedtest.Player{
+				{Number: 1, Name: "Kazik", Color: 0, TDPID: 11},
+				{Number: 2, Name: "Fez", Side: 1, Color: 1, TDPID: 12},
+			},
+			Units: []uint32{7, 235},
+		}
+		for i := 0; i < moves; i++ {
+			d.Moves = append(d.Moves, tedtest.Move{Time: 100, Sender: byte(i%2 + 1), Data: tedtest.Packets([]byte{0xff})})
+		}
+		gp, prs, err := Analyze(context.Background(), bytes.NewReader(d.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range prs {
+		}
+		if err := gp.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return gp
+	}
+	game, copied, rematch := demo(10), demo(10), demo(12)
+	if game.MapName != "Dark Comet" || len(game.Players) != 2 || game.Players[1].TDPID != 12 || game.TotalMoves != 10 {
+		t.Fatalf("the demo wasn't read back, got %+v", game)
+	}
+	if game.GetGameKey() != copied.GetGameKey() {
+		t.Error("wanted copies of a game to share their key")
+	}
+	if game.GetFingerprint() != rematch.GetFingerprint() {
+		t.Error("wanted a rematch in the same lobby to share the fingerprint")
+	}
+	if game.GetGameKey() == rematch.GetGameKey() {
+		t.Error("wanted a rematch to have a key of its own")
+	}
+}
+
 func TestParseSummary(t *testing.T) {
 	tf, err := os.Open(sample1)
 	if err != nil {
@@ -1230,7 +1343,10 @@ func TestReadHeaders(t *testing.T) {
 	}
 	t.Logf("len of upd: %v", len(upd))
 	playerMetadata := savePlayers{}
-	gameOffset := getGameOffset(tf)
+	gameOffset, err := getGameOffset(tf)
+	if err != nil {
+		t.Fatal(err)
+	}
 	nExpected := 13841
 	if int(gameOffset) != nExpected {
 		t.Errorf("got %v for gameOffset, was expecting %v", gameOffset, nExpected)
@@ -1293,13 +1409,22 @@ func TestReadHeaders(t *testing.T) {
 		// prevPack := lastDronePack[int(pr.Sender)-1]
 		if recentPos[int(pr.Sender)-1] {
 			recentPos[int(pr.Sender)-1] = false
-			cpdb = unsmartpak(pr, &masterHealth, lastDronePack, false)
+			cpdb, err = unsmartpak(pr, &masterHealth, lastDronePack, false)
+			if err != nil {
+				t.Fatal(err)
+			}
 			posSyncComplete[int(pr.Sender)-1] = lastDronePack[int(pr.Sender)-1] + maxunits
 		}
 		if lastDronePack[int(pr.Sender)-1] < posSyncComplete[int(pr.Sender)-1] {
-			cpdb = unsmartpak(pr, &masterHealth, lastDronePack, false)
+			cpdb, err = unsmartpak(pr, &masterHealth, lastDronePack, false)
+			if err != nil {
+				t.Fatal(err)
+			}
 		} else {
-			cpdb = unsmartpak(pr, &masterHealth, lastDronePack, true)
+			cpdb, err = unsmartpak(pr, &masterHealth, lastDronePack, true)
+			if err != nil {
+				t.Fatal(err)
+			}
 		}
 		cpdb = append([]byte{cpdb[0], 'c', 'c', 0xff, 0xff, 0xff, 0xff}, cpdb[1:]...)
 		// fmMain.timemode.Checked section -- omitted
@@ -1609,6 +1734,7 @@ func TestDrawGif(t *testing.T) {
 	var clock, lastTime int
 	var lastMove int
 	var unitSpaces [10]uint16
+	var lastID int
 	var gp *Game
 	err = loadDemo(tf, func(pr PacketRec, g *Game) {
 		gp = g
@@ -1621,6 +1747,7 @@ func TestDrawGif(t *testing.T) {
 			if err := binary.Read(bytes.NewReader(pr.Data), binary.LittleEndian, tmp); err != nil {
 				t.Error(err)
 			}
+			lastID++
 			unitmem[tmp.UnitID] = &TAUnit{
 				Owner:    int(pr.Sender),
 				NetID:    tmp.NetID,
@@ -1628,10 +1755,10 @@ func TestDrawGif(t *testing.T) {
 				Pos: point{
 					X:    int(tmp.XPos),
 					Y:    int(tmp.YPos),
-					ID:   uuid.New().String(),
+					ID:   1,
 					Time: clock,
 				},
-				ID: uuid.New().String(),
+				ID: lastID,
 			}
 			// check to see if its the first unit aka commander
 			if int(tmp.UnitID)%g.MaxUnits == 1 {
@@ -1692,13 +1819,13 @@ func TestDrawGif(t *testing.T) {
 				tau.Pos.X = int(tmp.OriginX)
 				tau.Pos.Y = int(tmp.OriginY)
 				tau.Pos.Time = clock
-				tau.Pos.ID = uuid.New().String()
+				tau.Pos.ID++
 			}
 			if tau, ok := unitmem[tmp.ShotID]; ok && tau != nil && tau.Class != buildingClass {
 				tau.Pos.X = int(tmp.DestX)
 				tau.Pos.Y = int(tmp.DestY)
 				tau.Pos.Time = clock
-				tau.Pos.ID = uuid.New().String()
+				tau.Pos.ID++
 			}
 		}
 		if pr.Data[0] == 0x2c && len(pr.Data) >= 0x1a {
@@ -1713,7 +1840,7 @@ func TestDrawGif(t *testing.T) {
 					tau.Pos.X = int(x2cXPos) * 16
 					tau.Pos.Y = int(x2cYPos) * 16
 					tau.Pos.Time = clock
-					tau.Pos.ID = uuid.New().String()
+					tau.Pos.ID++
 				}
 			}
 		}
@@ -1739,17 +1866,12 @@ func TestDrawGif(t *testing.T) {
 		}
 	}
 	// update frames with calculated unit positions
-	nullPoint := point{
-		X:    0,
-		Y:    0,
-		ID:   uuid.New().String(),
-		Time: 0,
-	}
+	nullPoint := point{ID: -1}
 	for i := range frames {
 		for tauID, tau := range frames[i].Units {
 			tau.Owner = colorMap[tau.Owner]
 			toChange := []int{}
-			if tau.NextPos.ID == "" {
+			if tau.NextPos.ID == 0 {
 				nextFrame := 0
 				for f := i; f < len(frames); f++ {
 					if unit, ok := frames[f].Units[tauID]; !ok || unit.ID != tau.ID {
@@ -1801,3 +1923,1285 @@ func TestDrawGif(t *testing.T) {
 	out.Close()
 	tf.Close()
 }
+
+// packetBytes encodes a packet struct the way it appears in a PacketRec
+func packetBytes(t *testing.T, p interface{}) []byte {
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, p); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+func TestCheatDetector(t *testing.T) {
+	gp := Game{
+		MaxUnits: 500,
+		Players: []DemoPlayer{
+			{Name: "alpha", Number: 1},
+			{Name: "bravo", Number: 2, Cheats: true},
+		},
+	}
+	msg := packet0x05{Marker: 0x05}
+	copy(msg.Message[:], "<alpha> .atm")
+	position := func(x, y int) []byte {
+		// an unsmartpaked 0x2c of alpha's commander
+		b := make([]byte, 0x1a)
+		b[0] = 0x2c
+		binary.LittleEndian.PutUint16(b[0x9:], 7+0xc00)
+		binary.LittleEndian.PutUint16(b[0xb:], uint16(x/16))
+		binary.LittleEndian.PutUint16(b[0xd:], uint16(y/16))
+		return b
+	}
+	packets := []PacketRec{
+		{Time: 100, Sender: 1, Move: 1, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 1})},
+		{Time: 100, Sender: 1, Move: 2, Data: packetBytes(t, &packet0x28{Marker: 0x28, Kills: 3, StoredM: 10, StorageM: 100, StorageE: 100})},
+		// kills go down, metal is over storage and 500 metal a second is more than a commander makes
+		{Time: 1000, Sender: 1, Move: 3, Data: packetBytes(t, &packet0x28{Marker: 0x28, Kills: 2, StoredM: 900, StorageM: 100, StorageE: 100, TotalM: 500})},
+		{Time: 100, Sender: 1, Move: 4, Data: packetBytes(t, &msg)},
+		{Time: 100, Sender: 1, Move: 5, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 8, UnitID: 2})},
+		{Time: 100, Sender: 1, Move: 6, Data: packetBytes(t, &packet0x12{Marker: 0x12, BuiltID: 2, BuiltByID: 1})},
+		{Time: 100, Sender: 1, Move: 7, Data: position(1024, 1024)},
+		{Time: 100, Sender: 1, Move: 8, Data: position(4096, 4096)},
+		{Time: 100, Sender: 2, Move: 9, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 501})},
+		{Time: 100, Sender: 2, Move: 10, Data: packetBytes(t, &packet0x0c{Marker: 0x0c, Destroyed: 501})},
+		{Time: 100, Sender: 2, Move: 11, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 8, UnitID: 502})},
+	}
+	units := UnitTable{
+		7: {Name: "ARMCOM", MetalMake: 1.5, EnergyMake: 25},
+		8: {Name: "ARMSOLAR", BuildTime: 5000, EnergyMake: 20},
+	}
+	stream := make(chan PacketRec)
+	go func() {
+		defer close(stream)
+		for _, pr := range packets {
+			stream <- pr
+		}
+	}()
+	report, err := NewCheatDetector(gp, units).Worker(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[FindingKind][]int{
+		CheatsEnabled:    {2},
+		ScoreRegression:  {1},
+		ImpossibleIncome: {1},
+		FastBuild:        {1},
+		Teleport:         {1},
+		OverStorage:      {1},
+		DotCommand:       {1},
+		DeadSender:       {2},
+	}
+	got := make(map[FindingKind][]int)
+	for _, f := range report.Findings {
+		got[f.Kind] = append(got[f.Kind], f.Player)
+		t.Logf("%v: %v at move %d (%d ms): %v", f.Kind, f.Name, f.Move, f.Milliseconds, f.Evidence)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted the players of each kind of finding to be %v, got %v", want, got)
+	}
+	if len(report.Suspects()) != 2 {
+		t.Errorf("wanted 2 suspects, got %v", report.Suspects())
+	}
+
+	// names alone leave out the rules that need unit data
+	for _, rule := range DefaultCheatRules(NewUnitTable(map[uint16]string{7: "ARMCOM"})) {
+		switch rule.(type) {
+		case *IncomeRule, *BuildTimeRule:
+			t.Errorf("wanted no %T without unit data", rule)
+		}
+	}
+	if n := len(DefaultCheatRules(units)); n != 7 {
+		t.Errorf("wanted every rule with unit data, got %d", n)
+	}
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	NewCheatDetector(gp, nil, &IncomeRule{}, &BuildTimeRule{})
+	if warnings := len(hook.AllEntries()); warnings != 2 {
+		t.Errorf("wanted warnings about the income and build time rules, got %d", warnings)
+	}
+}
+func TestResourceWorker(t *testing.T) {
+	samples := []packet0x28{
+		{Marker: 0x28, StoredM: 500, StorageM: 1000, StoredE: 500, StorageE: 1000},
+		{Marker: 0x28, StoredM: 1000, StorageM: 1000, StoredE: 0, StorageE: 1000, ExcessM: 100},
+		{Marker: 0x28, StoredM: 1000, StorageM: 1000, StoredE: 0, StorageE: 1000, ExcessM: 2100},
+		{Marker: 0x28, StoredM: 1000, StorageM: 1000, StoredE: 300, StorageE: 1000, ExcessM: 4100},
+		{Marker: 0x28, StoredM: 200, StorageM: 1000, StoredE: 300, StorageE: 1000, ExcessM: 4100},
+	}
+	stream := make(chan PacketRec)
+	go func() {
+		defer close(stream)
+		for i := range samples {
+			stream <- PacketRec{Time: minuteInMilliseconds, Sender: 1, Move: i + 1, Data: packetBytes(t, &samples[i])}
+		}
+	}()
+	reports, err := ResourceWorker(stream, map[byte]string{1: "alpha"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := reports["alpha"]
+	if rr == nil || len(rr.Samples) != len(samples) {
+		t.Fatalf("wanted %d samples for alpha, got %+v", len(samples), rr)
+	}
+	if rr.WastedM != 4100 {
+		t.Errorf("wanted 4100 wasted metal, got %v", rr.WastedM)
+	}
+	if rr.EnergyStalled != 2*minuteInMilliseconds {
+		t.Errorf("wanted energy stall of 2 minutes, got %v ms", rr.EnergyStalled)
+	}
+	for _, c := range rr.Callouts() {
+		t.Log(c)
+	}
+
+	// metal storage without energy storage isn't an energy stall
+	stream = make(chan PacketRec)
+	go func() {
+		defer close(stream)
+		for i := 0; i < 3; i++ {
+			sample := packet0x28{Marker: 0x28, StoredM: 500, StorageM: 1000, ExcessE: float32(100 * i)}
+			stream <- PacketRec{Time: minuteInMilliseconds, Sender: 1, Move: i + 1, Data: packetBytes(t, &sample)}
+		}
+	}()
+	reports, err = ResourceWorker(stream, map[byte]string{1: "alpha"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if events := reports["alpha"].Events; len(events) != 0 {
+		t.Errorf("wanted no events without energy storage, got %+v", events)
+	}
+}
+func TestParseLobbyMessages(t *testing.T) {
+	textEncoder := charmap.Windows1252.NewEncoder()
+	raw, err := textEncoder.String("<Kazik> gl hf\r*** Didou has joined the game\r<Fnörd> hi\rMap changed to [V] Dark Comet\r*** Didou has left the game\r")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines, err := parseLobbyChat(extraSector{sectorType: lobbyChatType, data: []byte(raw)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gp := Game{LobbyChat: lines, LobbyMessages: parseLobbyMessages(lines)}
+	want := []LobbyMessage{
+		{Kind: PlayerLine, Speaker: "Kazik", Text: "gl hf"},
+		{Kind: JoinLine, Subject: "Didou", Text: "*** Didou has joined the game"},
+		{Kind: PlayerLine, Speaker: "Fnörd", Text: "hi"},
+		{Kind: MapChangeLine, Text: "Map changed to [V] Dark Comet"},
+		{Kind: LeaveLine, Subject: "Didou", Text: "*** Didou has left the game"},
+	}
+	if len(gp.LobbyMessages) != len(want) {
+		t.Fatalf("wanted %d messages, got %d", len(want), len(gp.LobbyMessages))
+	}
+	for i := range want {
+		if gp.LobbyMessages[i] != want[i] {
+			t.Errorf("wanted %+v, got %+v", want[i], gp.LobbyMessages[i])
+		}
+	}
+	if n := len(gp.LobbyMessagesBy("didou")); n != 2 {
+		t.Errorf("wanted 2 messages about didou, got %d", n)
+	}
+
+	for _, c := range []struct {
+		line    string
+		kind    LobbyMessageKind
+		subject string
+	}{
+		{"*** Bob has been kicked", KickLine, "Bob"},
+		{"*** Bob was kicked by Admin", KickLine, "Bob"},
+		{"*** Admin kicked Bob", KickLine, "Bob"},
+		{"*** [CLAN]Didou disconnected", LeaveLine, "[CLAN]Didou"},
+		{"*** Kazik has joined", JoinLine, "Kazik"},
+		// lines that only mention joining or leaving aren't about a player
+		{"I left early", SystemLine, ""},
+		{"*** Kazik joined Fez's team", SystemLine, ""},
+		{"*** Didou has left the game, waiting for players", SystemLine, ""},
+		{"Everyone kicked the ball around", SystemLine, ""},
+		{"*** the map changed", SystemLine, ""},
+	} {
+		lm := parseLobbyMessage(c.line)
+		if lm.Kind != c.kind || lm.Subject != c.subject || lm.Text != c.line {
+			t.Errorf("%q: wanted a %v line about %q, got %+v", c.line, c.kind, c.subject, lm)
+		}
+	}
+}
+func TestChatWorker(t *testing.T) {
+	gp := Game{
+		MaxUnits: 500,
+		Players: []DemoPlayer{
+			{Name: "alpha", Number: 1},
+			{Name: "bravo", Number: 2},
+			{Name: "charlie", Number: 3, Side: 2},
+		},
+	}
+	chat := func(sender byte, move int, line string) PacketRec {
+		msg := packet0x05{Marker: 0x05}
+		raw, err := charmap.Windows1252.NewEncoder().String(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		copy(msg.Message[:], raw)
+		return PacketRec{Time: 1000, Sender: sender, Move: move, Data: packetBytes(t, &msg)}
+	}
+	packets := []PacketRec{
+		chat(1, 1, "<alpha> gl hf"),
+		chat(2, 2, "<bravo> (allies) rush mid"),
+		chat(3, 3, "<charlie> @alpha to all: behind you"),
+		{Time: 1000, Sender: 2, Move: 4, Data: packetBytes(t, &packet0x0c{Marker: 0x0c, Destroyed: 501})},
+		chat(2, 5, "<bravo> gg noob"),
+	}
+	stream := make(chan PacketRec)
+	go func() {
+		defer close(stream)
+		for _, pr := range packets {
+			stream <- pr
+		}
+	}()
+	messages, err := ChatWorker(stream, gp, ChatOptions{FlagWatchers: true, FlagDead: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PlayerMessage{
+		{Message: "<alpha> gl hf", Sent: 1000, Sender: 1, SenderName: "alpha", Text: "gl hf"},
+		// nothing in the text says who a message was sent to
+		{Message: "<bravo> (allies) rush mid", Sent: 2000, Sender: 2, SenderName: "bravo", Text: "(allies) rush mid"},
+		{Message: "<charlie> @alpha to all: behind you", Sent: 3000, Sender: 3, SenderName: "charlie", Text: "@alpha to all: behind you", Watcher: true},
+		{Message: "<bravo> gg noob", Sent: 5000, Sender: 2, SenderName: "bravo", Text: "gg noob", Dead: true},
+	}
+	if len(messages) != len(want) {
+		t.Fatalf("wanted %d messages, got %d", len(want), len(messages))
+	}
+	for i := range want {
+		if messages[i] != want[i] {
+			t.Errorf("wanted %+v, got %+v", want[i], messages[i])
+		}
+	}
+}
+func TestRecorderMetadata(t *testing.T) {
+	rv := parseRecorderVersion("TA Demo Recorder v0.99b\x00")
+	want := RecorderVersion{Name: "TA Demo Recorder", Major: 0, Minor: 99, Variant: "b"}
+	if rv != want {
+		t.Errorf("wanted %+v, got %+v", want, rv)
+	}
+	recorded, err := parseRecDate("2019-06-08 21:33:07\x00")
+	if err != nil {
+		t.Error(err)
+	}
+	if !recorded.Equal(time.Date(2019, 6, 8, 21, 33, 7, 0, time.UTC)) {
+		t.Errorf("got %v for recording date", recorded)
+	}
+	for _, date := range []string{"sometime", "05/06/2019 21:33:07", "13/05/2019"} {
+		if _, err := parseRecDate(date); err == nil {
+			t.Errorf("expected an error for %q", date)
+		}
+	}
+	var buf bytes.Buffer
+	sectors := []extraSector{
+		{sectorType: commentsType, data: []byte("good game\x00")},
+		{sectorType: versionNumberType, data: []byte("TA Demo Recorder v0.99b")},
+		{sectorType: 42, data: []byte{1, 2, 3}},
+		{sectorType: commentsType, data: []byte("rematch\x00")},
+		{sectorType: dateStringType, data: []byte("05/06/2019\x00")},
+	}
+	buf.Write([]byte{3, 0, byte(len(sectors))})
+	for _, sec := range sectors {
+		binary.Write(&buf, binary.LittleEndian, uint16(len(sec.data)+6))
+		binary.Write(&buf, binary.LittleEndian, sec.sectorType)
+		buf.Write(sec.data)
+	}
+	gp := &Game{}
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+	if err := loadExtraSectors(&buf, gp); err != nil {
+		t.Fatal(err)
+	}
+	if gp.Comments != "good game\nrematch" {
+		t.Errorf("wanted the comments of both sectors, got %q", gp.Comments)
+	}
+	if !gp.Recorded.IsZero() || hook.LastEntry() == nil || hook.LastEntry().Level != log.WarnLevel {
+		t.Errorf("wanted a warning and no recording date, got %v", gp.Recorded)
+	}
+	if gp.Recorder != want {
+		t.Errorf("wanted %+v, got %+v", want, gp.Recorder)
+	}
+	if len(gp.UnknownSectors) != 1 || gp.UnknownSectors[0].Type != 42 || len(gp.UnknownSectors[0].Data) != 3 {
+		t.Errorf("unknown sector was not kept: %+v", gp.UnknownSectors)
+	}
+}
+func TestDrawGifOverMap(t *testing.T) {
+	bgf, err := os.Open(darkcometpng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapPic, _, err := image.Decode(bgf)
+	bgf.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapRect := image.Rect(0, 0, 6144, 7680)
+	frames := []PlaybackFrame{
+		{Number: 0, Time: 0, Units: map[uint16]*TAUnit{
+			1: {Owner: 1, Finished: true, Class: commanderClass, Pos: point{X: 1000, Y: 1000}},
+		}},
+		{Number: 1, Time: 10000, Units: map[uint16]*TAUnit{
+			1: {Owner: 1, Finished: true, Class: commanderClass, Pos: point{X: 1200, Y: 1100}},
+		}},
+	}
+	var buf bytes.Buffer
+	err = DrawGifWithOptions(&buf, frames, mapPic, mapRect, RenderOptions{Darken: 0.5, Desaturate: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Image) != len(frames) {
+		t.Fatalf("wanted %d frames, got %d", len(frames), len(out.Image))
+	}
+	if out.Image[0].Bounds() != mapPic.Bounds() {
+		t.Errorf("wanted frames the size of the map %v, got %v", mapPic.Bounds(), out.Image[0].Bounds())
+	}
+	// the corner of the map has terrain instead of transparency
+	if _, _, _, a := out.Image[0].At(0, 0).RGBA(); a == 0 {
+		t.Error("expected the map to be drawn under the units")
+	}
+	buf.Reset()
+	if err := DrawGif(&buf, frames, mapPic.Bounds(), mapRect); err != nil {
+		t.Fatal(err)
+	}
+	out, err = gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := out.Image[0].At(0, 0).RGBA(); a != 0 {
+		t.Error("expected a transparent background when only the map's size is given")
+	}
+}
+func TestRenderOptions(t *testing.T) {
+	gp := Game{Players: []DemoPlayer{{Number: 1, Color: 3}, {Number: 2, Color: 5}}}
+	frames := make([]PlaybackFrame, 6)
+	for i := range frames {
+		frames[i] = PlaybackFrame{Number: i, Time: (i + 1) * 5000, Units: map[uint16]*TAUnit{
+			1:   {Owner: 1, Finished: true, Class: commanderClass, Pos: point{X: 100 * i, Y: 100}},
+			501: {Owner: 2, Finished: true, Class: buildingClass, Pos: point{X: 3000, Y: 3000}},
+		}}
+	}
+	opts := RenderOptions{
+		Speed:         100,
+		Width:         200,
+		MarkerScale:   2,
+		Start:         10000,
+		End:           25000,
+		HideBuildings: true,
+		PlayerColors:  map[int]color.Color{3: color.RGBA{0xff, 0, 0, 0xff}},
+	}
+	var buf bytes.Buffer
+	err := gp.DrawGifWithOptions(&buf, frames, image.Rect(0, 0, 640, 800), image.Rect(0, 0, 6144, 7680), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Image) != 4 {
+		t.Errorf("wanted 4 frames in the time window, got %d", len(out.Image))
+	}
+	if out.Image[0].Bounds().Dx() != 200 || out.Image[0].Bounds().Dy() != 250 {
+		t.Errorf("wanted 200x250 frames, got %v", out.Image[0].Bounds())
+	}
+	for _, d := range out.Delay {
+		if d != 5 {
+			t.Errorf("wanted a delay of 5 for 5 seconds at 100x, got %d", d)
+		}
+	}
+	if frames[0].Units[1].Owner != 1 {
+		t.Error("drawing with a ColorMap changed the owners of the frames")
+	}
+}
+func TestRenderSnapshot(t *testing.T) {
+	frames := []PlaybackFrame{
+		{Number: 0, Time: 10000, Units: map[uint16]*TAUnit{
+			1: {Owner: 1, Finished: true, Class: commanderClass, ID: 1, Pos: point{X: 1000, Y: 1000, ID: 1, Time: 10000}},
+		}},
+		{Number: 1, Time: 20000, Units: map[uint16]*TAUnit{
+			1: {Owner: 1, Finished: true, Class: commanderClass, ID: 1, Pos: point{X: 2000, Y: 3000, ID: 2, Time: 20000}},
+		}},
+	}
+	mid := FrameAt(frames, 15000)
+	if mid.Units[1].Pos.X != 1500 || mid.Units[1].Pos.Y != 2000 {
+		t.Errorf("wanted the commander half way at (1500, 2000), got %+v", mid.Units[1].Pos)
+	}
+	if frames[0].Units[1].Pos.X != 1000 {
+		t.Error("FrameAt changed the frames it was given")
+	}
+	// the next position was reached before the next frame
+	frames[1].Units[1].Pos = point{X: 1700, Y: 1000, ID: 3, Time: 17000}
+	if late := FrameAt(frames, 19000); late.Units[1].Pos.X != 1700 || late.Units[1].Pos.Y != 1000 {
+		t.Errorf("wanted the commander to stop at (1700, 1000), got %+v", late.Units[1].Pos)
+	}
+
+	// a recording where a unit is built and destroyed between the frames of FramesWorker
+	position := func(x, y int) []byte {
+		// the smartpaked 0x2c of the commander's position, which unsmartpak numbers
+		b := make([]byte, 22)
+		b[0], b[1] = 0xfd, 26
+		binary.LittleEndian.PutUint16(b[5:], 7+0xc00)
+		binary.LittleEndian.PutUint16(b[7:], uint16(x/16))
+		binary.LittleEndian.PutUint16(b[9:], uint16(y/16))
+		return b
+	}
+	d := &tedtest.Demo{Map: "Dark Comet", MaxUnits: 500, Players: []tedtest.Player{{Number: 1, Name: "Kazik"}}}
+	for _, m := range []struct {
+		time   uint16
+		packet []byte
+	}{
+		{100, packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 1, XPos: 1000, YPos: 1000})},
+		{9900, position(2048, 3072)},
+		{2000, packetBytes(t, &packet0x09{Marker: 0x09, NetID: 8, UnitID: 2, XPos: 4000, YPos: 4000})},
+		{1000, packetBytes(t, &packet0x12{Marker: 0x12, BuiltID: 2, BuiltByID: 1})},
+		{5000, packetBytes(t, &packet0x0c{Marker: 0x0c, Destroyed: 2})},
+		{2000, position(3072, 3072)},
+		// the last move isn't streamed
+		{1000, []byte{0xff}},
+	} {
+		d.Moves = append(d.Moves, tedtest.Move{Time: m.time, Sender: 1, Data: tedtest.Packets(m.packet)})
+	}
+	snapshot := func(tval int) PlaybackFrame {
+		gp, prs, err := Analyze(context.Background(), bytes.NewReader(d.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var snapshot PlaybackFrame
+		err = RunWorkers(prs, func(stream chan PacketRec) (err error) {
+			snapshot, err = SnapshotWorker(stream, gp.MaxUnits, tval, RenderOptions{})
+			return
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return snapshot
+	}
+	if s := snapshot(15000); len(s.Units) != 2 || s.Units[2] == nil || s.Units[1].Pos.X != 2560 || s.Units[1].Pos.Y != 3072 {
+		t.Errorf("wanted the new unit and the commander half way at (2560, 3072), got %+v", s.Units)
+	}
+	if s := snapshot(19000); len(s.Units) != 1 {
+		t.Errorf("wanted the new unit gone, got %+v", s.Units)
+	}
+
+	var buf bytes.Buffer
+	err := RenderSnapshot(&buf, bytes.NewReader(d.Bytes()), 15*time.Second, image.Rect(0, 0, 614, 768), RenderOptions{MapSize: image.Pt(6144, 7680)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, format, err := image.Decode(&buf)
+	if err != nil || format != "png" {
+		t.Fatalf("expected a png, got %v: %v", format, err)
+	}
+	if _, _, _, a := img.At(256, 307).RGBA(); a == 0 {
+		t.Error("expected the commander to be drawn at (256, 307)")
+	}
+	if _, _, _, a := img.At(400, 400).RGBA(); a == 0 {
+		t.Error("expected the new unit to be drawn at (400, 400)")
+	}
+	if err := RenderSnapshot(&buf, bytes.NewReader(d.Bytes()), 0, image.Rect(0, 0, 614, 768), RenderOptions{}); err == nil {
+		t.Error("wanted an error without the map size")
+	}
+}
+func TestHeatmapWorker(t *testing.T) {
+	mapRect := image.Rect(0, 0, 6144, 7680)
+	packets := []PacketRec{
+		{Time: 100, Sender: 1, Move: 1, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 1, XPos: 100, YPos: 100})},
+		{Time: 100, Sender: 2, Move: 2, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 501, XPos: 5000, YPos: 7000})},
+		{Time: 5000, Sender: 1, Move: 3, Data: packetBytes(t, &packet0x0b{Marker: 0x0b, DamagedID: 501, DamagerID: 1, Damage: 300, Unknown2: 1})},
+		{Time: 5000, Sender: 2, Move: 4, Data: packetBytes(t, &packet0x0c{Marker: 0x0c, Destroyed: 501, Destroyer: 1})},
+	}
+	stream := make(chan PacketRec)
+	go func() {
+		defer close(stream)
+		for _, pr := range packets {
+			stream <- pr
+		}
+	}()
+	layers, err := HeatmapWorker(stream, 500, mapRect, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := layers.Presence[1].At(100, 100); v != 10200 {
+		t.Errorf("wanted 10200 ms of presence for player 1, got %v", v)
+	}
+	if v := layers.Damage[2].At(5000, 7000); v != 300 {
+		t.Errorf("wanted 300 damage for player 2, got %v", v)
+	}
+	if v := layers.Deaths[2].At(5000, 7000); v != 1 {
+		t.Errorf("wanted 1 death for player 2, got %v", v)
+	}
+	var buf bytes.Buffer
+	err = RenderHeatmap(&buf, layers.Presence[1], image.Rect(0, 0, 614, 768), mapRect, RenderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := img.At(12, 12).RGBA(); a == 0 {
+		t.Error("expected the hot cell to be drawn")
+	}
+}
+
+func TestFrameEffects(t *testing.T) {
+	packets := []PacketRec{
+		{Time: 100, Sender: 1, Move: 1, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 1, XPos: 100, YPos: 100})},
+		{Time: 100, Sender: 2, Move: 2, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 501, XPos: 900, YPos: 900})},
+		{Time: 500, Sender: 1, Move: 3, Data: packetBytes(t, &packet0x0d{Marker: 0x0d, OriginX: 100, OriginY: 100, DestX: 900, DestY: 900, ShotID: 501, ShooterID: 1})},
+		{Time: 500, Sender: 2, Move: 4, Data: packetBytes(t, &packet0x10{Marker: 0x10, UnitID: 501})},
+		{Time: 1000, Sender: 1, Move: 5, Data: packetBytes(t, &packet0x2c{Marker: 0x2c})},
+		{Time: 3000, Sender: 1, Move: 6, Data: packetBytes(t, &packet0x2c{Marker: 0x2c})},
+	}
+	stream := make(chan PacketRec)
+	go func() {
+		defer close(stream)
+		for _, pr := range packets {
+			stream <- pr
+		}
+	}()
+	frames, err := FramesWorkerWithOptions(stream, 500, RenderOptions{FrameInterval: 1000, EffectWindow: 2000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 3 {
+		t.Fatalf("wanted 3 frames, got %d", len(frames))
+	}
+	effects := frames[0].Effects
+	if len(effects) != 2 || effects[0].Kind != ShotEffect || effects[1].Kind != ExplosionEffect {
+		t.Fatalf("wanted a shot and an explosion in the first frame, got %+v", effects)
+	}
+	if effects[0].Owner != 1 || effects[0].ToX != 900 || effects[1].Owner != 2 || effects[1].X != 900 {
+		t.Errorf("unexpected effects %+v", effects)
+	}
+	if len(frames[1].Effects) != 2 {
+		t.Errorf("wanted effects to last into the second frame, got %+v", frames[1].Effects)
+	}
+	if len(frames[2].Effects) != 0 {
+		t.Errorf("wanted effects to fade by the third frame, got %+v", frames[2].Effects)
+	}
+
+	opts := RenderOptions{EffectWindow: 2000}
+	drawn := gg.NewContext(100, 100)
+	opts.drawEffects(drawn, effects, effects[0].Time, 0.1)
+	if _, _, _, a := drawn.Image().At(50, 50).RGBA(); a == 0 {
+		t.Error("expected the shot line to be drawn")
+	}
+	faded := gg.NewContext(100, 100)
+	opts.drawEffects(faded, effects, effects[0].Time+2000, 0.1)
+	if _, _, _, a := faded.Image().At(50, 50).RGBA(); a != 0 {
+		t.Error("expected the shot to have faded")
+	}
+}
+
+func TestCameraWorker(t *testing.T) {
+	packets := []PacketRec{
+		{Time: 100, Sender: 1, Move: 1, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 1, XPos: 500, YPos: 500})},
+		{Time: 100, Sender: 2, Move: 2, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 501, XPos: 5000, YPos: 5000})},
+		{Time: 100, Sender: 1, Move: 3, Data: packetBytes(t, &packet0xfc{Marker: 0xfc, XPos: 200, YPos: 200})},
+		{Time: 3000, Sender: 1, Move: 4, Data: packetBytes(t, &packet0xfc{Marker: 0xfc, XPos: 4800, YPos: 4800})},
+		{Time: 1000, Sender: 1, Move: 5, Data: packetBytes(t, &packet0xfc{Marker: 0xfc, XPos: 2500, YPos: 2500})},
+		{Time: 1000, Sender: 1, Move: 6, Data: packetBytes(t, &packet0x2c{Marker: 0x2c})},
+	}
+	feed := func() chan PacketRec {
+		stream := make(chan PacketRec)
+		go func() {
+			defer close(stream)
+			for _, pr := range packets {
+				stream <- pr
+			}
+		}()
+		return stream
+	}
+	report, err := CameraWorker(feed(), 500)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if track := report.Tracks[1]; len(track) != 3 || track[1].X != 4800 || track[1].Time != 3300 {
+		t.Fatalf("unexpected camera track %+v", track)
+	}
+	want := Attention{Base: 3000, Front: 1000, Other: 1000}
+	if got := report.Attention[1]; got == nil || *got != want {
+		t.Fatalf("wanted attention %+v, got %+v", want, got)
+	}
+	if base, front, _ := report.Attention[1].Shares(); base != 0.6 || front != 0.2 {
+		t.Errorf("wanted shares of 0.6 and 0.2, got %v and %v", base, front)
+	}
+
+	frames, err := FramesWorkerWithOptions(feed(), 500, RenderOptions{FrameInterval: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := frames[len(frames)-1]
+	if len(last.Cameras) != 1 || last.Cameras[0].X != 2500 {
+		t.Fatalf("wanted the latest camera in the last frame, got %+v", last.Cameras)
+	}
+	snapshot, err := SnapshotWorker(feed(), 500, last.Time, RenderOptions{ShowCameras: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot.Cameras) != 1 || snapshot.Cameras[0].X != 2500 {
+		t.Fatalf("wanted the latest camera in the snapshot, got %+v", snapshot.Cameras)
+	}
+	var buf bytes.Buffer
+	err = drawSnapshot(&buf, snapshot, image.Rect(0, 0, 614, 614), image.Rect(0, 0, 6144, 6144), RenderOptions{ShowCameras: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := img.At(250, 270).RGBA(); a == 0 {
+		t.Error("expected the camera outline to be drawn")
+	}
+}
+
+func TestPerspective(t *testing.T) {
+	units := map[uint16]*TAUnit{
+		1:   {Owner: 1, NetID: 10, Finished: true, Pos: point{X: 1000, Y: 1000}},
+		2:   {Owner: 3, NetID: 10, Finished: true, Pos: point{X: 4000, Y: 4000}},
+		501: {Owner: 2, NetID: 20, Finished: true, Pos: point{X: 1300, Y: 1000}},
+		502: {Owner: 2, NetID: 20, Finished: true, Pos: point{X: 2000, Y: 1000}},
+		503: {Owner: 2, NetID: 20, Finished: true, Pos: point{X: 4100, Y: 4000}},
+	}
+	effects := []Effect{
+		{Kind: ShotEffect, Owner: 2, X: 3000, Y: 3000, ToX: 1000, ToY: 1000},
+		{Kind: ExplosionEffect, Owner: 2, X: 6000, Y: 6000, ToX: 6000, ToY: 6000},
+	}
+	opts := RenderOptions{}
+	if seen, _ := opts.perspective(units, effects); len(seen) != len(units) {
+		t.Fatalf("wanted every unit without a perspective, got %d", len(seen))
+	}
+	opts = RenderOptions{Perspective: 1, Sight: UnitTable{10: {Sight: 500}}}
+	seen, seenEffects := opts.perspective(units, effects)
+	if seen[501] == nil || seen[502] != nil || seen[503] != nil || seen[2] != nil {
+		t.Errorf("unexpected visible units %v", seen)
+	}
+	if len(seenEffects) != 1 || seenEffects[0].Kind != ShotEffect {
+		t.Errorf("wanted only the shot landing in view, got %+v", seenEffects)
+	}
+	opts.PerspectiveAllies = []int{3}
+	if seen, _ := opts.perspective(units, effects); seen[503] == nil {
+		t.Error("expected allies to share vision")
+	}
+}
+
+func TestFrameSinks(t *testing.T) {
+	frames := make([]PlaybackFrame, 3)
+	for i := range frames {
+		frames[i] = PlaybackFrame{Number: i, Time: (i + 1) * 10000, Units: map[uint16]*TAUnit{
+			1: {Owner: 0, Finished: true, Class: mobileClass, Pos: point{X: 1000 + 1000*i, Y: 1000}},
+		}}
+	}
+	// the last frame is the same as the one before it
+	frames[2].Units[1].Pos.X = 2000
+	mapPic := image.Rect(0, 0, 100, 100)
+	mapRect := image.Rect(0, 0, 10000, 10000)
+
+	var apngBuf bytes.Buffer
+	if err := RenderFrames(NewAPNGSink(&apngBuf), frames, mapPic, mapRect, RenderOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	data := apngBuf.Bytes()
+	if n := bytes.Count(data, []byte("fcTL")); n != 2 {
+		t.Errorf("wanted 2 apng frames after merging the still one, got %d", n)
+	}
+	if actl := bytes.Index(data, []byte("acTL")); actl == -1 || binary.BigEndian.Uint32(data[actl+4:]) != 2 {
+		t.Error("wanted an acTL chunk with 2 frames")
+	}
+	first, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, a := first.At(10, 10).RGBA(); a == 0 {
+		t.Error("expected the unit in the first apng frame")
+	}
+
+	// diff frames need an opaque map to draw over
+	opaqueMap := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	draw.Draw(opaqueMap, opaqueMap.Bounds(), image.NewUniform(color.RGBA{0, 80, 0, 255}), image.Point{}, draw.Src)
+	var gifBuf bytes.Buffer
+	if err := RenderFrames(NewGifSink(&gifBuf, true), frames, opaqueMap, mapRect, RenderOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(&gifBuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 2 || g.Delay[1] != 20 {
+		t.Fatalf("wanted 2 gif frames with the last shown twice as long, got %d %v", len(g.Image), g.Delay)
+	}
+	if !g.Image[1].Bounds().In(image.Rect(0, 0, 100, 100)) || g.Image[1].Bounds().Eq(image.Rect(0, 0, 100, 100)) {
+		t.Errorf("wanted the diff frame to be cropped, got %v", g.Image[1].Bounds())
+	}
+	// on a transparent map the unit's first position is cleared when it moves
+	for _, diff := range []bool{false, true} {
+		gifBuf.Reset()
+		if err := RenderFrames(NewGifSink(&gifBuf, diff), frames, mapPic, mapRect, RenderOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		g, err := gif.DecodeAll(&gifBuf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, _, a := playGif(g).At(10, 10).RGBA(); a != 0 {
+			t.Errorf("with diff %v the unit left a trail with alpha %d", diff, a)
+		}
+	}
+
+	dir := t.TempDir()
+	if err := RenderFrames(NewPNGSequenceSink(dir, "frame"), frames, mapPic, mapRect, RenderOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := range frames {
+		if _, err := os.Stat(path.Join(dir, fmt.Sprintf("frame%05d.png", i))); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// playGif draws the frames of a GIF onto a canvas the way a viewer does and returns
+// the canvas after the last one
+func playGif(g *gif.GIF) *image.RGBA {
+	canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+	for i, frame := range g.Image {
+		previous := image.NewRGBA(canvas.Bounds())
+		copy(previous.Pix, canvas.Pix)
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+		if i == len(g.Image)-1 {
+			break
+		}
+		switch g.Disposal[i] {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return canvas
+}
+
+func TestUnitTracker(t *testing.T) {
+	ut := newUnitTracker(500)
+	packets := []PacketRec{
+		{Sender: 1, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 1, XPos: 100, YPos: 100})},
+		{Sender: 2, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 501, XPos: 5000, YPos: 5000})},
+		{Sender: 1, Data: packetBytes(t, &packet0x0d{Marker: 0x0d, OriginX: 300, OriginY: 300, DestX: 5000, DestY: 5000, ShotID: 501, ShooterID: 1})},
+		{Sender: 1, Data: packetBytes(t, &packet0x0d{Marker: 0x0d, OriginX: 400, OriginY: 300, DestX: 5000, DestY: 5000, ShotID: 501, ShooterID: 1})},
+	}
+	for i, pr := range packets {
+		if err := ut.update(pr, i*1000); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if u := ut.units[1]; u.ID != 1 || u.Pos.ID != 3 || u.Pos.X != 400 {
+		t.Errorf("wanted the first unit at its third position, got %+v", u)
+	}
+	if u := ut.units[501]; u.ID != 2 || u.Pos.ID != 3 {
+		t.Errorf("wanted the second unit at its third position, got %+v", u)
+	}
+}
+
+func TestDeltaFrames(t *testing.T) {
+	packets := []PacketRec{
+		{Time: 100, Sender: 1, Move: 1, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 1, XPos: 100, YPos: 100})},
+		{Time: 100, Sender: 2, Move: 2, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 501, XPos: 5000, YPos: 5000})},
+		{Time: 1000, Sender: 1, Move: 3, Data: packetBytes(t, &packet0x0d{Marker: 0x0d, OriginX: 300, OriginY: 300, DestX: 5000, DestY: 5000, ShotID: 501, ShooterID: 1})},
+		{Time: 1000, Sender: 2, Move: 4, Data: packetBytes(t, &packet0x0c{Marker: 0x0c, Destroyed: 501, Destroyer: 1})},
+		{Time: 1000, Sender: 1, Move: 5, Data: packetBytes(t, &packet0x0d{Marker: 0x0d, OriginX: 400, OriginY: 300, DestX: 5000, DestY: 5000, ShotID: 501, ShooterID: 1})},
+		{Time: 1000, Sender: 1, Move: 6, Data: packetBytes(t, &packet0x2c{Marker: 0x2c})},
+	}
+	feed := func() chan PacketRec {
+		stream := make(chan PacketRec)
+		go func() {
+			defer close(stream)
+			for _, pr := range packets {
+				stream <- pr
+			}
+		}()
+		return stream
+	}
+	opts := RenderOptions{FrameInterval: 1000, KeyframeInterval: 3}
+	frames, err := FramesWorkerWithOptions(feed(), 500, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	df, err := DeltaFramesWorker(feed(), 500, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if df.Len() != len(frames) || df.Len() != 4 {
+		t.Fatalf("wanted 4 frames, got %d and %d", df.Len(), len(frames))
+	}
+	if !df.Deltas[0].Keyframe || df.Deltas[1].Keyframe || !df.Deltas[3].Keyframe {
+		t.Error("wanted keyframes every 3 frames")
+	}
+	if d := df.Deltas[1]; len(d.Changed) != 0 || len(d.Removed) != 1 || d.Removed[0] != 501 {
+		t.Errorf("wanted the dead unit removed in the second delta, got %+v", d)
+	}
+	if d := df.Deltas[2]; len(d.Changed) != 1 || len(d.Removed) != 0 {
+		t.Errorf("wanted only the moved unit in the third delta, got %+v", d)
+	}
+	rebuilt := df.Frames()
+	for i := range frames {
+		if len(rebuilt[i].Units) != len(frames[i].Units) {
+			t.Fatalf("frame %d has %d units, wanted %d", i, len(rebuilt[i].Units), len(frames[i].Units))
+		}
+		for k, v := range frames[i].Units {
+			// ids are made fresh on every run
+			got := rebuilt[i].Units[k]
+			if got == nil || got.Pos.X != v.Pos.X || got.Pos.Y != v.Pos.Y || got.Owner != v.Owner || got.Class != v.Class {
+				t.Errorf("frame %d unit %d is %+v, wanted %+v", i, k, got, v)
+			}
+		}
+		if single := df.Frame(i); len(single.Units) != len(frames[i].Units) || single.Time != frames[i].Time {
+			t.Errorf("frame %d rebuilt alone doesn't match", i)
+		}
+	}
+
+	var full, streamed bytes.Buffer
+	mapPic, mapRect := image.Rect(0, 0, 100, 100), image.Rect(0, 0, 6144, 6144)
+	if err := RenderFrames(NewGifSink(&full, true), frames, mapPic, mapRect, opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := RenderDeltaFrames(NewGifSink(&streamed, true), df, mapPic, mapRect, opts); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(full.Bytes(), streamed.Bytes()) {
+		t.Error("wanted delta frames to draw the same animation as full frames")
+	}
+}
+
+func TestChart(t *testing.T) {
+	gp := &Game{Players: []DemoPlayer{
+		{Name: "Alpha", Number: 1, Color: 0},
+		{Name: "Bravo", Number: 2, Color: 1},
+		{Name: "Watcher", Number: 3, Color: 2, Side: 2},
+	}}
+	series := map[string][]SPLite{
+		"Alpha": {{Milliseconds: 0}, {Metal: 10, Kills: 2, Milliseconds: minuteInMilliseconds}, {Metal: 12, Kills: 3, Milliseconds: 2 * minuteInMilliseconds}},
+		"Bravo": {{Milliseconds: 0}, {Metal: 15, Kills: 20, Milliseconds: minuteInMilliseconds + 5000}, {Metal: 20, Kills: 21, Milliseconds: 2 * minuteInMilliseconds}},
+	}
+	// Bravo lasted to the end of the game
+	chart := gp.NewChart(series, MetalIncome, [10]int{3 * minuteInMilliseconds, 4*minuteInMilliseconds + 1})
+	if len(chart.Markers) != 1 || chart.Markers[0].Label != "Alpha died" || chart.Markers[0].Milliseconds != 3*minuteInMilliseconds {
+		t.Fatalf("wanted a death marker, got %+v", chart.Markers)
+	}
+	battles := BattleMarkers(series, 10)
+	if len(battles) != 1 || battles[0].Milliseconds != minuteInMilliseconds || battles[0].Label != "battle: 22 kills" {
+		t.Fatalf("wanted a battle in the second minute, got %+v", battles)
+	}
+	chart.Markers = append(chart.Markers, battles...)
+
+	var svg bytes.Buffer
+	if err := chart.WriteSVG(&svg); err != nil {
+		t.Fatal(err)
+	}
+	out := svg.String()
+	if !strings.HasPrefix(out, "<svg") || strings.Count(out, "<line") != 2 || !strings.Contains(out, ">Alpha died</text>") {
+		t.Errorf("wanted an svg with Alpha's death and the battle marked, got %v", out)
+	}
+	if !strings.Contains(out, svgColor(playerColors[1])) {
+		t.Error("expected Bravo's line in their player color")
+	}
+	var pngBuf bytes.Buffer
+	if err := chart.WritePNG(&pngBuf); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&pngBuf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 800 || img.Bounds().Dy() != 400 {
+		t.Errorf("wanted an 800x400 chart, got %v", img.Bounds())
+	}
+}
+
+func TestDumpWorker(t *testing.T) {
+	var message [64]byte
+	copy(message[:], "<Kazik> gg")
+	packets := []PacketRec{
+		{Time: 1000, Sender: 1, Move: 1, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 1})},
+		{Time: 1000, Sender: 1, Move: 2, Data: []byte{0x0c, 0x01}},
+		{Time: 1000, Sender: 1, Move: 3, Data: packetBytes(t, &packet0x05{Marker: 0x05, Message: message})},
+	}
+	stream := make(chan PacketRec)
+	go func() {
+		defer close(stream)
+		for _, pr := range packets {
+			stream <- pr
+		}
+	}()
+	var buf bytes.Buffer
+	if err := DumpWorker(stream, &buf, map[uint16]string{7: "ARMCOM"}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	// the short packet is dumped and the packets after it still are
+	for _, want := range []string{"00:01 move 1: player 1 sent 09: started building a ARMCOM", "00:02 move 2: player 1 sent 0c: could not decode", "0c 01", "00:03 move 3: player 1 sent 05: sent chat message: <Kazik> gg"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("wanted %q in the dump, got\n%v", want, out)
+		}
+	}
+}
+func TestInspector(t *testing.T) {
+	packets := []PacketRec{
+		{Time: 100, Sender: 1, Move: 1, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 235, UnitID: 3, XPos: 100, YPos: 100})},
+		{Time: 100, Sender: 2, Move: 2, Data: []byte{0x77, 0x01, 0x02}},
+		{Time: 2000, Sender: 1, Move: 3, Data: packetBytes(t, &packet0x12{Marker: 0x12, BuiltID: 3, BuiltByID: 1})},
+		{Time: 2000, Sender: 1, Move: 4, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 3})},
+	}
+	stream := make(chan PacketRec)
+	go func() {
+		defer close(stream)
+		for _, pr := range packets {
+			stream <- pr
+		}
+	}()
+	in := NewInspector(stream, map[uint16]string{235: "ARMZEUS"})
+	if in.Len() != 4 {
+		t.Fatalf("wanted 4 packets, got %d", in.Len())
+	}
+	if ip := in.At(2); !strings.Contains(ip.Message, "ARMZEUS") || ip.Fields == "" || ip.Milliseconds != 2200 {
+		t.Errorf("wanted the built unit's name and fields, got %+v", ip)
+	}
+	if ip := in.At(1); ip.Fields != "" || !strings.Contains(ip.Message, "77") {
+		t.Errorf("wanted an unknown marker to only have a hex dump, got %+v", ip)
+	}
+	if name := in.UnitName(3, 2); name != "ARMZEUS" {
+		t.Errorf("wanted unit 3 to be ARMZEUS before it was reused, got %v", name)
+	}
+	if name := in.UnitName(3, 3); name != "netid 7" {
+		t.Errorf("wanted unit 3 to be netid 7 after it was reused, got %v", name)
+	}
+	in.Filter = InspectFilter{Markers: []byte{0x09}}
+	if !in.Next(1) || in.Pos != 3 || in.Next(1) {
+		t.Errorf("wanted to stop only at 0x09 packets, at %d", in.Pos)
+	}
+	in.Filter = InspectFilter{Senders: []byte{2}}
+	if !in.SeekTime(0) || in.Pos != 1 {
+		t.Errorf("wanted the first packet from player 2, at %d", in.Pos)
+	}
+	in.Filter = InspectFilter{}
+	if !in.SeekMove(3) || in.Pos != 2 || !in.Prev(2) || in.Pos != 0 {
+		t.Errorf("wanted to seek to move 3 and back to the start, at %d", in.Pos)
+	}
+}
+
+// checkGolden compares got to a golden file, rewriting it with -update
+func checkGolden(t *testing.T, golden string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(path.Dir(golden), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v, run go test -update to write it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%v doesn't match, got:\n%s", golden, got)
+	}
+}
+
+func TestGameReportSchema(t *testing.T) {
+	schema, err := GameReportSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "gamereport.schema.json", schema)
+}
+
+func TestGameReport(t *testing.T) {
+	gp := &Game{
+		MapName:       "Dark Comet",
+		Version:       "TA Demo Recorder 0.99b",
+		Recorded:      time.Date(2021, 7, 20, 18, 30, 0, 0, time.UTC),
+		Milliseconds:  600000,
+		TotalMoves:    4000,
+		MaxUnits:      500,
+		Unitsum:       "0x1234",
+		LobbyMessages: []LobbyMessage{{Kind: JoinLine, Subject: "Fez", Text: "Fez has joined"}},
+		Players: []DemoPlayer{
+			{Number: 1, Name: "Kazik", Side: 0, Color: 0, TDPID: 11},
+			{Number: 2, Name: "Fez", Side: 1, Color: 1, TDPID: 12},
+			{Number: 3, Name: "Watcher", Side: 2, Color: 2, TDPID: 13},
+		},
+	}
+	parts := ReportParts{
+		FinalScores: []FinalScore{{Player: "Kazik", Status: 1, Kills: 3}, {Player: "Fez", Status: 2, Losses: 3}},
+		FoulPlay:    []int{1},
+		ScoreSeries: map[string][]SPLite{
+			"Kazik": {{Milliseconds: 0}, {Milliseconds: 2000, Metal: math.Inf(-1), Energy: 25, Kills: 1}},
+		},
+		UnitCounts: []map[int]*UnitTypeRecord{{
+			235: {Kills: map[string]int{"7": 2, "235": 1}, Deaths: map[string]int{}, Produced: 4, FirstProduced: 90000},
+		}},
+		Chat:      []PlayerMessage{{Sent: 3000, Sender: 2, SenderName: "Fez", Scope: AllyChat, Text: "gg"}},
+		Teams:     []int{2},
+		TimeToDie: [10]int{0, 500000},
+		Deaths:    []int{1},
+		Actions:   [10]int{120, 80},
+		UnitNames: map[uint16]string{235: "ARMZEUS"},
+	}
+	report := gp.NewGameReport(parts)
+	if report.SchemaVersion != ReportSchemaVersion {
+		t.Errorf("wanted schema version %d, got %d", ReportSchemaVersion, report.SchemaVersion)
+	}
+	if len(report.Players) != 3 || !report.Players[1].FoulPlay || !report.Players[2].Allied {
+		t.Errorf("wanted Fez to be flagged and the watcher allied, got %+v", report.Players)
+	}
+	if !report.Players[1].Died || report.Players[0].Died || report.Players[0].Actions != 120 {
+		t.Errorf("wanted Fez dead and Kazik with 120 actions, got %+v", report.Players[:2])
+	}
+	if u := report.Players[0].Units; len(u) != 1 || u[0].Name != "ARMZEUS" || len(u[0].Kills) != 2 || u[0].Kills[0].NetID != 7 {
+		t.Errorf("wanted ARMZEUS kills sorted by NetID, got %+v", u)
+	}
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, path.Join("testdata", "gamereport.json"), append(out, '\n'))
+}
+
+func TestGameReportGolden(t *testing.T) {
+	const lambdaTimeoutSeconds = 120
+	unitNamesFile, err := os.Open("taesc900.gob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unitNames, err := LoadUnitNames(unitNamesFile)
+	unitNamesFile.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sample := range []string{sample1, sample3, sample8, sample11} {
+		t.Run(path.Base(sample), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), lambdaTimeoutSeconds*time.Second)
+			defer cancel()
+			tf, err := os.Open(sample)
+			if errors.Is(err, os.ErrNotExist) {
+				t.Skipf("%v isn't checked out", sample)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tf.Close()
+			report, err := AnalyzeReport(ctx, tf, unitNames)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := path.Join("testdata", strings.TrimSuffix(path.Base(sample), ".ted")+".report.json")
+			checkGolden(t, golden, append(out, '\n'))
+		})
+	}
+}
+
+// checkSchema reports the places where v, which is decoded from JSON, doesn't match
+// the schema. It knows the keywords that GameReportSchema uses.
+func checkSchema(t *testing.T, root, schema map[string]interface{}, v interface{}, at string) {
+	t.Helper()
+	if ref, ok := schema["$ref"].(string); ok {
+		def, _ := root["$defs"].(map[string]interface{})[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{})
+		if def == nil {
+			t.Fatalf("%v: no definition for %v", at, ref)
+		}
+		schema = def
+	}
+	if c, ok := schema["const"]; ok && c != v {
+		t.Errorf("%v: wanted %v, got %v", at, c, v)
+	}
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			t.Errorf("%v: wanted an object, got %T", at, v)
+			return
+		}
+		for _, name := range schema["required"].([]interface{}) {
+			if _, ok := obj[name.(string)]; !ok {
+				t.Errorf("%v: %v is required", at, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, value := range obj {
+			if sub, ok := properties[name].(map[string]interface{}); ok {
+				checkSchema(t, root, sub, value, at+"."+name)
+			} else if sub, ok := schema["additionalProperties"].(map[string]interface{}); ok {
+				checkSchema(t, root, sub, value, at+"."+name)
+			}
+		}
+	case "array":
+		list, ok := v.([]interface{})
+		if !ok {
+			t.Errorf("%v: wanted an array, got %T", at, v)
+			return
+		}
+		for i, value := range list {
+			checkSchema(t, root, schema["items"].(map[string]interface{}), value, fmt.Sprintf("%v[%d]", at, i))
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			t.Errorf("%v: wanted a string, got %T", at, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			t.Errorf("%v: wanted a boolean, got %T", at, v)
+		}
+	case "integer":
+		if n, ok := v.(float64); !ok || n != math.Trunc(n) {
+			t.Errorf("%v: wanted an integer, got %v", at, v)
+		}
+	case "number":
+		if _, ok := v.(float64); !ok {
+			t.Errorf("%v: wanted a number, got %T", at, v)
+		}
+	}
+}
+
+func TestGameReportMatchesSchema(t *testing.T) {
+	data, err := os.ReadFile("gamereport.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	msg := packet0x05{Marker: 0x05}
+	copy(msg.Message[:], "<Kazik> gl hf")
+	d := &tedtest.Demo{
+		Map:      "Dark Comet",
+		MaxUnits: 500,
+		Players: []tedtest.Player{
+			{Number: 1, Name: "Kazik", Color: 0, TDPID: 11},
+			{Number: 2, Name: "Fez", Side: 1, Color: 1, TDPID: 12},
+		},
+		Sectors: []tedtest.Sector{{Type: tedtest.CommentsSector, Data: []byte("ladder game")}},
+		Units:   []uint32{7, 235},
+	}
+	for _, m := range []struct {
+		sender byte
+		packet []byte
+	}{
+		{1, packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 1, XPos: 1000, YPos: 1000})},
+		{2, packetBytes(t, &packet0x09{Marker: 0x09, NetID: 7, UnitID: 501, XPos: 5000, YPos: 5000})},
+		{1, packetBytes(t, &msg)},
+		{1, packetBytes(t, &packet0x28{Marker: 0x28, Status: 1, Kills: 1, StorageM: 1000, StorageE: 1000, TotalM: 50})},
+		{2, packetBytes(t, &packet0x0c{Marker: 0x0c, Destroyed: 501, Destroyer: 1})},
+		// the last move isn't streamed
+		{1, []byte{0xff}},
+	} {
+		d.Moves = append(d.Moves, tedtest.Move{Time: 1000, Sender: m.sender, Data: tedtest.Packets(m.packet)})
+	}
+	report, err := AnalyzeReport(context.Background(), bytes.NewReader(d.Bytes()), map[uint16]string{7: "ARMCOM"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := json.Marshal(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(out, &v); err != nil {
+		t.Fatal(err)
+	}
+	checkSchema(t, schema, schema, v, "report")
+
+	// and the golden of TestGameReport, which fills in every part
+	if data, err = os.ReadFile(path.Join("testdata", "gamereport.json")); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	checkSchema(t, schema, schema, v, "golden")
+}
+
+func TestEventDecoder(t *testing.T) {
+	var message [64]byte
+	copy(message[:], "<Kazik> caf\xe9")
+	packets := []PacketRec{
+		{Time: 100, Sender: 1, Move: 1, Data: packetBytes(t, &packet0x09{Marker: 0x09, NetID: 235, UnitID: 3, XPos: 100, YPos: 200})},
+		{Time: 100, Sender: 1, Move: 1, Data: packetBytes(t, &packet0x05{Marker: 0x05, Message: message})},
+		{Time: 250, Sender: 2, Move: 2, Data: packetBytes(t, &packet0x0b{Marker: 0x0b, DamagedID: 3, DamagerID: 7, Damage: 40})},
+		{Time: 250, Sender: 2, Move: 3, Data: []byte{0x77, 0x01}},
+	}
+	var dec EventDecoder
+	var events []PacketEvent
+	for _, pr := range packets {
+		ev, err := dec.Decode(pr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+	if ev := events[0]; ev.Kind != UnitSpawnedEvent || ev.Unit != 3 || ev.NetID != 235 || ev.X != 100 || ev.Y != 200 {
+		t.Errorf("wanted a spawn, got %+v", ev)
+	}
+	if ev := events[1]; ev.Kind != ChatEvent || ev.Text != "<Kazik> café" || ev.Milliseconds != 100 {
+		t.Errorf("wanted a chat message, got %+v", ev)
+	}
+	if ev := events[2]; ev.Kind != UnitDamagedEvent || ev.Unit != 3 || ev.Other != 7 || ev.Amount != 40 || ev.Milliseconds != 350 {
+		t.Errorf("wanted damage, got %+v", ev)
+	}
+	if ev := events[3]; ev.Kind != OtherEvent || ev.Index != 3 || !bytes.Equal(ev.Data, packets[3].Data) || ev.Milliseconds != 600 {
+		t.Errorf("wanted an undecoded packet, got %+v", ev)
+	}
+	short := []byte{0x0c, 0x01}
+	if ev, err := dec.Decode(PacketRec{Move: 4, Data: short}); err == nil || ev.Kind != OtherEvent || !bytes.Equal(ev.Data, short) {
+		t.Errorf("wanted an error and the data of a short packet, got %+v %v", ev, err)
+	}
+	shot := packetBytes(t, &packet0x0d{Marker: 0x0d, ShooterID: 3, OriginX: 10, OriginY: 20, DestX: 30, DestY: 40})
+	if ev, err := dec.Decode(PacketRec{Move: 5, Data: shot}); err != nil || ev.Kind != ShotEvent || ev.Unit != 3 || ev.ToX != 30 || ev.ToY != 40 {
+		t.Errorf("wanted a shot, got %+v %v", ev, err)
+	}
+	ally := packetBytes(t, &packet0x23{Marker: 0x23, Player: 11, Allied: 12, Status: 1})
+	if ev, err := dec.Decode(PacketRec{Move: 6, Data: ally}); err != nil || ev.Kind != AllianceEvent || ev.Player != 11 || ev.Ally != 12 || !ev.Allied {
+		t.Errorf("wanted an alliance, got %+v %v", ev, err)
+	}
+}
+
+func TestActionsAndCommanderDeaths(t *testing.T) {
+	gp := Game{MaxUnits: 500, Players: []DemoPlayer{{Number: 1}, {Number: 2}}}
+	// player 2's commander is unit 501
+	killed := []byte{0x0c, 0xf5, 0x01, 0, 0, 0, 0, 0x02, 0x00, 0, 0}
+	prs := []PacketRec{
+		{Sender: 1, Data: []byte{0xfc, 0, 0, 0, 0}},
+		{Sender: 1, Data: []byte{0x11, 0x02, 0x00, 0x01}},
+		{Sender: 2, Data: []byte{0xfc, 0, 0, 0, 0}},
+		{Sender: 2, Data: []byte{0x2c, 0, 0}},
+		{Sender: 2, Data: killed},
+		{Sender: 2, Data: killed},
+	}
+	stream := func() chan PacketRec {
+		c := make(chan PacketRec, len(prs))
+		for _, pr := range prs {
+			c <- pr
+		}
+		close(c)
+		return c
+	}
+	actions, err := ActionsWorker(stream())
+	// camera moves aren't actions
+	if err != nil || actions[0] != 1 || actions[1] != 0 {
+		t.Errorf("wanted 1 and 0 actions, got %v %v", actions[:2], err)
+	}
+	deaths, err := CommanderDeathsWorker(stream(), gp)
+	if err != nil || len(deaths) != 1 || deaths[0] != 1 {
+		t.Errorf("wanted player 2's commander dead once, got %v %v", deaths, err)
+	}
+}
@@ -0,0 +1,226 @@
+// Package tadrpc serves replay analysis over gRPC. The messages and service are defined
+// in tad.proto and tad.pb.go and tad_grpc.pb.go are generated from it.
+package tadrpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative tad.proto
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/cosmouser/tad"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server implements ReplayAnalysisServer. UnitNames names the unit types in results
+// and can be nil.
+type Server struct {
+	UnimplementedReplayAnalysisServer
+	UnitNames map[uint16]string
+}
+
+// NewServer creates a Server that names units with unitNames
+func NewServer(unitNames map[uint16]string) *Server {
+	return &Server{UnitNames: unitNames}
+}
+
+// AnalyzeDemo runs every analysis over a recording
+func (s *Server) AnalyzeDemo(ctx context.Context, req *AnalyzeDemoRequest) (*AnalyzeDemoResponse, error) {
+	report, err := tad.AnalyzeReport(ctx, bytes.NewReader(req.GetDemo()), s.UnitNames)
+	if err != nil {
+		return nil, analysisError(err)
+	}
+	return responseFromReport(report), nil
+}
+
+// StreamEvents sends the decoded packets of a recording as they are read
+func (s *Server) StreamEvents(req *StreamEventsRequest, stream grpc.ServerStreamingServer[Event]) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	gp, prs, err := tad.Analyze(ctx, bytes.NewReader(req.GetDemo()))
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "not a valid recording: %v", err)
+	}
+	var dec tad.EventDecoder
+	for pr := range prs {
+		// packets that can't be decoded are sent as Other
+		ev, _ := dec.Decode(pr)
+		if err := stream.Send(eventFromPacket(ev)); err != nil {
+			return err
+		}
+	}
+	if err := gp.Err(); err != nil {
+		return analysisError(err)
+	}
+	return nil
+}
+
+// analysisError gives the status of an analysis that stopped early, which is the
+// context's when it was done and InvalidArgument for a malformed recording
+func analysisError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return status.FromContextError(err).Err()
+	}
+	return status.Errorf(codes.InvalidArgument, "not a valid recording: %v", err)
+}
+
+var sides = map[string]Side{
+	"arm":     Side_SIDE_ARM,
+	"core":    Side_SIDE_CORE,
+	"watcher": Side_SIDE_WATCHER,
+}
+
+func responseFromReport(report *tad.GameReport) *AnalyzeDemoResponse {
+	game := &Game{
+		Map:          report.Map,
+		Recorder:     report.Recorder,
+		RecordedFrom: report.RecordedFrom,
+		Comments:     report.Comments,
+		Milliseconds: int32(report.Milliseconds),
+		Moves:        int32(report.Moves),
+		MaxUnits:     int32(report.MaxUnits),
+		Unitsum:      report.Unitsum,
+		Fingerprint:  report.Fingerprint,
+		Key:          report.Key,
+	}
+	if recorded, err := time.Parse(time.RFC3339, report.Recorded); err == nil {
+		game.Recorded = timestamppb.New(recorded)
+	}
+	resp := &AnalyzeDemoResponse{Game: game}
+	for _, p := range report.Players {
+		game.Players = append(game.Players, &Player{
+			Number:  int32(p.Number),
+			Name:    p.Name,
+			Side:    sides[p.Side],
+			Color:   int32(p.Color),
+			Status:  p.Status,
+			Ip:      p.IP,
+			Cheats:  p.Cheats,
+			Tdpid:   p.TDPID,
+			Actions: int32(p.Actions),
+		})
+		series := &ScoreSeries{Player: int32(p.Number)}
+		for _, s := range p.ScoreSeries {
+			series.Samples = append(series.Samples, &ScoreSample{
+				Milliseconds:  int32(s.Milliseconds),
+				Kills:         int32(s.Kills),
+				Losses:        int32(s.Losses),
+				Metal:         s.Metal,
+				Energy:        s.Energy,
+				TotalMetal:    s.TotalMetal,
+				TotalEnergy:   s.TotalEnergy,
+				ExcessMetal:   s.ExcessMetal,
+				ExcessEnergy:  s.ExcessEnergy,
+				StoredMetal:   s.StoredMetal,
+				StoredEnergy:  s.StoredEnergy,
+				MetalStorage:  s.MetalStorage,
+				EnergyStorage: s.EnergyStorage,
+			})
+		}
+		resp.ScoreSeries = append(resp.ScoreSeries, series)
+		for _, u := range p.Units {
+			resp.Units = append(resp.Units, &UnitRecord{
+				Player:         int32(p.Number),
+				NetId:          int32(u.NetID),
+				Name:           u.Name,
+				Produced:       int32(u.Produced),
+				FirstProduced:  int32(u.FirstProduced),
+				DamageDealt:    int32(u.DamageDealt),
+				DamageReceived: int32(u.DamageReceived),
+				Kills:          unitCounts(u.Kills),
+				Deaths:         unitCounts(u.Deaths),
+			})
+		}
+		outcome := &Outcome{
+			Player:    int32(p.Number),
+			TimeToDie: int32(p.TimeToDie),
+			Allied:    p.Allied,
+			FoulPlay:  p.FoulPlay,
+			Died:      p.Died,
+		}
+		if fs := p.FinalScore; fs != nil {
+			outcome.Status = int32(fs.Status)
+			outcome.CommandersKilled = int32(fs.Won)
+			outcome.CommandersLost = int32(fs.Lost)
+			outcome.Kills = int32(fs.Kills)
+			outcome.Losses = int32(fs.Losses)
+			outcome.EnergyProduced = fs.TotalE
+			outcome.ExcessEnergy = fs.ExcessE
+			outcome.MetalProduced = fs.TotalM
+			outcome.ExcessMetal = fs.ExcessM
+		}
+		resp.Outcomes = append(resp.Outcomes, outcome)
+	}
+	for _, m := range report.Chat {
+		resp.Chat = append(resp.Chat, &ChatMessage{
+			Milliseconds: int32(m.Milliseconds),
+			Sender:       int32(m.Sender),
+			SenderName:   m.SenderName,
+			Scope:        m.Scope,
+			Recipient:    m.Recipient,
+			Text:         m.Text,
+			Watcher:      m.Watcher,
+			Dead:         m.Dead,
+		})
+	}
+	return resp
+}
+
+func unitCounts(counts []tad.UnitCount) []*UnitCount {
+	out := make([]*UnitCount, len(counts))
+	for i, c := range counts {
+		out[i] = &UnitCount{NetId: int32(c.NetID), Name: c.Name, Count: int32(c.Count)}
+	}
+	return out
+}
+
+func eventFromPacket(ev tad.PacketEvent) *Event {
+	e := &Event{
+		Index:        int32(ev.Index),
+		Move:         int32(ev.Move),
+		Milliseconds: int32(ev.Milliseconds),
+		Sender:       int32(ev.Sender),
+		Marker:       int32(ev.Marker),
+	}
+	switch ev.Kind {
+	case tad.UnitSpawnedEvent:
+		e.Event = &Event_UnitSpawned{&UnitSpawned{Unit: int32(ev.Unit), NetId: int32(ev.NetID), X: int32(ev.X), Y: int32(ev.Y)}}
+	case tad.UnitBuiltEvent:
+		e.Event = &Event_UnitBuilt{&UnitBuilt{Unit: int32(ev.Unit), Builder: int32(ev.Other)}}
+	case tad.UnitKilledEvent:
+		e.Event = &Event_UnitKilled{&UnitKilled{Unit: int32(ev.Unit), Destroyer: int32(ev.Other)}}
+	case tad.UnitDamagedEvent:
+		e.Event = &Event_UnitDamaged{&UnitDamaged{Unit: int32(ev.Unit), Damager: int32(ev.Other), Damage: int32(ev.Amount)}}
+	case tad.UnitStateEvent:
+		e.Event = &Event_UnitState{&UnitState{Unit: int32(ev.Unit), State: int32(ev.Amount)}}
+	case tad.ShotEvent:
+		e.Event = &Event_Shot{&Shot{Shooter: int32(ev.Unit), X: int32(ev.X), Y: int32(ev.Y), ToX: int32(ev.ToX), ToY: int32(ev.ToY)}}
+	case tad.ChatEvent:
+		e.Event = &Event_Chat{&Chat{Text: ev.Text}}
+	case tad.ScoreEvent:
+		e.Event = &Event_Score{&Score{
+			Kills:         int32(ev.Score.Kills),
+			Losses:        int32(ev.Score.Losses),
+			TotalMetal:    ev.Score.TotalM,
+			TotalEnergy:   ev.Score.TotalE,
+			ExcessMetal:   ev.Score.ExcessM,
+			ExcessEnergy:  ev.Score.ExcessE,
+			StoredMetal:   ev.Score.StoredM,
+			StoredEnergy:  ev.Score.StoredE,
+			MetalStorage:  ev.Score.StorageM,
+			EnergyStorage: ev.Score.StorageE,
+		}}
+	case tad.AllianceEvent:
+		e.Event = &Event_Alliance{&Alliance{Player: ev.Player, Ally: ev.Ally, Allied: ev.Allied}}
+	case tad.CameraEvent:
+		e.Event = &Event_Camera{&Camera{X: int32(ev.X), Y: int32(ev.Y)}}
+	default:
+		e.Event = &Event_Other{&Other{Data: ev.Data}}
+	}
+	return e
+}
@@ -0,0 +1,175 @@
+package tadrpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/cosmouser/tad"
+	"github.com/cosmouser/tad/internal/tedtest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newClient starts an in-process server and connects to it
+func newClient(t *testing.T) ReplayAnalysisClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	RegisterReplayAnalysisServer(srv, NewServer(nil))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewReplayAnalysisClient(conn)
+}
+
+func TestInvalidDemo(t *testing.T) {
+	client := newClient(t)
+	ctx := context.Background()
+	_, err := client.AnalyzeDemo(ctx, &AnalyzeDemoRequest{Demo: []byte("not a recording")})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("wanted InvalidArgument from AnalyzeDemo, got %v", err)
+	}
+	stream, err := client.StreamEvents(ctx, &StreamEventsRequest{Demo: []byte("not a recording")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("wanted InvalidArgument from StreamEvents, got %v", err)
+	}
+}
+
+// demo makes a recording with a spawn and a chat message from Kazik and the packets
+// of more
+func demo(more ...[]byte) []byte {
+	spawn := make([]byte, 23)
+	spawn[0] = 0x09
+	binary.LittleEndian.PutUint16(spawn[1:], 7)
+	binary.LittleEndian.PutUint16(spawn[3:], 1)
+	binary.LittleEndian.PutUint16(spawn[7:], 1000)
+	binary.LittleEndian.PutUint16(spawn[15:], 2000)
+	chat := make([]byte, 65)
+	chat[0] = 0x05
+	copy(chat[1:], "<Kazik> gl hf")
+	d := &tedtest.Demo{
+		Map:      "Dark Comet",
+		MaxUnits: 500,
+		Players: []tedtest.Player{
+			{Number: 1, Name: "Kazik", TDPID: 11},
+			{Number: 2, Name: "Fez", Side: 1, Color: 1, TDPID: 12},
+		},
+		Units: []uint32{7},
+	}
+	// the last move isn't read
+	for _, packet := range append(append([][]byte{spawn, chat}, more...), []byte{0xff}) {
+		d.Moves = append(d.Moves, tedtest.Move{Time: 1000, Sender: 1, Data: tedtest.Packets(packet)})
+	}
+	return d.Bytes()
+}
+
+func TestAnalyzeDemo(t *testing.T) {
+	client := newClient(t)
+	resp, err := client.AnalyzeDemo(context.Background(), &AnalyzeDemoRequest{Demo: demo()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := resp.Game; g.Map != "Dark Comet" || len(g.Players) != 2 || g.Players[1].Tdpid != 12 || g.Key == "" {
+		t.Errorf("wanted the game and its players, got %v", g)
+	}
+	if len(resp.Chat) != 1 || resp.Chat[0].Text != "gl hf" || resp.Chat[0].SenderName != "Kazik" {
+		t.Errorf("wanted Kazik's message, got %v", resp.Chat)
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	client := newClient(t)
+	// a score packet cut short
+	stream, err := client.StreamEvents(context.Background(), &StreamEventsRequest{Demo: demo([]byte{0x28, 0x01, 0x02})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var events []*Event
+	for {
+		ev, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+	if len(events) != 3 {
+		t.Fatalf("wanted 3 events, got %v", events)
+	}
+	if s := events[0].GetUnitSpawned(); s == nil || s.Unit != 1 || s.NetId != 7 || s.X != 1000 || s.Y != 2000 {
+		t.Errorf("wanted a spawn, got %v", events[0])
+	}
+	if c := events[1].GetChat(); c == nil || c.Text != "<Kazik> gl hf" || events[1].Milliseconds != 2000 {
+		t.Errorf("wanted a chat message, got %v", events[1])
+	}
+	if o := events[2].GetOther(); o == nil || events[2].Marker != 0x28 || len(o.Data) != 3 {
+		t.Errorf("wanted the short score packet as other, got %v", events[2])
+	}
+}
+
+func TestResponseFromReport(t *testing.T) {
+	report := &tad.GameReport{
+		Map:      "Dark Comet",
+		Recorded: "2021-07-20T18:30:00Z",
+		Players: []tad.PlayerReport{{
+			Number:      1,
+			Name:        "Kazik",
+			Side:        "core",
+			TDPID:       -42,
+			Allied:      true,
+			TimeToDie:   500000,
+			Died:        true,
+			Actions:     120,
+			FinalScore:  &tad.FinalScore{Status: 2, Won: 1, Kills: 3},
+			ScoreSeries: []tad.ScoreSample{{Milliseconds: 2000, Metal: 4.5}},
+			Units:       []tad.UnitReport{{NetID: 235, Name: "ARMZEUS", Produced: 4, Kills: []tad.UnitCount{{NetID: 7, Count: 2}}}},
+		}},
+		Chat: []tad.ChatReport{{Sender: 1, Text: "gg"}},
+	}
+	resp := responseFromReport(report)
+	if resp.Game.GetRecorded().AsTime().Year() != 2021 || resp.Game.Players[0].Side != Side_SIDE_CORE {
+		t.Errorf("wanted the recording date and side, got %v", resp.Game)
+	}
+	if p := resp.Game.Players[0]; p.Tdpid != -42 || p.Actions != 120 {
+		t.Errorf("wanted the tdpid and actions, got %v", p)
+	}
+	if o := resp.Outcomes[0]; !o.Allied || o.CommandersKilled != 1 || o.TimeToDie != 500000 || !o.Died {
+		t.Errorf("wanted the outcome of the final score, got %v", o)
+	}
+	if u := resp.Units[0]; u.Player != 1 || u.Kills[0].NetId != 7 || u.Kills[0].Count != 2 {
+		t.Errorf("wanted the unit record, got %v", u)
+	}
+	if resp.ScoreSeries[0].Samples[0].Metal != 4.5 || resp.Chat[0].Text != "gg" {
+		t.Errorf("wanted the score series and chat, got %v", resp)
+	}
+}
+
+func TestEventFromPacket(t *testing.T) {
+	ev := eventFromPacket(tad.PacketEvent{Kind: tad.UnitDamagedEvent, Index: 3, Marker: 0x0b, Unit: 10, Other: 20, Amount: 55})
+	if d := ev.GetUnitDamaged(); d == nil || d.Unit != 10 || d.Damager != 20 || d.Damage != 55 || ev.Index != 3 {
+		t.Errorf("wanted a damage event, got %v", ev)
+	}
+	ev = eventFromPacket(tad.PacketEvent{Marker: 0x77, Data: []byte{0x77, 1}})
+	if o := ev.GetOther(); o == nil || len(o.Data) != 2 {
+		t.Errorf("wanted the raw packet of an unknown marker, got %v", ev)
+	}
+}
@@ -0,0 +1,2338 @@
+// Replay analysis results of Total Annihilation demo recordings (.ted files).
+// Times are milliseconds of game time unless they say otherwise.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: tad.proto
+
+package tadrpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Side int32
+
+const (
+	Side_SIDE_UNSPECIFIED Side = 0
+	Side_SIDE_ARM         Side = 1
+	Side_SIDE_CORE        Side = 2
+	Side_SIDE_WATCHER     Side = 3
+)
+
+// Enum value maps for Side.
+var (
+	Side_name = map[int32]string{
+		0: "SIDE_UNSPECIFIED",
+		1: "SIDE_ARM",
+		2: "SIDE_CORE",
+		3: "SIDE_WATCHER",
+	}
+	Side_value = map[string]int32{
+		"SIDE_UNSPECIFIED": 0,
+		"SIDE_ARM":         1,
+		"SIDE_CORE":        2,
+		"SIDE_WATCHER":     3,
+	}
+)
+
+func (x Side) Enum() *Side {
+	p := new(Side)
+	*p = x
+	return p
+}
+
+func (x Side) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Side) Descriptor() protoreflect.EnumDescriptor {
+	return file_tad_proto_enumTypes[0].Descriptor()
+}
+
+func (Side) Type() protoreflect.EnumType {
+	return &file_tad_proto_enumTypes[0]
+}
+
+func (x Side) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Side.Descriptor instead.
+func (Side) EnumDescriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{0}
+}
+
+type AnalyzeDemoRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Demo          []byte                 `protobuf:"bytes,1,opt,name=demo,proto3" json:"demo,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeDemoRequest) Reset() {
+	*x = AnalyzeDemoRequest{}
+	mi := &file_tad_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeDemoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeDemoRequest) ProtoMessage() {}
+
+func (x *AnalyzeDemoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeDemoRequest.ProtoReflect.Descriptor instead.
+func (*AnalyzeDemoRequest) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AnalyzeDemoRequest) GetDemo() []byte {
+	if x != nil {
+		return x.Demo
+	}
+	return nil
+}
+
+type AnalyzeDemoResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Game          *Game                  `protobuf:"bytes,1,opt,name=game,proto3" json:"game,omitempty"`
+	ScoreSeries   []*ScoreSeries         `protobuf:"bytes,2,rep,name=score_series,json=scoreSeries,proto3" json:"score_series,omitempty"`
+	Units         []*UnitRecord          `protobuf:"bytes,3,rep,name=units,proto3" json:"units,omitempty"`
+	Outcomes      []*Outcome             `protobuf:"bytes,4,rep,name=outcomes,proto3" json:"outcomes,omitempty"`
+	Chat          []*ChatMessage         `protobuf:"bytes,5,rep,name=chat,proto3" json:"chat,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeDemoResponse) Reset() {
+	*x = AnalyzeDemoResponse{}
+	mi := &file_tad_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeDemoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeDemoResponse) ProtoMessage() {}
+
+func (x *AnalyzeDemoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeDemoResponse.ProtoReflect.Descriptor instead.
+func (*AnalyzeDemoResponse) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AnalyzeDemoResponse) GetGame() *Game {
+	if x != nil {
+		return x.Game
+	}
+	return nil
+}
+
+func (x *AnalyzeDemoResponse) GetScoreSeries() []*ScoreSeries {
+	if x != nil {
+		return x.ScoreSeries
+	}
+	return nil
+}
+
+func (x *AnalyzeDemoResponse) GetUnits() []*UnitRecord {
+	if x != nil {
+		return x.Units
+	}
+	return nil
+}
+
+func (x *AnalyzeDemoResponse) GetOutcomes() []*Outcome {
+	if x != nil {
+		return x.Outcomes
+	}
+	return nil
+}
+
+func (x *AnalyzeDemoResponse) GetChat() []*ChatMessage {
+	if x != nil {
+		return x.Chat
+	}
+	return nil
+}
+
+type StreamEventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Demo          []byte                 `protobuf:"bytes,1,opt,name=demo,proto3" json:"demo,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamEventsRequest) Reset() {
+	*x = StreamEventsRequest{}
+	mi := &file_tad_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamEventsRequest) ProtoMessage() {}
+
+func (x *StreamEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamEventsRequest.ProtoReflect.Descriptor instead.
+func (*StreamEventsRequest) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *StreamEventsRequest) GetDemo() []byte {
+	if x != nil {
+		return x.Demo
+	}
+	return nil
+}
+
+type Game struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Map          string                 `protobuf:"bytes,1,opt,name=map,proto3" json:"map,omitempty"`
+	Recorder     string                 `protobuf:"bytes,2,opt,name=recorder,proto3" json:"recorder,omitempty"`
+	Recorded     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=recorded,proto3" json:"recorded,omitempty"`
+	RecordedFrom string                 `protobuf:"bytes,4,opt,name=recorded_from,json=recordedFrom,proto3" json:"recorded_from,omitempty"`
+	Comments     string                 `protobuf:"bytes,5,opt,name=comments,proto3" json:"comments,omitempty"`
+	Milliseconds int32                  `protobuf:"varint,6,opt,name=milliseconds,proto3" json:"milliseconds,omitempty"`
+	Moves        int32                  `protobuf:"varint,7,opt,name=moves,proto3" json:"moves,omitempty"`
+	MaxUnits     int32                  `protobuf:"varint,8,opt,name=max_units,json=maxUnits,proto3" json:"max_units,omitempty"`
+	Unitsum      string                 `protobuf:"bytes,9,opt,name=unitsum,proto3" json:"unitsum,omitempty"`
+	Fingerprint  string                 `protobuf:"bytes,10,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	Players      []*Player              `protobuf:"bytes,11,rep,name=players,proto3" json:"players,omitempty"`
+	// tells apart games with the same fingerprint, see tad.Game.GetGameKey
+	Key           string `protobuf:"bytes,12,opt,name=key,proto3" json:"key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Game) Reset() {
+	*x = Game{}
+	mi := &file_tad_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Game) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Game) ProtoMessage() {}
+
+func (x *Game) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Game.ProtoReflect.Descriptor instead.
+func (*Game) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Game) GetMap() string {
+	if x != nil {
+		return x.Map
+	}
+	return ""
+}
+
+func (x *Game) GetRecorder() string {
+	if x != nil {
+		return x.Recorder
+	}
+	return ""
+}
+
+func (x *Game) GetRecorded() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Recorded
+	}
+	return nil
+}
+
+func (x *Game) GetRecordedFrom() string {
+	if x != nil {
+		return x.RecordedFrom
+	}
+	return ""
+}
+
+func (x *Game) GetComments() string {
+	if x != nil {
+		return x.Comments
+	}
+	return ""
+}
+
+func (x *Game) GetMilliseconds() int32 {
+	if x != nil {
+		return x.Milliseconds
+	}
+	return 0
+}
+
+func (x *Game) GetMoves() int32 {
+	if x != nil {
+		return x.Moves
+	}
+	return 0
+}
+
+func (x *Game) GetMaxUnits() int32 {
+	if x != nil {
+		return x.MaxUnits
+	}
+	return 0
+}
+
+func (x *Game) GetUnitsum() string {
+	if x != nil {
+		return x.Unitsum
+	}
+	return ""
+}
+
+func (x *Game) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+func (x *Game) GetPlayers() []*Player {
+	if x != nil {
+		return x.Players
+	}
+	return nil
+}
+
+func (x *Game) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+type Player struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Number int32                  `protobuf:"varint,1,opt,name=number,proto3" json:"number,omitempty"`
+	Name   string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Side   Side                   `protobuf:"varint,3,opt,name=side,proto3,enum=tad.v1.Side" json:"side,omitempty"`
+	Color  int32                  `protobuf:"varint,4,opt,name=color,proto3" json:"color,omitempty"`
+	Status string                 `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	Ip     string                 `protobuf:"bytes,6,opt,name=ip,proto3" json:"ip,omitempty"`
+	Cheats bool                   `protobuf:"varint,7,opt,name=cheats,proto3" json:"cheats,omitempty"`
+	Tdpid  int32                  `protobuf:"varint,8,opt,name=tdpid,proto3" json:"tdpid,omitempty"`
+	// builds started and unit state changes over the game, see tad.ActionsWorker
+	Actions       int32 `protobuf:"varint,9,opt,name=actions,proto3" json:"actions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Player) Reset() {
+	*x = Player{}
+	mi := &file_tad_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Player) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Player) ProtoMessage() {}
+
+func (x *Player) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Player.ProtoReflect.Descriptor instead.
+func (*Player) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Player) GetNumber() int32 {
+	if x != nil {
+		return x.Number
+	}
+	return 0
+}
+
+func (x *Player) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Player) GetSide() Side {
+	if x != nil {
+		return x.Side
+	}
+	return Side_SIDE_UNSPECIFIED
+}
+
+func (x *Player) GetColor() int32 {
+	if x != nil {
+		return x.Color
+	}
+	return 0
+}
+
+func (x *Player) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Player) GetIp() string {
+	if x != nil {
+		return x.Ip
+	}
+	return ""
+}
+
+func (x *Player) GetCheats() bool {
+	if x != nil {
+		return x.Cheats
+	}
+	return false
+}
+
+func (x *Player) GetTdpid() int32 {
+	if x != nil {
+		return x.Tdpid
+	}
+	return 0
+}
+
+func (x *Player) GetActions() int32 {
+	if x != nil {
+		return x.Actions
+	}
+	return 0
+}
+
+type ScoreSeries struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Player        int32                  `protobuf:"varint,1,opt,name=player,proto3" json:"player,omitempty"`
+	Samples       []*ScoreSample         `protobuf:"bytes,2,rep,name=samples,proto3" json:"samples,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScoreSeries) Reset() {
+	*x = ScoreSeries{}
+	mi := &file_tad_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScoreSeries) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScoreSeries) ProtoMessage() {}
+
+func (x *ScoreSeries) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScoreSeries.ProtoReflect.Descriptor instead.
+func (*ScoreSeries) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ScoreSeries) GetPlayer() int32 {
+	if x != nil {
+		return x.Player
+	}
+	return 0
+}
+
+func (x *ScoreSeries) GetSamples() []*ScoreSample {
+	if x != nil {
+		return x.Samples
+	}
+	return nil
+}
+
+type ScoreSample struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Milliseconds int32                  `protobuf:"varint,1,opt,name=milliseconds,proto3" json:"milliseconds,omitempty"`
+	Kills        int32                  `protobuf:"varint,2,opt,name=kills,proto3" json:"kills,omitempty"`
+	Losses       int32                  `protobuf:"varint,3,opt,name=losses,proto3" json:"losses,omitempty"`
+	// metal and energy are per second
+	Metal         float64 `protobuf:"fixed64,4,opt,name=metal,proto3" json:"metal,omitempty"`
+	Energy        float64 `protobuf:"fixed64,5,opt,name=energy,proto3" json:"energy,omitempty"`
+	TotalMetal    float64 `protobuf:"fixed64,6,opt,name=total_metal,json=totalMetal,proto3" json:"total_metal,omitempty"`
+	TotalEnergy   float64 `protobuf:"fixed64,7,opt,name=total_energy,json=totalEnergy,proto3" json:"total_energy,omitempty"`
+	ExcessMetal   float64 `protobuf:"fixed64,8,opt,name=excess_metal,json=excessMetal,proto3" json:"excess_metal,omitempty"`
+	ExcessEnergy  float64 `protobuf:"fixed64,9,opt,name=excess_energy,json=excessEnergy,proto3" json:"excess_energy,omitempty"`
+	StoredMetal   float64 `protobuf:"fixed64,10,opt,name=stored_metal,json=storedMetal,proto3" json:"stored_metal,omitempty"`
+	StoredEnergy  float64 `protobuf:"fixed64,11,opt,name=stored_energy,json=storedEnergy,proto3" json:"stored_energy,omitempty"`
+	MetalStorage  float64 `protobuf:"fixed64,12,opt,name=metal_storage,json=metalStorage,proto3" json:"metal_storage,omitempty"`
+	EnergyStorage float64 `protobuf:"fixed64,13,opt,name=energy_storage,json=energyStorage,proto3" json:"energy_storage,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScoreSample) Reset() {
+	*x = ScoreSample{}
+	mi := &file_tad_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScoreSample) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScoreSample) ProtoMessage() {}
+
+func (x *ScoreSample) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScoreSample.ProtoReflect.Descriptor instead.
+func (*ScoreSample) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ScoreSample) GetMilliseconds() int32 {
+	if x != nil {
+		return x.Milliseconds
+	}
+	return 0
+}
+
+func (x *ScoreSample) GetKills() int32 {
+	if x != nil {
+		return x.Kills
+	}
+	return 0
+}
+
+func (x *ScoreSample) GetLosses() int32 {
+	if x != nil {
+		return x.Losses
+	}
+	return 0
+}
+
+func (x *ScoreSample) GetMetal() float64 {
+	if x != nil {
+		return x.Metal
+	}
+	return 0
+}
+
+func (x *ScoreSample) GetEnergy() float64 {
+	if x != nil {
+		return x.Energy
+	}
+	return 0
+}
+
+func (x *ScoreSample) GetTotalMetal() float64 {
+	if x != nil {
+		return x.TotalMetal
+	}
+	return 0
+}
+
+func (x *ScoreSample) GetTotalEnergy() float64 {
+	if x != nil {
+		return x.TotalEnergy
+	}
+	return 0
+}
+
+func (x *ScoreSample) GetExcessMetal() float64 {
+	if x != nil {
+		return x.ExcessMetal
+	}
+	return 0
+}
+
+func (x *ScoreSample) GetExcessEnergy() float64 {
+	if x != nil {
+		return x.ExcessEnergy
+	}
+	return 0
+}
+
+func (x *ScoreSample) GetStoredMetal() float64 {
+	if x != nil {
+		return x.StoredMetal
+	}
+	return 0
+}
+
+func (x *ScoreSample) GetStoredEnergy() float64 {
+	if x != nil {
+		return x.StoredEnergy
+	}
+	return 0
+}
+
+func (x *ScoreSample) GetMetalStorage() float64 {
+	if x != nil {
+		return x.MetalStorage
+	}
+	return 0
+}
+
+func (x *ScoreSample) GetEnergyStorage() float64 {
+	if x != nil {
+		return x.EnergyStorage
+	}
+	return 0
+}
+
+// UnitRecord is one unit type built by a player
+type UnitRecord struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Player         int32                  `protobuf:"varint,1,opt,name=player,proto3" json:"player,omitempty"`
+	NetId          int32                  `protobuf:"varint,2,opt,name=net_id,json=netId,proto3" json:"net_id,omitempty"`
+	Name           string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Produced       int32                  `protobuf:"varint,4,opt,name=produced,proto3" json:"produced,omitempty"`
+	FirstProduced  int32                  `protobuf:"varint,5,opt,name=first_produced,json=firstProduced,proto3" json:"first_produced,omitempty"`
+	DamageDealt    int32                  `protobuf:"varint,6,opt,name=damage_dealt,json=damageDealt,proto3" json:"damage_dealt,omitempty"`
+	DamageReceived int32                  `protobuf:"varint,7,opt,name=damage_received,json=damageReceived,proto3" json:"damage_received,omitempty"`
+	Kills          []*UnitCount           `protobuf:"bytes,8,rep,name=kills,proto3" json:"kills,omitempty"`
+	Deaths         []*UnitCount           `protobuf:"bytes,9,rep,name=deaths,proto3" json:"deaths,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *UnitRecord) Reset() {
+	*x = UnitRecord{}
+	mi := &file_tad_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnitRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnitRecord) ProtoMessage() {}
+
+func (x *UnitRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnitRecord.ProtoReflect.Descriptor instead.
+func (*UnitRecord) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *UnitRecord) GetPlayer() int32 {
+	if x != nil {
+		return x.Player
+	}
+	return 0
+}
+
+func (x *UnitRecord) GetNetId() int32 {
+	if x != nil {
+		return x.NetId
+	}
+	return 0
+}
+
+func (x *UnitRecord) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UnitRecord) GetProduced() int32 {
+	if x != nil {
+		return x.Produced
+	}
+	return 0
+}
+
+func (x *UnitRecord) GetFirstProduced() int32 {
+	if x != nil {
+		return x.FirstProduced
+	}
+	return 0
+}
+
+func (x *UnitRecord) GetDamageDealt() int32 {
+	if x != nil {
+		return x.DamageDealt
+	}
+	return 0
+}
+
+func (x *UnitRecord) GetDamageReceived() int32 {
+	if x != nil {
+		return x.DamageReceived
+	}
+	return 0
+}
+
+func (x *UnitRecord) GetKills() []*UnitCount {
+	if x != nil {
+		return x.Kills
+	}
+	return nil
+}
+
+func (x *UnitRecord) GetDeaths() []*UnitCount {
+	if x != nil {
+		return x.Deaths
+	}
+	return nil
+}
+
+type UnitCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	NetId         int32                  `protobuf:"varint,1,opt,name=net_id,json=netId,proto3" json:"net_id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Count         int32                  `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnitCount) Reset() {
+	*x = UnitCount{}
+	mi := &file_tad_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnitCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnitCount) ProtoMessage() {}
+
+func (x *UnitCount) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnitCount.ProtoReflect.Descriptor instead.
+func (*UnitCount) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *UnitCount) GetNetId() int32 {
+	if x != nil {
+		return x.NetId
+	}
+	return 0
+}
+
+func (x *UnitCount) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UnitCount) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+// Outcome is how the game ended for a player
+type Outcome struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Player           int32                  `protobuf:"varint,1,opt,name=player,proto3" json:"player,omitempty"`
+	Status           int32                  `protobuf:"varint,2,opt,name=status,proto3" json:"status,omitempty"`
+	CommandersKilled int32                  `protobuf:"varint,3,opt,name=commanders_killed,json=commandersKilled,proto3" json:"commanders_killed,omitempty"`
+	CommandersLost   int32                  `protobuf:"varint,4,opt,name=commanders_lost,json=commandersLost,proto3" json:"commanders_lost,omitempty"`
+	Kills            int32                  `protobuf:"varint,5,opt,name=kills,proto3" json:"kills,omitempty"`
+	Losses           int32                  `protobuf:"varint,6,opt,name=losses,proto3" json:"losses,omitempty"`
+	EnergyProduced   float64                `protobuf:"fixed64,7,opt,name=energy_produced,json=energyProduced,proto3" json:"energy_produced,omitempty"`
+	ExcessEnergy     float64                `protobuf:"fixed64,8,opt,name=excess_energy,json=excessEnergy,proto3" json:"excess_energy,omitempty"`
+	MetalProduced    float64                `protobuf:"fixed64,9,opt,name=metal_produced,json=metalProduced,proto3" json:"metal_produced,omitempty"`
+	ExcessMetal      float64                `protobuf:"fixed64,10,opt,name=excess_metal,json=excessMetal,proto3" json:"excess_metal,omitempty"`
+	// milliseconds until the player died. Players who lasted to the end get the game's
+	// length plus 1 and watchers get 0.
+	TimeToDie int32 `protobuf:"varint,11,opt,name=time_to_die,json=timeToDie,proto3" json:"time_to_die,omitempty"`
+	// allied with the player who recorded the game
+	Allied   bool `protobuf:"varint,12,opt,name=allied,proto3" json:"allied,omitempty"`
+	FoulPlay bool `protobuf:"varint,13,opt,name=foul_play,json=foulPlay,proto3" json:"foul_play,omitempty"`
+	// their commander was destroyed
+	Died          bool `protobuf:"varint,14,opt,name=died,proto3" json:"died,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Outcome) Reset() {
+	*x = Outcome{}
+	mi := &file_tad_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Outcome) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Outcome) ProtoMessage() {}
+
+func (x *Outcome) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Outcome.ProtoReflect.Descriptor instead.
+func (*Outcome) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Outcome) GetPlayer() int32 {
+	if x != nil {
+		return x.Player
+	}
+	return 0
+}
+
+func (x *Outcome) GetStatus() int32 {
+	if x != nil {
+		return x.Status
+	}
+	return 0
+}
+
+func (x *Outcome) GetCommandersKilled() int32 {
+	if x != nil {
+		return x.CommandersKilled
+	}
+	return 0
+}
+
+func (x *Outcome) GetCommandersLost() int32 {
+	if x != nil {
+		return x.CommandersLost
+	}
+	return 0
+}
+
+func (x *Outcome) GetKills() int32 {
+	if x != nil {
+		return x.Kills
+	}
+	return 0
+}
+
+func (x *Outcome) GetLosses() int32 {
+	if x != nil {
+		return x.Losses
+	}
+	return 0
+}
+
+func (x *Outcome) GetEnergyProduced() float64 {
+	if x != nil {
+		return x.EnergyProduced
+	}
+	return 0
+}
+
+func (x *Outcome) GetExcessEnergy() float64 {
+	if x != nil {
+		return x.ExcessEnergy
+	}
+	return 0
+}
+
+func (x *Outcome) GetMetalProduced() float64 {
+	if x != nil {
+		return x.MetalProduced
+	}
+	return 0
+}
+
+func (x *Outcome) GetExcessMetal() float64 {
+	if x != nil {
+		return x.ExcessMetal
+	}
+	return 0
+}
+
+func (x *Outcome) GetTimeToDie() int32 {
+	if x != nil {
+		return x.TimeToDie
+	}
+	return 0
+}
+
+func (x *Outcome) GetAllied() bool {
+	if x != nil {
+		return x.Allied
+	}
+	return false
+}
+
+func (x *Outcome) GetFoulPlay() bool {
+	if x != nil {
+		return x.FoulPlay
+	}
+	return false
+}
+
+func (x *Outcome) GetDied() bool {
+	if x != nil {
+		return x.Died
+	}
+	return false
+}
+
+type ChatMessage struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Milliseconds  int32                  `protobuf:"varint,1,opt,name=milliseconds,proto3" json:"milliseconds,omitempty"`
+	Sender        int32                  `protobuf:"varint,2,opt,name=sender,proto3" json:"sender,omitempty"`
+	SenderName    string                 `protobuf:"bytes,3,opt,name=sender_name,json=senderName,proto3" json:"sender_name,omitempty"`
+	Scope         string                 `protobuf:"bytes,4,opt,name=scope,proto3" json:"scope,omitempty"`
+	Recipient     string                 `protobuf:"bytes,5,opt,name=recipient,proto3" json:"recipient,omitempty"`
+	Text          string                 `protobuf:"bytes,6,opt,name=text,proto3" json:"text,omitempty"`
+	Watcher       bool                   `protobuf:"varint,7,opt,name=watcher,proto3" json:"watcher,omitempty"`
+	Dead          bool                   `protobuf:"varint,8,opt,name=dead,proto3" json:"dead,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChatMessage) Reset() {
+	*x = ChatMessage{}
+	mi := &file_tad_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChatMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChatMessage) ProtoMessage() {}
+
+func (x *ChatMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChatMessage.ProtoReflect.Descriptor instead.
+func (*ChatMessage) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ChatMessage) GetMilliseconds() int32 {
+	if x != nil {
+		return x.Milliseconds
+	}
+	return 0
+}
+
+func (x *ChatMessage) GetSender() int32 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *ChatMessage) GetSenderName() string {
+	if x != nil {
+		return x.SenderName
+	}
+	return ""
+}
+
+func (x *ChatMessage) GetScope() string {
+	if x != nil {
+		return x.Scope
+	}
+	return ""
+}
+
+func (x *ChatMessage) GetRecipient() string {
+	if x != nil {
+		return x.Recipient
+	}
+	return ""
+}
+
+func (x *ChatMessage) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *ChatMessage) GetWatcher() bool {
+	if x != nil {
+		return x.Watcher
+	}
+	return false
+}
+
+func (x *ChatMessage) GetDead() bool {
+	if x != nil {
+		return x.Dead
+	}
+	return false
+}
+
+// Event is a decoded packet. Unit fields are unit IDs which a UnitSpawned event ties to
+// a unit type.
+type Event struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Index        int32                  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Move         int32                  `protobuf:"varint,2,opt,name=move,proto3" json:"move,omitempty"`
+	Milliseconds int32                  `protobuf:"varint,3,opt,name=milliseconds,proto3" json:"milliseconds,omitempty"`
+	Sender       int32                  `protobuf:"varint,4,opt,name=sender,proto3" json:"sender,omitempty"`
+	Marker       int32                  `protobuf:"varint,5,opt,name=marker,proto3" json:"marker,omitempty"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*Event_UnitSpawned
+	//	*Event_UnitBuilt
+	//	*Event_UnitKilled
+	//	*Event_UnitDamaged
+	//	*Event_UnitState
+	//	*Event_Shot
+	//	*Event_Chat
+	//	*Event_Score
+	//	*Event_Alliance
+	//	*Event_Camera
+	//	*Event_Other
+	Event         isEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_tad_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Event) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *Event) GetMove() int32 {
+	if x != nil {
+		return x.Move
+	}
+	return 0
+}
+
+func (x *Event) GetMilliseconds() int32 {
+	if x != nil {
+		return x.Milliseconds
+	}
+	return 0
+}
+
+func (x *Event) GetSender() int32 {
+	if x != nil {
+		return x.Sender
+	}
+	return 0
+}
+
+func (x *Event) GetMarker() int32 {
+	if x != nil {
+		return x.Marker
+	}
+	return 0
+}
+
+func (x *Event) GetEvent() isEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *Event) GetUnitSpawned() *UnitSpawned {
+	if x != nil {
+		if x, ok := x.Event.(*Event_UnitSpawned); ok {
+			return x.UnitSpawned
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetUnitBuilt() *UnitBuilt {
+	if x != nil {
+		if x, ok := x.Event.(*Event_UnitBuilt); ok {
+			return x.UnitBuilt
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetUnitKilled() *UnitKilled {
+	if x != nil {
+		if x, ok := x.Event.(*Event_UnitKilled); ok {
+			return x.UnitKilled
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetUnitDamaged() *UnitDamaged {
+	if x != nil {
+		if x, ok := x.Event.(*Event_UnitDamaged); ok {
+			return x.UnitDamaged
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetUnitState() *UnitState {
+	if x != nil {
+		if x, ok := x.Event.(*Event_UnitState); ok {
+			return x.UnitState
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetShot() *Shot {
+	if x != nil {
+		if x, ok := x.Event.(*Event_Shot); ok {
+			return x.Shot
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetChat() *Chat {
+	if x != nil {
+		if x, ok := x.Event.(*Event_Chat); ok {
+			return x.Chat
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetScore() *Score {
+	if x != nil {
+		if x, ok := x.Event.(*Event_Score); ok {
+			return x.Score
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetAlliance() *Alliance {
+	if x != nil {
+		if x, ok := x.Event.(*Event_Alliance); ok {
+			return x.Alliance
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetCamera() *Camera {
+	if x != nil {
+		if x, ok := x.Event.(*Event_Camera); ok {
+			return x.Camera
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetOther() *Other {
+	if x != nil {
+		if x, ok := x.Event.(*Event_Other); ok {
+			return x.Other
+		}
+	}
+	return nil
+}
+
+type isEvent_Event interface {
+	isEvent_Event()
+}
+
+type Event_UnitSpawned struct {
+	UnitSpawned *UnitSpawned `protobuf:"bytes,10,opt,name=unit_spawned,json=unitSpawned,proto3,oneof"`
+}
+
+type Event_UnitBuilt struct {
+	UnitBuilt *UnitBuilt `protobuf:"bytes,11,opt,name=unit_built,json=unitBuilt,proto3,oneof"`
+}
+
+type Event_UnitKilled struct {
+	UnitKilled *UnitKilled `protobuf:"bytes,12,opt,name=unit_killed,json=unitKilled,proto3,oneof"`
+}
+
+type Event_UnitDamaged struct {
+	UnitDamaged *UnitDamaged `protobuf:"bytes,13,opt,name=unit_damaged,json=unitDamaged,proto3,oneof"`
+}
+
+type Event_UnitState struct {
+	UnitState *UnitState `protobuf:"bytes,14,opt,name=unit_state,json=unitState,proto3,oneof"`
+}
+
+type Event_Shot struct {
+	Shot *Shot `protobuf:"bytes,15,opt,name=shot,proto3,oneof"`
+}
+
+type Event_Chat struct {
+	Chat *Chat `protobuf:"bytes,16,opt,name=chat,proto3,oneof"`
+}
+
+type Event_Score struct {
+	Score *Score `protobuf:"bytes,17,opt,name=score,proto3,oneof"`
+}
+
+type Event_Alliance struct {
+	Alliance *Alliance `protobuf:"bytes,18,opt,name=alliance,proto3,oneof"`
+}
+
+type Event_Camera struct {
+	Camera *Camera `protobuf:"bytes,19,opt,name=camera,proto3,oneof"`
+}
+
+type Event_Other struct {
+	Other *Other `protobuf:"bytes,20,opt,name=other,proto3,oneof"`
+}
+
+func (*Event_UnitSpawned) isEvent_Event() {}
+
+func (*Event_UnitBuilt) isEvent_Event() {}
+
+func (*Event_UnitKilled) isEvent_Event() {}
+
+func (*Event_UnitDamaged) isEvent_Event() {}
+
+func (*Event_UnitState) isEvent_Event() {}
+
+func (*Event_Shot) isEvent_Event() {}
+
+func (*Event_Chat) isEvent_Event() {}
+
+func (*Event_Score) isEvent_Event() {}
+
+func (*Event_Alliance) isEvent_Event() {}
+
+func (*Event_Camera) isEvent_Event() {}
+
+func (*Event_Other) isEvent_Event() {}
+
+type UnitSpawned struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Unit          int32                  `protobuf:"varint,1,opt,name=unit,proto3" json:"unit,omitempty"`
+	NetId         int32                  `protobuf:"varint,2,opt,name=net_id,json=netId,proto3" json:"net_id,omitempty"`
+	X             int32                  `protobuf:"varint,3,opt,name=x,proto3" json:"x,omitempty"`
+	Y             int32                  `protobuf:"varint,4,opt,name=y,proto3" json:"y,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnitSpawned) Reset() {
+	*x = UnitSpawned{}
+	mi := &file_tad_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnitSpawned) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnitSpawned) ProtoMessage() {}
+
+func (x *UnitSpawned) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnitSpawned.ProtoReflect.Descriptor instead.
+func (*UnitSpawned) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *UnitSpawned) GetUnit() int32 {
+	if x != nil {
+		return x.Unit
+	}
+	return 0
+}
+
+func (x *UnitSpawned) GetNetId() int32 {
+	if x != nil {
+		return x.NetId
+	}
+	return 0
+}
+
+func (x *UnitSpawned) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *UnitSpawned) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+type UnitBuilt struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Unit          int32                  `protobuf:"varint,1,opt,name=unit,proto3" json:"unit,omitempty"`
+	Builder       int32                  `protobuf:"varint,2,opt,name=builder,proto3" json:"builder,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnitBuilt) Reset() {
+	*x = UnitBuilt{}
+	mi := &file_tad_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnitBuilt) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnitBuilt) ProtoMessage() {}
+
+func (x *UnitBuilt) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnitBuilt.ProtoReflect.Descriptor instead.
+func (*UnitBuilt) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *UnitBuilt) GetUnit() int32 {
+	if x != nil {
+		return x.Unit
+	}
+	return 0
+}
+
+func (x *UnitBuilt) GetBuilder() int32 {
+	if x != nil {
+		return x.Builder
+	}
+	return 0
+}
+
+type UnitKilled struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Unit          int32                  `protobuf:"varint,1,opt,name=unit,proto3" json:"unit,omitempty"`
+	Destroyer     int32                  `protobuf:"varint,2,opt,name=destroyer,proto3" json:"destroyer,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnitKilled) Reset() {
+	*x = UnitKilled{}
+	mi := &file_tad_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnitKilled) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnitKilled) ProtoMessage() {}
+
+func (x *UnitKilled) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnitKilled.ProtoReflect.Descriptor instead.
+func (*UnitKilled) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *UnitKilled) GetUnit() int32 {
+	if x != nil {
+		return x.Unit
+	}
+	return 0
+}
+
+func (x *UnitKilled) GetDestroyer() int32 {
+	if x != nil {
+		return x.Destroyer
+	}
+	return 0
+}
+
+type UnitDamaged struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Unit          int32                  `protobuf:"varint,1,opt,name=unit,proto3" json:"unit,omitempty"`
+	Damager       int32                  `protobuf:"varint,2,opt,name=damager,proto3" json:"damager,omitempty"`
+	Damage        int32                  `protobuf:"varint,3,opt,name=damage,proto3" json:"damage,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnitDamaged) Reset() {
+	*x = UnitDamaged{}
+	mi := &file_tad_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnitDamaged) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnitDamaged) ProtoMessage() {}
+
+func (x *UnitDamaged) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnitDamaged.ProtoReflect.Descriptor instead.
+func (*UnitDamaged) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *UnitDamaged) GetUnit() int32 {
+	if x != nil {
+		return x.Unit
+	}
+	return 0
+}
+
+func (x *UnitDamaged) GetDamager() int32 {
+	if x != nil {
+		return x.Damager
+	}
+	return 0
+}
+
+func (x *UnitDamaged) GetDamage() int32 {
+	if x != nil {
+		return x.Damage
+	}
+	return 0
+}
+
+type UnitState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Unit          int32                  `protobuf:"varint,1,opt,name=unit,proto3" json:"unit,omitempty"`
+	State         int32                  `protobuf:"varint,2,opt,name=state,proto3" json:"state,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UnitState) Reset() {
+	*x = UnitState{}
+	mi := &file_tad_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UnitState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UnitState) ProtoMessage() {}
+
+func (x *UnitState) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UnitState.ProtoReflect.Descriptor instead.
+func (*UnitState) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *UnitState) GetUnit() int32 {
+	if x != nil {
+		return x.Unit
+	}
+	return 0
+}
+
+func (x *UnitState) GetState() int32 {
+	if x != nil {
+		return x.State
+	}
+	return 0
+}
+
+type Shot struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Shooter       int32                  `protobuf:"varint,1,opt,name=shooter,proto3" json:"shooter,omitempty"`
+	X             int32                  `protobuf:"varint,2,opt,name=x,proto3" json:"x,omitempty"`
+	Y             int32                  `protobuf:"varint,3,opt,name=y,proto3" json:"y,omitempty"`
+	ToX           int32                  `protobuf:"varint,4,opt,name=to_x,json=toX,proto3" json:"to_x,omitempty"`
+	ToY           int32                  `protobuf:"varint,5,opt,name=to_y,json=toY,proto3" json:"to_y,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Shot) Reset() {
+	*x = Shot{}
+	mi := &file_tad_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Shot) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Shot) ProtoMessage() {}
+
+func (x *Shot) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Shot.ProtoReflect.Descriptor instead.
+func (*Shot) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *Shot) GetShooter() int32 {
+	if x != nil {
+		return x.Shooter
+	}
+	return 0
+}
+
+func (x *Shot) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *Shot) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+func (x *Shot) GetToX() int32 {
+	if x != nil {
+		return x.ToX
+	}
+	return 0
+}
+
+func (x *Shot) GetToY() int32 {
+	if x != nil {
+		return x.ToY
+	}
+	return 0
+}
+
+type Chat struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Text          string                 `protobuf:"bytes,1,opt,name=text,proto3" json:"text,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Chat) Reset() {
+	*x = Chat{}
+	mi := &file_tad_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Chat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chat) ProtoMessage() {}
+
+func (x *Chat) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chat.ProtoReflect.Descriptor instead.
+func (*Chat) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Chat) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+// Score is a score packet without the rates a ScoreSample works out
+type Score struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kills         int32                  `protobuf:"varint,1,opt,name=kills,proto3" json:"kills,omitempty"`
+	Losses        int32                  `protobuf:"varint,2,opt,name=losses,proto3" json:"losses,omitempty"`
+	TotalMetal    float64                `protobuf:"fixed64,3,opt,name=total_metal,json=totalMetal,proto3" json:"total_metal,omitempty"`
+	TotalEnergy   float64                `protobuf:"fixed64,4,opt,name=total_energy,json=totalEnergy,proto3" json:"total_energy,omitempty"`
+	ExcessMetal   float64                `protobuf:"fixed64,5,opt,name=excess_metal,json=excessMetal,proto3" json:"excess_metal,omitempty"`
+	ExcessEnergy  float64                `protobuf:"fixed64,6,opt,name=excess_energy,json=excessEnergy,proto3" json:"excess_energy,omitempty"`
+	StoredMetal   float64                `protobuf:"fixed64,7,opt,name=stored_metal,json=storedMetal,proto3" json:"stored_metal,omitempty"`
+	StoredEnergy  float64                `protobuf:"fixed64,8,opt,name=stored_energy,json=storedEnergy,proto3" json:"stored_energy,omitempty"`
+	MetalStorage  float64                `protobuf:"fixed64,9,opt,name=metal_storage,json=metalStorage,proto3" json:"metal_storage,omitempty"`
+	EnergyStorage float64                `protobuf:"fixed64,10,opt,name=energy_storage,json=energyStorage,proto3" json:"energy_storage,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Score) Reset() {
+	*x = Score{}
+	mi := &file_tad_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Score) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Score) ProtoMessage() {}
+
+func (x *Score) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Score.ProtoReflect.Descriptor instead.
+func (*Score) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *Score) GetKills() int32 {
+	if x != nil {
+		return x.Kills
+	}
+	return 0
+}
+
+func (x *Score) GetLosses() int32 {
+	if x != nil {
+		return x.Losses
+	}
+	return 0
+}
+
+func (x *Score) GetTotalMetal() float64 {
+	if x != nil {
+		return x.TotalMetal
+	}
+	return 0
+}
+
+func (x *Score) GetTotalEnergy() float64 {
+	if x != nil {
+		return x.TotalEnergy
+	}
+	return 0
+}
+
+func (x *Score) GetExcessMetal() float64 {
+	if x != nil {
+		return x.ExcessMetal
+	}
+	return 0
+}
+
+func (x *Score) GetExcessEnergy() float64 {
+	if x != nil {
+		return x.ExcessEnergy
+	}
+	return 0
+}
+
+func (x *Score) GetStoredMetal() float64 {
+	if x != nil {
+		return x.StoredMetal
+	}
+	return 0
+}
+
+func (x *Score) GetStoredEnergy() float64 {
+	if x != nil {
+		return x.StoredEnergy
+	}
+	return 0
+}
+
+func (x *Score) GetMetalStorage() float64 {
+	if x != nil {
+		return x.MetalStorage
+	}
+	return 0
+}
+
+func (x *Score) GetEnergyStorage() float64 {
+	if x != nil {
+		return x.EnergyStorage
+	}
+	return 0
+}
+
+// Alliance is a player allying or unallying another by their TDPIDs
+type Alliance struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Player        int32                  `protobuf:"varint,1,opt,name=player,proto3" json:"player,omitempty"`
+	Ally          int32                  `protobuf:"varint,2,opt,name=ally,proto3" json:"ally,omitempty"`
+	Allied        bool                   `protobuf:"varint,3,opt,name=allied,proto3" json:"allied,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Alliance) Reset() {
+	*x = Alliance{}
+	mi := &file_tad_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Alliance) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Alliance) ProtoMessage() {}
+
+func (x *Alliance) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Alliance.ProtoReflect.Descriptor instead.
+func (*Alliance) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *Alliance) GetPlayer() int32 {
+	if x != nil {
+		return x.Player
+	}
+	return 0
+}
+
+func (x *Alliance) GetAlly() int32 {
+	if x != nil {
+		return x.Ally
+	}
+	return 0
+}
+
+func (x *Alliance) GetAllied() bool {
+	if x != nil {
+		return x.Allied
+	}
+	return false
+}
+
+type Camera struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             int32                  `protobuf:"varint,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y             int32                  `protobuf:"varint,2,opt,name=y,proto3" json:"y,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Camera) Reset() {
+	*x = Camera{}
+	mi := &file_tad_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Camera) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Camera) ProtoMessage() {}
+
+func (x *Camera) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Camera.ProtoReflect.Descriptor instead.
+func (*Camera) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *Camera) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *Camera) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+// Other is a packet without a decoder or one that is too short to decode
+type Other struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Other) Reset() {
+	*x = Other{}
+	mi := &file_tad_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Other) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Other) ProtoMessage() {}
+
+func (x *Other) ProtoReflect() protoreflect.Message {
+	mi := &file_tad_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Other.ProtoReflect.Descriptor instead.
+func (*Other) Descriptor() ([]byte, []int) {
+	return file_tad_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *Other) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+var File_tad_proto protoreflect.FileDescriptor
+
+const file_tad_proto_rawDesc = "" +
+	"\n" +
+	"\ttad.proto\x12\x06tad.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"(\n" +
+	"\x12AnalyzeDemoRequest\x12\x12\n" +
+	"\x04demo\x18\x01 \x01(\fR\x04demo\"\xef\x01\n" +
+	"\x13AnalyzeDemoResponse\x12 \n" +
+	"\x04game\x18\x01 \x01(\v2\f.tad.v1.GameR\x04game\x126\n" +
+	"\fscore_series\x18\x02 \x03(\v2\x13.tad.v1.ScoreSeriesR\vscoreSeries\x12(\n" +
+	"\x05units\x18\x03 \x03(\v2\x12.tad.v1.UnitRecordR\x05units\x12+\n" +
+	"\boutcomes\x18\x04 \x03(\v2\x0f.tad.v1.OutcomeR\boutcomes\x12'\n" +
+	"\x04chat\x18\x05 \x03(\v2\x13.tad.v1.ChatMessageR\x04chat\")\n" +
+	"\x13StreamEventsRequest\x12\x12\n" +
+	"\x04demo\x18\x01 \x01(\fR\x04demo\"\xfc\x02\n" +
+	"\x04Game\x12\x10\n" +
+	"\x03map\x18\x01 \x01(\tR\x03map\x12\x1a\n" +
+	"\brecorder\x18\x02 \x01(\tR\brecorder\x126\n" +
+	"\brecorded\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\brecorded\x12#\n" +
+	"\rrecorded_from\x18\x04 \x01(\tR\frecordedFrom\x12\x1a\n" +
+	"\bcomments\x18\x05 \x01(\tR\bcomments\x12\"\n" +
+	"\fmilliseconds\x18\x06 \x01(\x05R\fmilliseconds\x12\x14\n" +
+	"\x05moves\x18\a \x01(\x05R\x05moves\x12\x1b\n" +
+	"\tmax_units\x18\b \x01(\x05R\bmaxUnits\x12\x18\n" +
+	"\aunitsum\x18\t \x01(\tR\aunitsum\x12 \n" +
+	"\vfingerprint\x18\n" +
+	" \x01(\tR\vfingerprint\x12(\n" +
+	"\aplayers\x18\v \x03(\v2\x0e.tad.v1.PlayerR\aplayers\x12\x10\n" +
+	"\x03key\x18\f \x01(\tR\x03key\"\xdc\x01\n" +
+	"\x06Player\x12\x16\n" +
+	"\x06number\x18\x01 \x01(\x05R\x06number\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12 \n" +
+	"\x04side\x18\x03 \x01(\x0e2\f.tad.v1.SideR\x04side\x12\x14\n" +
+	"\x05color\x18\x04 \x01(\x05R\x05color\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12\x0e\n" +
+	"\x02ip\x18\x06 \x01(\tR\x02ip\x12\x16\n" +
+	"\x06cheats\x18\a \x01(\bR\x06cheats\x12\x14\n" +
+	"\x05tdpid\x18\b \x01(\x05R\x05tdpid\x12\x18\n" +
+	"\aactions\x18\t \x01(\x05R\aactions\"T\n" +
+	"\vScoreSeries\x12\x16\n" +
+	"\x06player\x18\x01 \x01(\x05R\x06player\x12-\n" +
+	"\asamples\x18\x02 \x03(\v2\x13.tad.v1.ScoreSampleR\asamples\"\xad\x03\n" +
+	"\vScoreSample\x12\"\n" +
+	"\fmilliseconds\x18\x01 \x01(\x05R\fmilliseconds\x12\x14\n" +
+	"\x05kills\x18\x02 \x01(\x05R\x05kills\x12\x16\n" +
+	"\x06losses\x18\x03 \x01(\x05R\x06losses\x12\x14\n" +
+	"\x05metal\x18\x04 \x01(\x01R\x05metal\x12\x16\n" +
+	"\x06energy\x18\x05 \x01(\x01R\x06energy\x12\x1f\n" +
+	"\vtotal_metal\x18\x06 \x01(\x01R\n" +
+	"totalMetal\x12!\n" +
+	"\ftotal_energy\x18\a \x01(\x01R\vtotalEnergy\x12!\n" +
+	"\fexcess_metal\x18\b \x01(\x01R\vexcessMetal\x12#\n" +
+	"\rexcess_energy\x18\t \x01(\x01R\fexcessEnergy\x12!\n" +
+	"\fstored_metal\x18\n" +
+	" \x01(\x01R\vstoredMetal\x12#\n" +
+	"\rstored_energy\x18\v \x01(\x01R\fstoredEnergy\x12#\n" +
+	"\rmetal_storage\x18\f \x01(\x01R\fmetalStorage\x12%\n" +
+	"\x0eenergy_storage\x18\r \x01(\x01R\renergyStorage\"\xb2\x02\n" +
+	"\n" +
+	"UnitRecord\x12\x16\n" +
+	"\x06player\x18\x01 \x01(\x05R\x06player\x12\x15\n" +
+	"\x06net_id\x18\x02 \x01(\x05R\x05netId\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x1a\n" +
+	"\bproduced\x18\x04 \x01(\x05R\bproduced\x12%\n" +
+	"\x0efirst_produced\x18\x05 \x01(\x05R\rfirstProduced\x12!\n" +
+	"\fdamage_dealt\x18\x06 \x01(\x05R\vdamageDealt\x12'\n" +
+	"\x0fdamage_received\x18\a \x01(\x05R\x0edamageReceived\x12'\n" +
+	"\x05kills\x18\b \x03(\v2\x11.tad.v1.UnitCountR\x05kills\x12)\n" +
+	"\x06deaths\x18\t \x03(\v2\x11.tad.v1.UnitCountR\x06deaths\"L\n" +
+	"\tUnitCount\x12\x15\n" +
+	"\x06net_id\x18\x01 \x01(\x05R\x05netId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05count\x18\x03 \x01(\x05R\x05count\"\xbe\x03\n" +
+	"\aOutcome\x12\x16\n" +
+	"\x06player\x18\x01 \x01(\x05R\x06player\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\x05R\x06status\x12+\n" +
+	"\x11commanders_killed\x18\x03 \x01(\x05R\x10commandersKilled\x12'\n" +
+	"\x0fcommanders_lost\x18\x04 \x01(\x05R\x0ecommandersLost\x12\x14\n" +
+	"\x05kills\x18\x05 \x01(\x05R\x05kills\x12\x16\n" +
+	"\x06losses\x18\x06 \x01(\x05R\x06losses\x12'\n" +
+	"\x0fenergy_produced\x18\a \x01(\x01R\x0eenergyProduced\x12#\n" +
+	"\rexcess_energy\x18\b \x01(\x01R\fexcessEnergy\x12%\n" +
+	"\x0emetal_produced\x18\t \x01(\x01R\rmetalProduced\x12!\n" +
+	"\fexcess_metal\x18\n" +
+	" \x01(\x01R\vexcessMetal\x12\x1e\n" +
+	"\vtime_to_die\x18\v \x01(\x05R\ttimeToDie\x12\x16\n" +
+	"\x06allied\x18\f \x01(\bR\x06allied\x12\x1b\n" +
+	"\tfoul_play\x18\r \x01(\bR\bfoulPlay\x12\x12\n" +
+	"\x04died\x18\x0e \x01(\bR\x04died\"\xe0\x01\n" +
+	"\vChatMessage\x12\"\n" +
+	"\fmilliseconds\x18\x01 \x01(\x05R\fmilliseconds\x12\x16\n" +
+	"\x06sender\x18\x02 \x01(\x05R\x06sender\x12\x1f\n" +
+	"\vsender_name\x18\x03 \x01(\tR\n" +
+	"senderName\x12\x14\n" +
+	"\x05scope\x18\x04 \x01(\tR\x05scope\x12\x1c\n" +
+	"\trecipient\x18\x05 \x01(\tR\trecipient\x12\x12\n" +
+	"\x04text\x18\x06 \x01(\tR\x04text\x12\x18\n" +
+	"\awatcher\x18\a \x01(\bR\awatcher\x12\x12\n" +
+	"\x04dead\x18\b \x01(\bR\x04dead\"\x91\x05\n" +
+	"\x05Event\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x12\n" +
+	"\x04move\x18\x02 \x01(\x05R\x04move\x12\"\n" +
+	"\fmilliseconds\x18\x03 \x01(\x05R\fmilliseconds\x12\x16\n" +
+	"\x06sender\x18\x04 \x01(\x05R\x06sender\x12\x16\n" +
+	"\x06marker\x18\x05 \x01(\x05R\x06marker\x128\n" +
+	"\funit_spawned\x18\n" +
+	" \x01(\v2\x13.tad.v1.UnitSpawnedH\x00R\vunitSpawned\x122\n" +
+	"\n" +
+	"unit_built\x18\v \x01(\v2\x11.tad.v1.UnitBuiltH\x00R\tunitBuilt\x125\n" +
+	"\vunit_killed\x18\f \x01(\v2\x12.tad.v1.UnitKilledH\x00R\n" +
+	"unitKilled\x128\n" +
+	"\funit_damaged\x18\r \x01(\v2\x13.tad.v1.UnitDamagedH\x00R\vunitDamaged\x122\n" +
+	"\n" +
+	"unit_state\x18\x0e \x01(\v2\x11.tad.v1.UnitStateH\x00R\tunitState\x12\"\n" +
+	"\x04shot\x18\x0f \x01(\v2\f.tad.v1.ShotH\x00R\x04shot\x12\"\n" +
+	"\x04chat\x18\x10 \x01(\v2\f.tad.v1.ChatH\x00R\x04chat\x12%\n" +
+	"\x05score\x18\x11 \x01(\v2\r.tad.v1.ScoreH\x00R\x05score\x12.\n" +
+	"\balliance\x18\x12 \x01(\v2\x10.tad.v1.AllianceH\x00R\balliance\x12(\n" +
+	"\x06camera\x18\x13 \x01(\v2\x0e.tad.v1.CameraH\x00R\x06camera\x12%\n" +
+	"\x05other\x18\x14 \x01(\v2\r.tad.v1.OtherH\x00R\x05otherB\a\n" +
+	"\x05event\"T\n" +
+	"\vUnitSpawned\x12\x12\n" +
+	"\x04unit\x18\x01 \x01(\x05R\x04unit\x12\x15\n" +
+	"\x06net_id\x18\x02 \x01(\x05R\x05netId\x12\f\n" +
+	"\x01x\x18\x03 \x01(\x05R\x01x\x12\f\n" +
+	"\x01y\x18\x04 \x01(\x05R\x01y\"9\n" +
+	"\tUnitBuilt\x12\x12\n" +
+	"\x04unit\x18\x01 \x01(\x05R\x04unit\x12\x18\n" +
+	"\abuilder\x18\x02 \x01(\x05R\abuilder\">\n" +
+	"\n" +
+	"UnitKilled\x12\x12\n" +
+	"\x04unit\x18\x01 \x01(\x05R\x04unit\x12\x1c\n" +
+	"\tdestroyer\x18\x02 \x01(\x05R\tdestroyer\"S\n" +
+	"\vUnitDamaged\x12\x12\n" +
+	"\x04unit\x18\x01 \x01(\x05R\x04unit\x12\x18\n" +
+	"\adamager\x18\x02 \x01(\x05R\adamager\x12\x16\n" +
+	"\x06damage\x18\x03 \x01(\x05R\x06damage\"5\n" +
+	"\tUnitState\x12\x12\n" +
+	"\x04unit\x18\x01 \x01(\x05R\x04unit\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\x05R\x05state\"b\n" +
+	"\x04Shot\x12\x18\n" +
+	"\ashooter\x18\x01 \x01(\x05R\ashooter\x12\f\n" +
+	"\x01x\x18\x02 \x01(\x05R\x01x\x12\f\n" +
+	"\x01y\x18\x03 \x01(\x05R\x01y\x12\x11\n" +
+	"\x04to_x\x18\x04 \x01(\x05R\x03toX\x12\x11\n" +
+	"\x04to_y\x18\x05 \x01(\x05R\x03toY\"\x1a\n" +
+	"\x04Chat\x12\x12\n" +
+	"\x04text\x18\x01 \x01(\tR\x04text\"\xd5\x02\n" +
+	"\x05Score\x12\x14\n" +
+	"\x05kills\x18\x01 \x01(\x05R\x05kills\x12\x16\n" +
+	"\x06losses\x18\x02 \x01(\x05R\x06losses\x12\x1f\n" +
+	"\vtotal_metal\x18\x03 \x01(\x01R\n" +
+	"totalMetal\x12!\n" +
+	"\ftotal_energy\x18\x04 \x01(\x01R\vtotalEnergy\x12!\n" +
+	"\fexcess_metal\x18\x05 \x01(\x01R\vexcessMetal\x12#\n" +
+	"\rexcess_energy\x18\x06 \x01(\x01R\fexcessEnergy\x12!\n" +
+	"\fstored_metal\x18\a \x01(\x01R\vstoredMetal\x12#\n" +
+	"\rstored_energy\x18\b \x01(\x01R\fstoredEnergy\x12#\n" +
+	"\rmetal_storage\x18\t \x01(\x01R\fmetalStorage\x12%\n" +
+	"\x0eenergy_storage\x18\n" +
+	" \x01(\x01R\renergyStorage\"N\n" +
+	"\bAlliance\x12\x16\n" +
+	"\x06player\x18\x01 \x01(\x05R\x06player\x12\x12\n" +
+	"\x04ally\x18\x02 \x01(\x05R\x04ally\x12\x16\n" +
+	"\x06allied\x18\x03 \x01(\bR\x06allied\"$\n" +
+	"\x06Camera\x12\f\n" +
+	"\x01x\x18\x01 \x01(\x05R\x01x\x12\f\n" +
+	"\x01y\x18\x02 \x01(\x05R\x01y\"\x1b\n" +
+	"\x05Other\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data*K\n" +
+	"\x04Side\x12\x14\n" +
+	"\x10SIDE_UNSPECIFIED\x10\x00\x12\f\n" +
+	"\bSIDE_ARM\x10\x01\x12\r\n" +
+	"\tSIDE_CORE\x10\x02\x12\x10\n" +
+	"\fSIDE_WATCHER\x10\x032\x96\x01\n" +
+	"\x0eReplayAnalysis\x12F\n" +
+	"\vAnalyzeDemo\x12\x1a.tad.v1.AnalyzeDemoRequest\x1a\x1b.tad.v1.AnalyzeDemoResponse\x12<\n" +
+	"\fStreamEvents\x12\x1b.tad.v1.StreamEventsRequest\x1a\r.tad.v1.Event0\x01B!Z\x1fgithub.com/cosmouser/tad/tadrpcb\x06proto3"
+
+var (
+	file_tad_proto_rawDescOnce sync.Once
+	file_tad_proto_rawDescData []byte
+)
+
+func file_tad_proto_rawDescGZIP() []byte {
+	file_tad_proto_rawDescOnce.Do(func() {
+		file_tad_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_tad_proto_rawDesc), len(file_tad_proto_rawDesc)))
+	})
+	return file_tad_proto_rawDescData
+}
+
+var file_tad_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_tad_proto_msgTypes = make([]protoimpl.MessageInfo, 23)
+var file_tad_proto_goTypes = []any{
+	(Side)(0),                     // 0: tad.v1.Side
+	(*AnalyzeDemoRequest)(nil),    // 1: tad.v1.AnalyzeDemoRequest
+	(*AnalyzeDemoResponse)(nil),   // 2: tad.v1.AnalyzeDemoResponse
+	(*StreamEventsRequest)(nil),   // 3: tad.v1.StreamEventsRequest
+	(*Game)(nil),                  // 4: tad.v1.Game
+	(*Player)(nil),                // 5: tad.v1.Player
+	(*ScoreSeries)(nil),           // 6: tad.v1.ScoreSeries
+	(*ScoreSample)(nil),           // 7: tad.v1.ScoreSample
+	(*UnitRecord)(nil),            // 8: tad.v1.UnitRecord
+	(*UnitCount)(nil),             // 9: tad.v1.UnitCount
+	(*Outcome)(nil),               // 10: tad.v1.Outcome
+	(*ChatMessage)(nil),           // 11: tad.v1.ChatMessage
+	(*Event)(nil),                 // 12: tad.v1.Event
+	(*UnitSpawned)(nil),           // 13: tad.v1.UnitSpawned
+	(*UnitBuilt)(nil),             // 14: tad.v1.UnitBuilt
+	(*UnitKilled)(nil),            // 15: tad.v1.UnitKilled
+	(*UnitDamaged)(nil),           // 16: tad.v1.UnitDamaged
+	(*UnitState)(nil),             // 17: tad.v1.UnitState
+	(*Shot)(nil),                  // 18: tad.v1.Shot
+	(*Chat)(nil),                  // 19: tad.v1.Chat
+	(*Score)(nil),                 // 20: tad.v1.Score
+	(*Alliance)(nil),              // 21: tad.v1.Alliance
+	(*Camera)(nil),                // 22: tad.v1.Camera
+	(*Other)(nil),                 // 23: tad.v1.Other
+	(*timestamppb.Timestamp)(nil), // 24: google.protobuf.Timestamp
+}
+var file_tad_proto_depIdxs = []int32{
+	4,  // 0: tad.v1.AnalyzeDemoResponse.game:type_name -> tad.v1.Game
+	6,  // 1: tad.v1.AnalyzeDemoResponse.score_series:type_name -> tad.v1.ScoreSeries
+	8,  // 2: tad.v1.AnalyzeDemoResponse.units:type_name -> tad.v1.UnitRecord
+	10, // 3: tad.v1.AnalyzeDemoResponse.outcomes:type_name -> tad.v1.Outcome
+	11, // 4: tad.v1.AnalyzeDemoResponse.chat:type_name -> tad.v1.ChatMessage
+	24, // 5: tad.v1.Game.recorded:type_name -> google.protobuf.Timestamp
+	5,  // 6: tad.v1.Game.players:type_name -> tad.v1.Player
+	0,  // 7: tad.v1.Player.side:type_name -> tad.v1.Side
+	7,  // 8: tad.v1.ScoreSeries.samples:type_name -> tad.v1.ScoreSample
+	9,  // 9: tad.v1.UnitRecord.kills:type_name -> tad.v1.UnitCount
+	9,  // 10: tad.v1.UnitRecord.deaths:type_name -> tad.v1.UnitCount
+	13, // 11: tad.v1.Event.unit_spawned:type_name -> tad.v1.UnitSpawned
+	14, // 12: tad.v1.Event.unit_built:type_name -> tad.v1.UnitBuilt
+	15, // 13: tad.v1.Event.unit_killed:type_name -> tad.v1.UnitKilled
+	16, // 14: tad.v1.Event.unit_damaged:type_name -> tad.v1.UnitDamaged
+	17, // 15: tad.v1.Event.unit_state:type_name -> tad.v1.UnitState
+	18, // 16: tad.v1.Event.shot:type_name -> tad.v1.Shot
+	19, // 17: tad.v1.Event.chat:type_name -> tad.v1.Chat
+	20, // 18: tad.v1.Event.score:type_name -> tad.v1.Score
+	21, // 19: tad.v1.Event.alliance:type_name -> tad.v1.Alliance
+	22, // 20: tad.v1.Event.camera:type_name -> tad.v1.Camera
+	23, // 21: tad.v1.Event.other:type_name -> tad.v1.Other
+	1,  // 22: tad.v1.ReplayAnalysis.AnalyzeDemo:input_type -> tad.v1.AnalyzeDemoRequest
+	3,  // 23: tad.v1.ReplayAnalysis.StreamEvents:input_type -> tad.v1.StreamEventsRequest
+	2,  // 24: tad.v1.ReplayAnalysis.AnalyzeDemo:output_type -> tad.v1.AnalyzeDemoResponse
+	12, // 25: tad.v1.ReplayAnalysis.StreamEvents:output_type -> tad.v1.Event
+	24, // [24:26] is the sub-list for method output_type
+	22, // [22:24] is the sub-list for method input_type
+	22, // [22:22] is the sub-list for extension type_name
+	22, // [22:22] is the sub-list for extension extendee
+	0,  // [0:22] is the sub-list for field type_name
+}
+
+func init() { file_tad_proto_init() }
+func file_tad_proto_init() {
+	if File_tad_proto != nil {
+		return
+	}
+	file_tad_proto_msgTypes[11].OneofWrappers = []any{
+		(*Event_UnitSpawned)(nil),
+		(*Event_UnitBuilt)(nil),
+		(*Event_UnitKilled)(nil),
+		(*Event_UnitDamaged)(nil),
+		(*Event_UnitState)(nil),
+		(*Event_Shot)(nil),
+		(*Event_Chat)(nil),
+		(*Event_Score)(nil),
+		(*Event_Alliance)(nil),
+		(*Event_Camera)(nil),
+		(*Event_Other)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_tad_proto_rawDesc), len(file_tad_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   23,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_tad_proto_goTypes,
+		DependencyIndexes: file_tad_proto_depIdxs,
+		EnumInfos:         file_tad_proto_enumTypes,
+		MessageInfos:      file_tad_proto_msgTypes,
+	}.Build()
+	File_tad_proto = out.File
+	file_tad_proto_goTypes = nil
+	file_tad_proto_depIdxs = nil
+}
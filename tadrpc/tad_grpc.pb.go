@@ -0,0 +1,174 @@
+// Replay analysis results of Total Annihilation demo recordings (.ted files).
+// Times are milliseconds of game time unless they say otherwise.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: tad.proto
+
+package tadrpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	ReplayAnalysis_AnalyzeDemo_FullMethodName  = "/tad.v1.ReplayAnalysis/AnalyzeDemo"
+	ReplayAnalysis_StreamEvents_FullMethodName = "/tad.v1.ReplayAnalysis/StreamEvents"
+)
+
+// ReplayAnalysisClient is the client API for ReplayAnalysis service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// ReplayAnalysis analyzes uploaded recordings
+type ReplayAnalysisClient interface {
+	// AnalyzeDemo runs every analysis over a recording
+	AnalyzeDemo(ctx context.Context, in *AnalyzeDemoRequest, opts ...grpc.CallOption) (*AnalyzeDemoResponse, error)
+	// StreamEvents sends the decoded packets of a recording as they are read
+	StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+}
+
+type replayAnalysisClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewReplayAnalysisClient(cc grpc.ClientConnInterface) ReplayAnalysisClient {
+	return &replayAnalysisClient{cc}
+}
+
+func (c *replayAnalysisClient) AnalyzeDemo(ctx context.Context, in *AnalyzeDemoRequest, opts ...grpc.CallOption) (*AnalyzeDemoResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AnalyzeDemoResponse)
+	err := c.cc.Invoke(ctx, ReplayAnalysis_AnalyzeDemo_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *replayAnalysisClient) StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &ReplayAnalysis_ServiceDesc.Streams[0], ReplayAnalysis_StreamEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamEventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ReplayAnalysis_StreamEventsClient = grpc.ServerStreamingClient[Event]
+
+// ReplayAnalysisServer is the server API for ReplayAnalysis service.
+// All implementations must embed UnimplementedReplayAnalysisServer
+// for forward compatibility.
+//
+// ReplayAnalysis analyzes uploaded recordings
+type ReplayAnalysisServer interface {
+	// AnalyzeDemo runs every analysis over a recording
+	AnalyzeDemo(context.Context, *AnalyzeDemoRequest) (*AnalyzeDemoResponse, error)
+	// StreamEvents sends the decoded packets of a recording as they are read
+	StreamEvents(*StreamEventsRequest, grpc.ServerStreamingServer[Event]) error
+	mustEmbedUnimplementedReplayAnalysisServer()
+}
+
+// UnimplementedReplayAnalysisServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedReplayAnalysisServer struct{}
+
+func (UnimplementedReplayAnalysisServer) AnalyzeDemo(context.Context, *AnalyzeDemoRequest) (*AnalyzeDemoResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AnalyzeDemo not implemented")
+}
+func (UnimplementedReplayAnalysisServer) StreamEvents(*StreamEventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedReplayAnalysisServer) mustEmbedUnimplementedReplayAnalysisServer() {}
+func (UnimplementedReplayAnalysisServer) testEmbeddedByValue()                        {}
+
+// UnsafeReplayAnalysisServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ReplayAnalysisServer will
+// result in compilation errors.
+type UnsafeReplayAnalysisServer interface {
+	mustEmbedUnimplementedReplayAnalysisServer()
+}
+
+func RegisterReplayAnalysisServer(s grpc.ServiceRegistrar, srv ReplayAnalysisServer) {
+	// If the following call pancis, it indicates UnimplementedReplayAnalysisServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&ReplayAnalysis_ServiceDesc, srv)
+}
+
+func _ReplayAnalysis_AnalyzeDemo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnalyzeDemoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReplayAnalysisServer).AnalyzeDemo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ReplayAnalysis_AnalyzeDemo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReplayAnalysisServer).AnalyzeDemo(ctx, req.(*AnalyzeDemoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ReplayAnalysis_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ReplayAnalysisServer).StreamEvents(m, &grpc.GenericServerStream[StreamEventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type ReplayAnalysis_StreamEventsServer = grpc.ServerStreamingServer[Event]
+
+// ReplayAnalysis_ServiceDesc is the grpc.ServiceDesc for ReplayAnalysis service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ReplayAnalysis_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tad.v1.ReplayAnalysis",
+	HandlerType: (*ReplayAnalysisServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AnalyzeDemo",
+			Handler:    _ReplayAnalysis_AnalyzeDemo_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _ReplayAnalysis_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "tad.proto",
+}